@@ -0,0 +1,144 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/codefresh-io/go/venona/pkg/agent"
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/logger"
+	"github.com/codefresh-io/go/venona/pkg/runtime"
+	"github.com/spf13/cobra"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+)
+
+type replayOptions struct {
+	file    string
+	agentID string
+	verbose bool
+}
+
+var replayCmdOptions replayOptions
+
+var replayCmd = &cobra.Command{
+	Use:   "replay",
+	Short: "Replay recorded tasks against a fake cluster",
+	Long:  "Replay the tasks recorded by \"start --record-tasks\" against a fake cluster and print the resulting Kubernetes actions",
+	Run: func(cmd *cobra.Command, args []string) {
+		dieOnError(replay(replayCmdOptions, os.Stdout))
+	},
+}
+
+func init() {
+	replayCmd.Flags().StringVar(&replayCmdOptions.file, "file", "", "path to the recorded tasks")
+	replayCmd.Flags().StringVar(&replayCmdOptions.agentID, "agent-id", "", "replay only the tasks pulled by this agent, all of them when not set")
+	replayCmd.Flags().BoolVar(&replayCmdOptions.verbose, "verbose", false, "Show the agent logs")
+	dieOnError(replayCmd.MarkFlagRequired("file"))
+
+	rootCmd.AddCommand(replayCmd)
+}
+
+func replay(options replayOptions, out io.Writer) error {
+	level := "crit"
+	if options.verbose {
+		level = "debug"
+	}
+	log := logger.New(logger.Options{Level: level})
+
+	f, err := os.Open(options.file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	recordings, err := agent.ReadRecordings(f)
+	if err != nil {
+		return err
+	}
+	if options.agentID != "" {
+		recordings = agentRecordings(recordings, options.agentID)
+	}
+
+	// every runtime of the recording gets its own fake cluster
+	clusters := map[string]*fake.Clientset{}
+	runtimes := map[string]runtime.Runtime{}
+	for _, rec := range recordings {
+		for _, t := range rec.Tasks {
+			name := t.Metadata.ReName
+			if _, ok := clusters[name]; ok || name == "" {
+				continue
+			}
+			clusters[name] = fake.NewSimpleClientset()
+			runtimes[name] = runtime.New(runtime.Options{
+				Kubernetes: kubernetes.NewForClient(clusters[name], kubernetes.Options{Logger: log.New("module", "kubernetes")}),
+				Name:       name,
+				Cluster:    "replay",
+				Placements: runtime.NewMemoryPlacementStore(),
+			})
+		}
+	}
+
+	agent.Replay(context.Background(), recordings, runtimes, log.New("module", "agent"))
+
+	for _, name := range sortedKeys(clusters) {
+		for _, action := range clusters[name].Actions() {
+			fmt.Fprintf(out, "%s\t%s\n", name, describeAction(action))
+		}
+	}
+	return nil
+}
+
+// agentRecordings keeps the batches pulled by the agent, the recording of a process
+// serving several agents mixes their tasks
+func agentRecordings(recordings []agent.Recording, agentID string) []agent.Recording {
+	res := []agent.Recording{}
+	for _, rec := range recordings {
+		if rec.Agent == agentID {
+			res = append(res, rec)
+		}
+	}
+	return res
+}
+
+func describeAction(action k8stesting.Action) string {
+	name := ""
+	switch a := action.(type) {
+	case k8stesting.CreateAction:
+		if obj, ok := a.GetObject().(interface{ GetName() string }); ok {
+			name = obj.GetName()
+		}
+	case k8stesting.GetAction:
+		name = a.GetName()
+	case k8stesting.DeleteAction:
+		name = a.GetName()
+	case k8stesting.PatchAction:
+		name = a.GetName()
+	}
+	return fmt.Sprintf("%s\t%s\t%s/%s", action.GetVerb(), action.GetResource().Resource, action.GetNamespace(), name)
+}
+
+func sortedKeys(m map[string]*fake.Clientset) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,82 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package cmd
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/agent"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func Test_replay(t *testing.T) {
+	dir, err := ioutil.TempDir("", "replay")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tasks.jsonl")
+
+	r, err := agent.NewRecorder(agent.RecorderOptions{Path: path})
+	assert.NoError(t, err)
+	metadata := task.Metadata{Workflow: "1", ReName: "re"}
+	assert.NoError(t, r.Record(time.Now(), "a", []task.Task{
+		{
+			Type: task.TypeCreatePod,
+			Spec: map[string]interface{}{
+				"kind":       "Pod",
+				"apiVersion": "v1",
+				"metadata":   map[string]interface{}{"name": "dind", "namespace": "ns"},
+			},
+			Metadata: metadata,
+		},
+		{
+			Type:     task.TypeAgentTask,
+			Spec:     map[string]interface{}{"type": "proxy"},
+			Metadata: metadata,
+		},
+	}))
+	assert.NoError(t, r.Record(time.Now(), "a", []task.Task{
+		{
+			Type:     task.TypeDeletePod,
+			Spec:     map[string]interface{}{"name": "dind", "namespace": "ns"},
+			Metadata: metadata,
+		},
+	}))
+	assert.NoError(t, r.Record(time.Now(), "b", []task.Task{
+		{
+			Type: task.TypeCreatePod,
+			Spec: map[string]interface{}{
+				"kind":       "Pod",
+				"apiVersion": "v1",
+				"metadata":   map[string]interface{}{"name": "other", "namespace": "ns"},
+			},
+			Metadata: task.Metadata{Workflow: "2", ReName: "re"},
+		},
+	}))
+	assert.NoError(t, r.Close())
+
+	out := &bytes.Buffer{}
+	assert.NoError(t, replay(replayOptions{file: path, agentID: "a"}, out))
+	assert.Equal(t, "re\tcreate\tpods\tns/dind\nre\tdelete\tpods\tns/dind\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, replay(replayOptions{file: path}, out))
+	assert.Equal(t, "re\tcreate\tpods\tns/dind\nre\tdelete\tpods\tns/dind\nre\tcreate\tpods\tns/other\n", out.String())
+}
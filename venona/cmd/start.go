@@ -16,11 +16,14 @@ package cmd
 
 import (
 	"context"
-	"crypto/tls"
+	"errors"
 	"fmt"
 	"net/http"
 	"os"
 	"os/signal"
+	"sort"
+	"strconv"
+	"strings"
 	"syscall"
 	"time"
 
@@ -33,20 +36,35 @@ import (
 	"github.com/codefresh-io/go/venona/pkg/monitoring/newrelic"
 	"github.com/codefresh-io/go/venona/pkg/runtime"
 	"github.com/codefresh-io/go/venona/pkg/server"
+	"github.com/codefresh-io/go/venona/pkg/transport"
 	nr "github.com/newrelic/go-agent/v3/newrelic"
 	"github.com/spf13/cobra"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 const (
 	defaultCodefreshHost = "https://g.codefresh.io"
+
+	quotaActionQueue  = "queue"
+	quotaActionReject = "reject"
+)
+
+var (
+	errAgentRequired        = errors.New("--agent-id and --codefresh-token or --codefresh-token-file are required unless --agents-config is set")
+	errInvalidQuota         = errors.New("invalid quota")
+	errInvalidPriorityClass = errors.New("invalid priority class, expected name=priority")
+	// a token rotated in memory only is revoked, the agent can not authenticate after a restart
+	errTokenNotPersisted = errors.New("--token-rotation-interval requires a writable token file, --codefresh-token-file or tokenFile in --agents-config")
 )
 
 type startOptions struct {
 	codefreshToken                 string
 	codefreshHost                  string
 	verbose                        bool
+	logLevel                       string
+	logFormat                      string
 	rejectTLSUnauthorized          bool
 	agentID                        string
 	taskPullingSecondsInterval     int64
@@ -56,6 +74,23 @@ type startOptions struct {
 	newrelicLicenseKey             string
 	newrelicAppname                string
 	inClusterRuntime               string
+	placementDir                   string
+	adminToken                     string
+	kubeEvents                     bool
+	recordTasks                    string
+	dryRun                         bool
+	agentsConfig                   string
+	codefreshTokenFile             string
+	tokenReloadSecondsInterval     int64
+	tokenRotationInterval          time.Duration
+	tlsCAFile                      string
+	tlsCertFile                    string
+	tlsKeyFile                     string
+	tlsMinVersion                  string
+	httpProxy                      string
+	httpsProxy                     string
+	noProxy                        string
+	priorityClasses                string
 }
 
 var (
@@ -81,16 +116,39 @@ func init() {
 	dieOnError(viper.BindEnv("port", "PORT"))
 	dieOnError(viper.BindEnv("NODE_TLS_REJECT_UNAUTHORIZED"))
 	dieOnError(viper.BindEnv("verbose", "VERBOSE"))
+	dieOnError(viper.BindEnv("log-level", "LOG_LEVEL"))
+	dieOnError(viper.BindEnv("log-format", "LOG_FORMAT"))
 	dieOnError(viper.BindEnv("newrelic-license-key", "NEWRELIC_LICENSE_KEY"))
 	dieOnError(viper.BindEnv("newrelic-appname", "NEWRELIC_APPNAME"))
+	dieOnError(viper.BindEnv("placement-dir", "VENONA_PLACEMENT_DIR"))
+	dieOnError(viper.BindEnv("admin-token", "VENONA_ADMIN_TOKEN"))
+	dieOnError(viper.BindEnv("kube-events", "VENONA_KUBE_EVENTS"))
+	dieOnError(viper.BindEnv("record-tasks", "VENONA_RECORD_TASKS"))
+	dieOnError(viper.BindEnv("dry-run", "VENONA_DRY_RUN"))
+	dieOnError(viper.BindEnv("agents-config", "VENONA_AGENTS_CONFIG"))
+	dieOnError(viper.BindEnv("codefresh-token-file", "CODEFRESH_TOKEN_FILE"))
+	dieOnError(viper.BindEnv("token-rotation-interval", "VENONA_TOKEN_ROTATION_INTERVAL"))
+	dieOnError(viper.BindEnv("tls-ca-file", "VENONA_TLS_CA_FILE"))
+	dieOnError(viper.BindEnv("tls-cert-file", "VENONA_TLS_CERT_FILE"))
+	dieOnError(viper.BindEnv("tls-key-file", "VENONA_TLS_KEY_FILE"))
+	dieOnError(viper.BindEnv("tls-min-version", "VENONA_TLS_MIN_VERSION"))
+	dieOnError(viper.BindEnv("http-proxy", "HTTP_PROXY"))
+	dieOnError(viper.BindEnv("https-proxy", "HTTPS_PROXY"))
+	dieOnError(viper.BindEnv("no-proxy", "NO_PROXY"))
+	dieOnError(viper.BindEnv("priority-classes", "VENONA_PRIORITY_CLASSES"))
 
 	viper.SetDefault("codefresh-host", defaultCodefreshHost)
 	viper.SetDefault("port", "8080")
 	viper.SetDefault("NODE_TLS_REJECT_UNAUTHORIZED", "1")
 	viper.SetDefault("in-cluster-runtime", "")
 	viper.SetDefault("newrelic-appname", AppName)
+	viper.SetDefault("log-level", "info")
+	viper.SetDefault("log-format", logger.FormatTerminal)
+	viper.SetDefault("tls-min-version", "1.2")
 
-	startCmd.Flags().BoolVar(&startCmdOptions.verbose, "verbose", viper.GetBool("verbose"), "Show more logs")
+	startCmd.Flags().BoolVar(&startCmdOptions.verbose, "verbose", viper.GetBool("verbose"), "Show more logs, same as --log-level=debug")
+	startCmd.Flags().StringVar(&startCmdOptions.logLevel, "log-level", viper.GetString("log-level"), "Log level, one of: debug, info, warn, error, crit [$LOG_LEVEL]")
+	startCmd.Flags().StringVar(&startCmdOptions.logFormat, "log-format", viper.GetString("log-format"), "Log format, one of: terminal, json [$LOG_FORMAT]")
 	startCmd.Flags().BoolVar(&startCmdOptions.rejectTLSUnauthorized, "tls-reject-unauthorized", viper.GetBool("NODE_TLS_REJECT_UNAUTHORIZED"), "Disable certificate validation for TLS connections")
 	startCmd.Flags().StringVar(&startCmdOptions.inClusterRuntime, "in-cluster-runtime", viper.GetString("in-cluster-runtime"), "Runtime name to run agent in cluster mode ")
 	startCmd.Flags().StringVar(&startCmdOptions.agentID, "agent-id", viper.GetString("agent-id"), "ID of the agent [$AGENT_ID]")
@@ -101,6 +159,23 @@ func init() {
 	startCmd.Flags().Int64Var(&startCmdOptions.taskPullingSecondsInterval, "task-pulling-interval", 3, "The interval (seconds) to pull new tasks from Codefresh")
 	startCmd.Flags().Int64Var(&startCmdOptions.statusReportingSecondsInterval, "status-reporting-interval", 10, "The interval (seconds) to report status back to Codefresh")
 	startCmd.Flags().StringVar(&startCmdOptions.newrelicLicenseKey, "newrelic-license-key", viper.GetString("newrelic-license-key"), "New-Relic license key [$NEWRELIC_LICENSE_KEY]")
+	startCmd.Flags().StringVar(&startCmdOptions.placementDir, "placement-dir", viper.GetString("placement-dir"), "path to a folder to keep workflow placement records, kept in memory when not set [$VENONA_PLACEMENT_DIR]")
+	startCmd.Flags().StringVar(&startCmdOptions.adminToken, "admin-token", viper.GetString("admin-token"), "Token to access the debug endpoints of the server, disabled when not set [$VENONA_ADMIN_TOKEN]")
+	startCmd.Flags().BoolVar(&startCmdOptions.kubeEvents, "kube-events", viper.GetBool("kube-events"), "Record Kubernetes events when workflow resources are created, deleted or queued [$VENONA_KUBE_EVENTS]")
+	startCmd.Flags().StringVar(&startCmdOptions.recordTasks, "record-tasks", viper.GetString("record-tasks"), "path to a file to record every batch of pulled tasks, for use with the replay command [$VENONA_RECORD_TASKS]")
+	startCmd.Flags().BoolVar(&startCmdOptions.dryRun, "dry-run", viper.GetBool("dry-run"), "Pull tasks and report status but only dry run changes to the clusters and log agent tasks instead of executing them [$VENONA_DRY_RUN]")
+	startCmd.Flags().StringVar(&startCmdOptions.agentsConfig, "agents-config", viper.GetString("agents-config"), "path to a file listing the agents to serve, replaces --agent-id and --codefresh-token [$VENONA_AGENTS_CONFIG]")
+	startCmd.Flags().StringVar(&startCmdOptions.codefreshTokenFile, "codefresh-token-file", viper.GetString("codefresh-token-file"), "path to a file with the Codefresh API token, reloaded when it changes, replaces --codefresh-token [$CODEFRESH_TOKEN_FILE]")
+	startCmd.Flags().Int64Var(&startCmdOptions.tokenReloadSecondsInterval, "token-reload-interval", 30, "The interval (seconds) to check the token files for changes")
+	startCmd.Flags().DurationVar(&startCmdOptions.tokenRotationInterval, "token-rotation-interval", viper.GetDuration("token-rotation-interval"), "Rotate the Codefresh token periodically, for example 720h, disabled when not set. The rotated token is saved to the token file, which must be writable [$VENONA_TOKEN_ROTATION_INTERVAL]")
+	startCmd.Flags().StringVar(&startCmdOptions.tlsCAFile, "tls-ca-file", viper.GetString("tls-ca-file"), "path to a PEM bundle of CAs to trust in addition to the system ones [$VENONA_TLS_CA_FILE]")
+	startCmd.Flags().StringVar(&startCmdOptions.tlsCertFile, "tls-cert-file", viper.GetString("tls-cert-file"), "path to a client certificate for mTLS to Codefresh [$VENONA_TLS_CERT_FILE]")
+	startCmd.Flags().StringVar(&startCmdOptions.tlsKeyFile, "tls-key-file", viper.GetString("tls-key-file"), "path to the key of the client certificate [$VENONA_TLS_KEY_FILE]")
+	startCmd.Flags().StringVar(&startCmdOptions.tlsMinVersion, "tls-min-version", viper.GetString("tls-min-version"), "Minimum TLS version, one of: 1.0, 1.1, 1.2, 1.3 [$VENONA_TLS_MIN_VERSION]")
+	startCmd.Flags().StringVar(&startCmdOptions.httpProxy, "http-proxy", viper.GetString("http-proxy"), "Proxy for outbound HTTP requests [$HTTP_PROXY]")
+	startCmd.Flags().StringVar(&startCmdOptions.httpsProxy, "https-proxy", viper.GetString("https-proxy"), "Proxy for outbound HTTPS requests [$HTTPS_PROXY]")
+	startCmd.Flags().StringVar(&startCmdOptions.noProxy, "no-proxy", viper.GetString("no-proxy"), "Comma separated hosts that are not sent through the proxy [$NO_PROXY]")
+	startCmd.Flags().StringVar(&startCmdOptions.priorityClasses, "priority-classes", viper.GetString("priority-classes"), "Comma separated priorityClassName=priority of workflow pods, higher starts first [$VENONA_PRIORITY_CLASSES]")
 	startCmd.Flags().StringVar(&startCmdOptions.newrelicAppname, "newrelic-appname", viper.GetString("newrelic-appname"), "New-Relic application name [$NEWRELIC_APPNAME]")
 
 	startCmd.Flags().VisitAll(func(f *pflag.Flag) {
@@ -109,32 +184,47 @@ func init() {
 		}
 	})
 
-	dieOnError(startCmd.MarkFlagRequired("codefresh-token"))
-	dieOnError(startCmd.MarkFlagRequired("agent-id"))
 	dieOnError(startCmd.MarkFlagRequired("port"))
 
 	rootCmd.AddCommand(startCmd)
 }
 
 func run(options startOptions) {
-	log := logger.New(logger.Options{
+	levels := logger.NewLevels()
+	logOptions := logger.Options{
 		Verbose: options.verbose,
-	})
+		Level:   options.logLevel,
+		Format:  options.logFormat,
+		Levels:  levels,
+	}
+	dieOnError(logOptions.Validate())
+	log := logger.New(logOptions)
 
+	var err error
 	log.Debug("Starting", "pid", os.Getpid(), "version", version)
 	if !options.rejectTLSUnauthorized {
 		log.Warn("Running in insecure mode", "NODE_TLS_REJECT_UNAUTHORIZED", options.rejectTLSUnauthorized)
 	}
 
-	var runtimes map[string]runtime.Runtime
+	if options.dryRun {
+		log.Warn("Running in dry-run mode, nothing is changed in the clusters")
+	}
+
+	placements := runtime.NewMemoryPlacementStore()
+	// a dry run must not leave records of objects that were never created
+	if options.placementDir != "" && !options.dryRun {
+		placements, err = runtime.NewFilePlacementStore(options.placementDir)
+		dieOnError(err)
+	}
+
+	var members map[string][]runtime.PoolMember
 	if options.inClusterRuntime != "" {
-		runtimes = inClusterRuntimeConfiguration(options)
+		members = inClusterRuntimeConfiguration(options, log)
 	} else {
-		runtimes = remoteRuntimeConfiguration(options, log)
+		members = remoteRuntimeConfiguration(options, log)
 	}
 
 	var monitor monitoring.Monitor = monitoring.NewEmpty()
-	var err error
 
 	if options.newrelicLicenseKey != "" {
 		monitor, err = newrelic.New(
@@ -144,119 +234,377 @@ func run(options startOptions) {
 		if err != nil {
 			log.Warn("Failed to create monitor", "error", err)
 		} else {
-			log.Info("Using New Relic monitor", "app-name", options.newrelicAppname, "license-key", options.newrelicLicenseKey)
+			log.Info("Using New Relic monitor", "app-name", options.newrelicAppname)
 		}
 	} else {
 		log.Warn("New Relic not starting without license key!")
 	}
 
-	var cf codefresh.Codefresh
+	agents := []config.Agent{{
+		ID:        options.agentID,
+		Token:     options.codefreshToken,
+		TokenFile: options.codefreshTokenFile,
+		Host:      options.codefreshHost,
+	}}
+	if options.agentsConfig != "" {
+		agents, err = config.LoadAgents(options.agentsConfig)
+		dieOnError(err)
+		log.Info("Serving multiple agents", "len", len(agents), "config", options.agentsConfig)
+	} else if options.agentID == "" || (options.codefreshToken == "" && options.codefreshTokenFile == "") {
+		dieOnError(errAgentRequired)
+	}
+
+	dumper := codefresh.NewDumper()
+	transport, err := transport.New(transport.Options{
+		Insecure:      !options.rejectTLSUnauthorized,
+		CAFile:        options.tlsCAFile,
+		CertFile:      options.tlsCertFile,
+		KeyFile:       options.tlsKeyFile,
+		MinTLSVersion: options.tlsMinVersion,
+		HTTPProxy:     options.httpProxy,
+		HTTPSProxy:    options.httpsProxy,
+		NoProxy:       options.noProxy,
+	})
+	dieOnError(err)
+	httpClient := http.Client{
+		Transport: monitor.NewRoundTripper(transport),
+	}
+
+	httpHeaders := http.Header{}
 	{
-		var httpClient http.Client
-		if !options.rejectTLSUnauthorized {
-			customTransport := http.DefaultTransport.(*http.Transport).Clone()
-			// #nosec
-			customTransport.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
-
-			httpClient = http.Client{
-				Transport: customTransport,
-			}
-		}
+		httpHeaders.Add("User-Agent", fmt.Sprintf("codefresh-runner-%s", version))
+	}
 
-		httpClient.Transport = monitor.NewRoundTripper(httpClient.Transport)
+	priorityClasses, err := parsePriorityClasses(options.priorityClasses)
+	dieOnError(err)
+
+	var recorder *agent.Recorder
+	if options.recordTasks != "" {
+		recorder, err = agent.NewRecorder(agent.RecorderOptions{Path: options.recordTasks})
+		dieOnError(err)
+		log.Info("Recording pulled tasks", "path", options.recordTasks)
+	}
 
-		httpHeaders := http.Header{}
-		{
-			httpHeaders.Add("User-Agent", fmt.Sprintf("codefresh-runner-%s", version))
+	// token files are watched until the agents using them are stopped
+	watchCtx, stopWatching := context.WithCancel(context.Background())
+	running := make([]*agent.Agent, 0, len(agents))
+	runtimes := []runtime.Runtime{}
+	served := make([]server.Agent, 0, len(agents))
+	for _, a := range agents {
+		host := a.Host
+		if host == "" {
+			host = options.codefreshHost
 		}
+		tokens := codefresh.NewStaticToken(a.Token)
+		if a.TokenFile != "" {
+			fileToken, err := codefresh.NewFileToken(a.TokenFile)
+			dieOnError(err)
+			if options.tokenRotationInterval > 0 {
+				if err := fileToken.Writable(); err != nil {
+					dieOnError(fmt.Errorf("%w: agent %s: %v", errTokenNotPersisted, a.ID, err))
+				}
+			}
+			go fileToken.Watch(watchCtx, time.Duration(options.tokenReloadSecondsInterval)*time.Second, log.New("module", "codefresh", "agent-id", a.ID))
+			tokens = fileToken
+		} else if options.tokenRotationInterval > 0 {
+			dieOnError(fmt.Errorf("%w: agent %s", errTokenNotPersisted, a.ID))
+		}
+		cf := codefresh.New(codefresh.Options{
+			Host:        host,
+			TokenSource: tokens,
+			AgentID:     a.ID,
+			Logger:      log.New("module", "codefresh", "agent-id", a.ID),
+			HTTPClient:  &httpClient,
+			Headers:     httpHeaders,
+			Dumper:      dumper,
+		})
 
-		cf = codefresh.New(codefresh.Options{
-			Host:       options.codefreshHost,
-			Token:      options.codefreshToken,
-			AgentID:    options.agentID,
-			Logger:     log.New("module", "service", "service", "codefresh"),
-			HTTPClient: &httpClient,
-			Headers:    httpHeaders,
+		runtimesOfAgent := agentRuntimes(a, members, placements, log)
+		for name, re := range runtimesOfAgent {
+			if err := runtime.Restore(re); err != nil {
+				log.Error("Failed to restore the quotas of running workflows", "agent-id", a.ID, "runtime", name, "error", err.Error())
+			}
+			runtimes = append(runtimes, re)
+		}
+		ag, err := agent.New(&agent.Options{
+			Codefresh:                      cf,
+			Logger:                         log.New("module", "agent", "agent-id", a.ID),
+			Runtimes:                       runtimesOfAgent,
+			ID:                             a.ID,
+			TaskPullingSecondsInterval:     time.Duration(options.taskPullingSecondsInterval) * time.Second,
+			StatusReportingSecondsInterval: time.Duration(options.statusReportingSecondsInterval) * time.Second,
+			Monitor:                        monitor,
+			Recorder:                       recorder,
+			DryRun:                         options.dryRun,
+			TokenRotationInterval:          options.tokenRotationInterval,
+			ProxyTaskTransport:             transport,
+			PriorityClasses:                priorityClasses,
 		})
+		dieOnError(err)
+		running = append(running, ag)
+		served = append(served, ag)
 	}
 
-	agent, err := agent.New(&agent.Options{
-		Codefresh:                      cf,
-		Logger:                         log.New("module", "agent"),
-		Runtimes:                       runtimes,
-		ID:                             options.agentID,
-		TaskPullingSecondsInterval:     time.Duration(options.taskPullingSecondsInterval) * time.Second,
-		StatusReportingSecondsInterval: time.Duration(options.statusReportingSecondsInterval) * time.Second,
-		Monitor:                        monitor,
-	})
-	dieOnError(err)
-
 	server, err := server.New(&server.Options{
-		Port:    fmt.Sprintf(":%s", options.serverPort),
-		Logger:  log.New("module", "server"),
-		Monitor: monitor,
+		Port:       fmt.Sprintf(":%s", options.serverPort),
+		Logger:     log.New("module", "server"),
+		Monitor:    monitor,
+		AdminToken: options.adminToken,
+		Levels:     levels,
+		Dumper:     dumper,
+		Agents:     served,
 	})
 	dieOnError(err)
 
+	stopAgents := func() error {
+		var err error
+		for _, a := range running {
+			if stopErr := a.Stop(); stopErr != nil && err == nil {
+				err = stopErr
+			}
+		}
+		stopWatching()
+		return err
+	}
+
 	ctx := context.Background()
 
-	ctx = withSignals(ctx, server.Stop, agent.Stop, log)
-	go func() { dieOnError(agent.Start(ctx)) }()
+	ctx = withSignals(ctx, server.Stop, stopAgents, levels, log)
+	for _, re := range runtimes {
+		go runtime.MonitorHealth(ctx, re)
+	}
+	for _, a := range running {
+		go func(a *agent.Agent) { dieOnError(a.Start(ctx)) }(a)
+	}
 	go func() { dieOnError(server.Start()) }()
 
 	<-ctx.Done()
 }
 
-func inClusterRuntimeConfiguration(options startOptions) map[string]runtime.Runtime {
-	k, err := kubernetes.NewInCluster()
-	dieOnError(err)
-	re := runtime.New(runtime.Options{
-		Kubernetes: k,
+// agentRuntimes builds the runtimes listed for the agent, all of them for an agent that
+// lists none, which the agents config only allows when it has a single agent. Every agent
+// gets its own runtimes, with their queues and quotas, on the shared Kubernetes clients
+func agentRuntimes(a config.Agent, members map[string][]runtime.PoolMember, placements runtime.PlacementStore, log logger.Logger) map[string]runtime.Runtime {
+	names := a.Runtimes
+	if len(names) == 0 {
+		for name := range members {
+			names = append(names, name)
+		}
+	}
+	res := map[string]runtime.Runtime{}
+	for _, name := range names {
+		m, ok := members[name]
+		if !ok {
+			log.Error("Runtime of agent not found", "agent-id", a.ID, "runtime", name)
+			continue
+		}
+		if len(m) == 1 {
+			res[name] = runtime.New(runtime.Options{
+				Kubernetes: m[0].Kubernetes,
+				Admission:  m[0].Admission,
+				Name:       name,
+				Cluster:    m[0].Name,
+				Agent:      a.ID,
+				Placements: placements,
+			})
+			continue
+		}
+		log.Info("Runtime backed by a pool of clusters", "agent-id", a.ID, "name", name, "size", len(m))
+		res[name] = runtime.NewPool(runtime.PoolOptions{
+			Name:       name,
+			Members:    m,
+			Agent:      a.ID,
+			Placements: placements,
+		})
+	}
+	return res
+}
+
+func inClusterRuntimeConfiguration(options startOptions, log logger.Logger) map[string][]runtime.PoolMember {
+	k, err := kubernetes.NewInCluster(kubernetes.Options{
+		Logger: log.New("module", "kubernetes"),
+		Events: options.kubeEvents,
+		DryRun: options.dryRun,
 	})
-	return map[string]runtime.Runtime{options.inClusterRuntime: re}
+	dieOnError(err)
+	return map[string][]runtime.PoolMember{
+		options.inClusterRuntime: {{Name: "in-cluster", Kubernetes: k}},
+	}
 }
 
-func remoteRuntimeConfiguration(options startOptions, log logger.Logger) map[string]runtime.Runtime {
+// remoteRuntimeConfiguration loads the clusters of the runtimes, by runtime name.
+// Clusters with the same host and credentials share the connection
+func remoteRuntimeConfiguration(options startOptions, log logger.Logger) map[string][]runtime.PoolMember {
 	configs, err := config.Load(options.configDir, ".*.runtime.yaml", log.New("module", "config-loader"))
 	dieOnError(err)
-	runtimes := map[string]runtime.Runtime{}
-	{
-		for name, config := range configs {
-			k, err := kubernetes.New(kubernetes.Options{
-				Token:    config.Token,
-				Type:     config.Type,
-				Host:     config.Host,
-				Cert:     config.Cert,
-				Insecure: !options.rejectTLSUnauthorized,
-			})
-			if err != nil {
-				log.Error("Failed to load kubernetes", "error", err.Error(), "file", name, "name", config.Name)
-				continue
-			}
-			re := runtime.New(runtime.Options{
-				Kubernetes: k,
-			})
-			runtimes[config.Name] = re
+	clients := kubernetes.NewClients()
+	members := map[string][]runtime.PoolMember{}
+	files := make([]string, 0, len(configs))
+	for name := range configs {
+		files = append(files, name)
+	}
+	sort.Strings(files)
+	for _, name := range files {
+		config := configs[name]
+		admission, err := admissionOptions(config.Admission)
+		if err != nil {
+			log.Error("Failed to load admission", "error", err.Error(), "file", name, "name", config.Name)
+			continue
+		}
+		k, err := clients.New(kubernetes.Options{
+			Token:    config.Token,
+			Type:     config.Type,
+			Host:     config.Host,
+			Cert:     config.Cert,
+			Insecure: !options.rejectTLSUnauthorized,
+			Deletion: deletionPolicy(config.Deletion),
+			Logger:   log.New("module", "kubernetes"),
+			Events:   options.kubeEvents,
+			DryRun:   options.dryRun,
+		})
+		if err != nil {
+			log.Error("Failed to load kubernetes", "error", err.Error(), "file", name, "name", config.Name)
+			continue
+		}
+		members[config.Name] = append(members[config.Name], runtime.PoolMember{
+			Name:       name,
+			Kubernetes: k,
+			Admission:  admission,
+			Priority:   config.Priority,
+			Weight:     config.Weight,
+		})
+	}
+	return members
+}
+
+func admissionOptions(a *config.Admission) (*runtime.AdmissionOptions, error) {
+	if a == nil {
+		return nil, nil
+	}
+	quotas := make([]runtime.Quota, 0, len(a.Quotas))
+	for _, q := range a.Quotas {
+		quota, err := runtimeQuota(q)
+		if err != nil {
+			return nil, err
+		}
+		quotas = append(quotas, quota)
+	}
+	return &runtime.AdmissionOptions{
+		Namespace:          a.Namespace,
+		LabelSelector:      a.LabelSelector,
+		MaxConcurrentPods:  a.MaxConcurrentPods,
+		MaxPendingPods:     a.MaxPendingPods,
+		CheckFreeResources: a.CheckFreeResources,
+		Quotas:             quotas,
+		PriorityAging:      time.Duration(a.PriorityAgingSeconds) * time.Second,
+	}, nil
+}
+
+// parsePriorityClasses parses name=priority pairs separated by commas
+func parsePriorityClasses(s string) (map[string]int, error) {
+	classes := map[string]int{}
+	for _, pair := range strings.Split(s, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
 		}
+		kv := strings.SplitN(pair, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("%w: %s", errInvalidPriorityClass, pair)
+		}
+		p, err := strconv.Atoi(kv[1])
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s", errInvalidPriorityClass, pair)
+		}
+		classes[kv[0]] = p
+	}
+	return classes, nil
+}
+
+func runtimeQuota(q config.Quota) (runtime.Quota, error) {
+	quota := runtime.Quota{
+		Account:      q.Account,
+		Labels:       q.Labels,
+		MaxWorkflows: q.MaxWorkflows,
+	}
+	if q.Account == "" {
+		return quota, fmt.Errorf("%w: account is required", errInvalidQuota)
+	}
+	switch q.Action {
+	case "", quotaActionQueue:
+	case quotaActionReject:
+		quota.Reject = true
+	default:
+		return quota, fmt.Errorf("%w: unknown action %s of account %s", errInvalidQuota, q.Action, q.Account)
+	}
+	var err error
+	if q.MaxCPU != "" {
+		if quota.MaxCPU, err = resource.ParseQuantity(q.MaxCPU); err != nil {
+			return quota, fmt.Errorf("%w: maxCPU of account %s: %v", errInvalidQuota, q.Account, err)
+		}
+	}
+	if q.MaxMemory != "" {
+		if quota.MaxMemory, err = resource.ParseQuantity(q.MaxMemory); err != nil {
+			return quota, fmt.Errorf("%w: maxMemory of account %s: %v", errInvalidQuota, q.Account, err)
+		}
+	}
+	return quota, nil
+}
+
+func deletionPolicy(d *config.Deletion) kubernetes.DeletionPolicy {
+	if d == nil {
+		return kubernetes.DeletionPolicy{}
+	}
+	return kubernetes.DeletionPolicy{
+		GracePeriodSeconds:  d.GracePeriodSeconds,
+		PropagationPolicy:   d.PropagationPolicy,
+		IgnoreNotFound:      d.IgnoreNotFound,
+		WaitTimeout:         time.Duration(d.WaitTimeoutSeconds) * time.Second,
+		ForceAfter:          time.Duration(d.ForceAfterSeconds) * time.Second,
+		RemovePVCFinalizers: d.RemovePVCFinalizers,
+	}
+}
+
+// handleDebugSignal turns debug logs on for all modules on SIGUSR1, and restores the
+// original levels on SIGUSR2. Returns false for any other signal
+func handleDebugSignal(sig os.Signal, levels *logger.Levels, log logger.Logger) bool {
+	switch sig {
+	case syscall.SIGUSR1:
+		if levels != nil {
+			levels.Reset()
+			_ = levels.Set(logger.DefaultModule, "debug")
+			log.Warn("Received SIGUSR1, debug logs enabled")
+		}
+		return true
+	case syscall.SIGUSR2:
+		if levels != nil {
+			levels.Reset()
+			log.Warn("Received SIGUSR2, log levels restored")
+		}
+		return true
 	}
-	return runtimes
+	return false
 }
 
 func withSignals(
 	ctx context.Context,
 	stopServer func(context.Context) error,
 	stopAgent func() error,
+	levels *logger.Levels,
 	log logger.Logger,
 ) context.Context {
 	var terminationReq int32 = 0
 	ctx, cancel := context.WithCancel(ctx)
 	sigChan := make(chan os.Signal, 10)
 
-	handleSignal(sigChan, syscall.SIGTERM, syscall.SIGINT)
+	handleSignal(sigChan, syscall.SIGTERM, syscall.SIGINT, syscall.SIGUSR1, syscall.SIGUSR2)
 
 	go func() {
 		for {
-			<-sigChan
+			sig := <-sigChan
+			if handleDebugSignal(sig, levels, log) {
+				continue
+			}
 			if terminationReq++; terminationReq > 1 {
 				// signal received more than once, forcing termination
 				log.Warn("Forcing termination!")
@@ -16,16 +16,21 @@ package cmd
 
 import (
 	"context"
+	"errors"
 	"os"
 	"os/signal"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/codefresh-io/go/venona/pkg/config"
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
 	"github.com/codefresh-io/go/venona/pkg/logger"
 	"github.com/codefresh-io/go/venona/pkg/mocks"
+	"github.com/codefresh-io/go/venona/pkg/runtime"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func Test_handleSignals(t *testing.T) {
@@ -60,6 +65,16 @@ func Test_handleSignals(t *testing.T) {
 				time.Duration(0),
 			},
 		},
+		{
+			"should not terminate on SIGUSR1 and SIGUSR2",
+			args{
+				createMockLogger(),
+				[]os.Signal{syscall.SIGUSR1, syscall.SIGUSR2},
+				false,
+				false,
+				time.Duration(0),
+			},
+		},
 		{
 			"should do forced exit when received two SIGINT signals",
 			args{
@@ -101,7 +116,7 @@ func Test_handleSignals(t *testing.T) {
 			}
 
 			ctx := context.Background()
-			ctx = withSignals(ctx, serverStopFunc, agentStopFunc, tt.args.log)
+			ctx = withSignals(ctx, serverStopFunc, agentStopFunc, logger.NewLevels(), tt.args.log)
 
 			for _, sig := range tt.args.fakeSigs {
 				sigChan <- sig
@@ -134,3 +149,86 @@ func createMockLogger() *mocks.Logger {
 	l.On("Warn", mock.Anything)
 	return l
 }
+
+func Test_agentRuntimes(t *testing.T) {
+	k := &kubernetes.MockKubernetes{}
+	members := map[string][]runtime.PoolMember{
+		"re1": {{Name: "c1", Kubernetes: k}},
+		"re2": {{Name: "c1", Kubernetes: k}, {Name: "c2", Kubernetes: k}},
+	}
+	placements := runtime.NewMemoryPlacementStore()
+	log := &mocks.Logger{}
+	log.On("Info", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	log.On("Error", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+
+	all := agentRuntimes(config.Agent{ID: "a"}, members, placements, log)
+	assert.Len(t, all, 2)
+	got := agentRuntimes(config.Agent{ID: "a", Runtimes: []string{"re2", "re3"}}, members, placements, log)
+	assert.Len(t, got, 1)
+	assert.NotNil(t, got["re2"])
+	log.AssertCalled(t, "Error", "Runtime of agent not found", "agent-id", "a", "runtime", "re3")
+
+	// agents serving the same runtime do not share its state
+	assert.NotSame(t, all["re2"], got["re2"])
+}
+
+func Test_runtimeQuota(t *testing.T) {
+	tests := []struct {
+		name    string
+		quota   config.Quota
+		want    runtime.Quota
+		wantErr bool
+	}{
+		{
+			name:  "should queue by default",
+			quota: config.Quota{Account: "a", MaxWorkflows: 2, MaxCPU: "4", MaxMemory: "8Gi"},
+			want:  runtime.Quota{Account: "a", MaxWorkflows: 2, MaxCPU: resource.MustParse("4"), MaxMemory: resource.MustParse("8Gi")},
+		},
+		{
+			name:  "should reject",
+			quota: config.Quota{Account: "*", Labels: map[string]string{"pipeline": "p"}, Action: "reject"},
+			want:  runtime.Quota{Account: "*", Labels: map[string]string{"pipeline": "p"}, Reject: true},
+		},
+		{
+			name:    "should fail without account",
+			quota:   config.Quota{MaxWorkflows: 1},
+			wantErr: true,
+		},
+		{
+			name:    "should fail on unknown action",
+			quota:   config.Quota{Account: "a", Action: "drop"},
+			wantErr: true,
+		},
+		{
+			name:    "should fail on invalid quantity",
+			quota:   config.Quota{Account: "a", MaxCPU: "lots"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := runtimeQuota(tt.quota)
+			if tt.wantErr {
+				assert.True(t, errors.Is(err, errInvalidQuota))
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_parsePriorityClasses(t *testing.T) {
+	got, err := parsePriorityClasses("high=100, low=-10,")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]int{"high": 100, "low": -10}, got)
+
+	got, err = parsePriorityClasses("")
+	assert.NoError(t, err)
+	assert.Empty(t, got)
+
+	for _, s := range []string{"high", "=1", "high=x"} {
+		_, err = parsePriorityClasses(s)
+		assert.True(t, errors.Is(err, errInvalidPriorityClass), s)
+	}
+}
@@ -0,0 +1,90 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"io/ioutil"
+	"net/http"
+	"testing"
+
+	"github.com/codefresh-io/go/venona/pkg/codefresh"
+	"github.com/codefresh-io/go/venona/pkg/codefresh/fake"
+	"github.com/codefresh-io/go/venona/pkg/config"
+	"github.com/codefresh-io/go/venona/pkg/task"
+)
+
+func TestAgent_workflowLifecycle(t *testing.T) {
+	h := newHarness(t, harnessOptions{})
+	defer h.close(t)
+
+	h.codefresh.PushTasks(podTask(task.TypeCreatePod, "1", "dind-1"))
+	h.eventually(t, func() bool { return h.podExists("dind-1") }, "workflow pod was not created")
+
+	h.codefresh.PushTasks(podTask(task.TypeDeletePod, "1", "dind-1"))
+	h.eventually(t, func() bool { return !h.podExists("dind-1") }, "workflow pod was not deleted")
+	h.eventually(t, func() bool { return len(h.codefresh.Statuses()) != 0 }, "agent did not report status")
+}
+
+func TestAgent_survivesAPIErrors(t *testing.T) {
+	h := newHarness(t, harnessOptions{})
+	defer h.close(t)
+
+	h.codefresh.PushResponse(fake.Response{StatusCode: http.StatusInternalServerError})
+	h.codefresh.PushResponse(fake.Response{StatusCode: http.StatusBadGateway})
+	h.codefresh.PushTasks(podTask(task.TypeCreatePod, "1", "dind-1"))
+	h.eventually(t, func() bool { return h.podExists("dind-1") }, "agent stopped pulling after API errors")
+}
+
+func TestAgent_reportsQueuedWorkflows(t *testing.T) {
+	h := newHarness(t, harnessOptions{
+		admission: &config.Admission{Namespace: namespace, MaxConcurrentPods: 1},
+	})
+	defer h.close(t)
+
+	h.codefresh.PushTasks(podTask(task.TypeCreatePod, "1", "dind-1"))
+	h.eventually(t, func() bool { return h.podExists("dind-1") }, "first workflow pod was not created")
+	h.codefresh.PushTasks(podTask(task.TypeCreatePod, "2", "dind-2"))
+	h.eventually(t, func() bool {
+		statuses := h.codefresh.Statuses()
+		if len(statuses) == 0 {
+			return false
+		}
+		last := statuses[len(statuses)-1]
+		return len(last.Workflows) == 1 && last.Workflows[0] == codefresh.WorkflowStatus{
+			ID:      "2",
+			Runtime: runtimeName,
+			Status:  codefresh.WorkflowStatusQueued,
+		}
+	}, "second workflow was not reported as queued")
+
+	h.codefresh.PushTasks(podTask(task.TypeDeletePod, "1", "dind-1"))
+	h.eventually(t, func() bool { return h.podExists("dind-2") }, "queued workflow did not start after capacity was freed")
+}
+
+func TestAgent_rotatesToken(t *testing.T) {
+	h := newHarness(t, harnessOptions{tokenRotationInterval: pollInterval * 4})
+	defer h.close(t)
+
+	h.eventually(t, func() bool { return h.codefresh.Token() != agentToken }, "token was not rotated")
+	h.eventually(t, func() bool {
+		b, err := ioutil.ReadFile(h.tokenFile)
+		return err == nil && string(b) == h.codefresh.Token()
+	}, "rotated token was not saved")
+	h.codefresh.PushTasks(podTask(task.TypeCreatePod, "1", "dind-1"))
+	h.eventually(t, func() bool { return h.podExists("dind-1") }, "agent did not use the rotated token")
+}
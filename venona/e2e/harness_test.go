@@ -0,0 +1,189 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/go/venona/cmd"
+	"github.com/codefresh-io/go/venona/pkg/codefresh/fake"
+	"github.com/codefresh-io/go/venona/pkg/config"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+)
+
+const (
+	agentID      = "e2e-agent"
+	agentToken   = "e2e-token"
+	runtimeName  = "e2e-runtime"
+	namespace    = "e2e"
+	waitTimeout  = time.Second * 10
+	pollInterval = time.Millisecond * 50
+)
+
+// harness runs the start command against the fake Codefresh API and a fake cluster
+type harness struct {
+	codefresh *fake.Server
+	cluster   *k8sfake.Clientset
+	servers   []*httptest.Server
+	configDir string
+	tokenFile string
+	done      chan struct{}
+}
+
+type harnessOptions struct {
+	admission             *config.Admission
+	tokenRotationInterval time.Duration
+}
+
+func newHarness(t *testing.T, opt harnessOptions) *harness {
+	headers := http.Header{}
+	// the version is only set by the release build
+	headers.Add("User-Agent", "codefresh-runner-")
+	h := &harness{
+		codefresh: fake.NewServer(fake.Options{
+			AgentID: agentID,
+			Token:   agentToken,
+			Headers: headers,
+		}),
+		cluster: k8sfake.NewSimpleClientset(),
+		done:    make(chan struct{}),
+	}
+	cf := httptest.NewServer(h.codefresh)
+	kube := httptest.NewServer(newKubeServer(h.cluster))
+	h.servers = []*httptest.Server{cf, kube}
+
+	dir, err := ioutil.TempDir("", "venona-e2e")
+	require.NoError(t, err)
+	h.configDir = dir
+	data, err := yaml.Marshal(config.Config{
+		Type:      "runtime",
+		Name:      runtimeName,
+		Host:      kube.URL,
+		Token:     "e2e-kube-token",
+		Admission: opt.admission,
+	})
+	require.NoError(t, err)
+	require.NoError(t, ioutil.WriteFile(filepath.Join(dir, "e2e.runtime.yaml"), data, 0600))
+
+	// rotated tokens are saved to the token file
+	h.tokenFile = filepath.Join(dir, "token")
+	require.NoError(t, ioutil.WriteFile(h.tokenFile, []byte(agentToken), 0600))
+
+	port := freePort(t)
+	// every flag that differs between tests is passed, the command keeps the values of the previous run
+	os.Args = []string{"venona", "start",
+		"--agent-id", agentID,
+		"--codefresh-token-file", h.tokenFile,
+		"--codefresh-host", cf.URL,
+		"--config-dir", dir,
+		"--port", port,
+		"--log-level", "error",
+		"--task-pulling-interval", "1",
+		"--status-reporting-interval", "1",
+		"--token-rotation-interval", opt.tokenRotationInterval.String(),
+	}
+	go func() {
+		defer close(h.done)
+		cmd.Execute()
+	}()
+	// the signal handlers are set before the server starts listening
+	h.eventually(t, func() bool {
+		conn, err := net.Dial("tcp", net.JoinHostPort("localhost", port))
+		if err != nil {
+			return false
+		}
+		conn.Close()
+		return true
+	}, "start command did not listen")
+	return h
+}
+
+// close stops the start command the way Kubernetes does, with SIGTERM
+func (h *harness) close(t *testing.T) {
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case <-h.done:
+	case <-time.After(waitTimeout):
+		t.Error("start command did not stop")
+	}
+	for _, s := range h.servers {
+		s.Close()
+	}
+	os.RemoveAll(h.configDir)
+	assert.Empty(t, h.codefresh.Errors(), "requests that did not match the fake Codefresh expectations")
+}
+
+func freePort(t *testing.T) string {
+	l, err := net.Listen("tcp", "localhost:0")
+	require.NoError(t, err)
+	defer l.Close()
+	return fmt.Sprint(l.Addr().(*net.TCPAddr).Port)
+}
+
+// eventually fails the test when cond does not become true in time
+func (h *harness) eventually(t *testing.T, cond func() bool, msg string) {
+	assert.Eventually(t, cond, waitTimeout, pollInterval, msg)
+}
+
+func (h *harness) podExists(name string) bool {
+	_, err := h.cluster.CoreV1().Pods(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	return err == nil
+}
+
+func podTask(taskType string, workflow string, name string) task.Task {
+	spec := map[string]interface{}{
+		"kind":       "Pod",
+		"apiVersion": "v1",
+		"metadata": map[string]interface{}{
+			"name":      name,
+			"namespace": namespace,
+		},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{"name": "dind", "image": "docker:dind"},
+			},
+		},
+	}
+	if taskType == task.TypeDeletePod {
+		spec = map[string]interface{}{"name": name, "namespace": namespace}
+	}
+	return task.Task{
+		Type: taskType,
+		Spec: spec,
+		Metadata: task.Metadata{
+			Workflow: workflow,
+			ReName:   runtimeName,
+			Account:  "e2e-account",
+		},
+	}
+}
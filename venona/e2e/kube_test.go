@@ -0,0 +1,144 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build e2e
+// +build e2e
+
+package e2e
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"strings"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	kruntime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/version"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/kubernetes/scheme"
+)
+
+// kubeServer serves the part of the Kubernetes API the agent uses on top of a fake clientset,
+// so the agent reaches the cluster through the same REST client it uses in production
+type kubeServer struct {
+	cluster *k8sfake.Clientset
+	codec   kruntime.Codec
+}
+
+func newKubeServer(cluster *k8sfake.Clientset) *kubeServer {
+	return &kubeServer{
+		cluster: cluster,
+		codec:   scheme.Codecs.LegacyCodec(v1.SchemeGroupVersion),
+	}
+}
+
+func (s *kubeServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path == "/version" {
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(version.Info{Major: "1", Minor: "20", GitVersion: "v1.20.4"})
+		return
+	}
+	parts := strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1"), "/"), "/")
+	ns := ""
+	if len(parts) >= 3 && parts[0] == "namespaces" {
+		ns, parts = parts[1], parts[2:]
+	}
+	resource, name := parts[0], ""
+	if len(parts) > 1 {
+		name = parts[1]
+	}
+	obj, err := s.handle(r, ns, resource, name)
+	if err != nil {
+		s.fail(w, err)
+		return
+	}
+	s.write(w, http.StatusOK, obj)
+}
+
+func (s *kubeServer) handle(r *http.Request, ns string, resource string, name string) (kruntime.Object, error) {
+	ctx := context.Background()
+	list := metav1.ListOptions{LabelSelector: r.URL.Query().Get("labelSelector")}
+	core := s.cluster.CoreV1()
+	switch {
+	case resource == "nodes" && r.Method == http.MethodGet:
+		return core.Nodes().List(ctx, list)
+	case resource == "pods" && r.Method == http.MethodGet && name == "":
+		return core.Pods(ns).List(ctx, list)
+	case resource == "pods" && r.Method == http.MethodGet:
+		return core.Pods(ns).Get(ctx, name, metav1.GetOptions{})
+	case resource == "pods" && r.Method == http.MethodPost:
+		pod := &v1.Pod{}
+		if err := s.decode(r, pod); err != nil {
+			return nil, err
+		}
+		return core.Pods(ns).Create(ctx, pod, metav1.CreateOptions{})
+	case resource == "pods" && r.Method == http.MethodDelete:
+		return success(), core.Pods(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	case resource == "persistentvolumeclaims" && r.Method == http.MethodGet:
+		return core.PersistentVolumeClaims(ns).Get(ctx, name, metav1.GetOptions{})
+	case resource == "persistentvolumeclaims" && r.Method == http.MethodPost:
+		pvc := &v1.PersistentVolumeClaim{}
+		if err := s.decode(r, pvc); err != nil {
+			return nil, err
+		}
+		return core.PersistentVolumeClaims(ns).Create(ctx, pvc, metav1.CreateOptions{})
+	case resource == "persistentvolumeclaims" && r.Method == http.MethodDelete:
+		return success(), core.PersistentVolumeClaims(ns).Delete(ctx, name, metav1.DeleteOptions{})
+	case resource == "events" && r.Method == http.MethodPost:
+		event := &v1.Event{}
+		if err := s.decode(r, event); err != nil {
+			return nil, err
+		}
+		return core.Events(ns).Create(ctx, event, metav1.CreateOptions{})
+	}
+	return nil, apierrors.NewMethodNotSupported(v1.Resource(resource), r.Method)
+}
+
+func (s *kubeServer) decode(r *http.Request, into kruntime.Object) error {
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	if _, _, err := s.codec.Decode(body, nil, into); err != nil {
+		return apierrors.NewBadRequest(err.Error())
+	}
+	return nil
+}
+
+func (s *kubeServer) fail(w http.ResponseWriter, err error) {
+	status, ok := err.(apierrors.APIStatus)
+	if !ok {
+		status = apierrors.NewInternalError(err)
+	}
+	st := status.Status()
+	s.write(w, int(st.Code), &st)
+}
+
+func (s *kubeServer) write(w http.ResponseWriter, code int, obj kruntime.Object) {
+	data, err := kruntime.Encode(s.codec, obj)
+	if err != nil {
+		code, data = http.StatusInternalServerError, []byte(err.Error())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	_, _ = w.Write(data)
+}
+
+func success() *metav1.Status {
+	return &metav1.Status{Status: metav1.StatusSuccess}
+}
@@ -21,6 +21,8 @@ import (
 	"errors"
 	"fmt"
 	"io/ioutil"
+	"net/http"
+	"sort"
 	"sync"
 	"time"
 
@@ -66,6 +68,16 @@ type (
 		TaskPullingSecondsInterval     time.Duration
 		StatusReportingSecondsInterval time.Duration
 		Monitor                        monitoring.Monitor
+		// Recorder writes every batch of pulled tasks, optional
+		Recorder *Recorder
+		// DryRun logs agent tasks instead of executing them
+		DryRun bool
+		// TokenRotationInterval rotates the Codefresh token periodically, disabled when zero
+		TokenRotationInterval time.Duration
+		// ProxyTaskTransport sends the requests of proxy tasks, optional
+		ProxyTaskTransport http.RoundTripper
+		// PriorityClasses maps the priorityClassName of workflow pods to a priority
+		PriorityClasses map[string]int
 	}
 
 	// Agent holds all the references from Codefresh
@@ -81,12 +93,22 @@ type (
 		lastStatus         Status
 		wg                 *sync.WaitGroup
 		monitor            monitoring.Monitor
+		recorder           *Recorder
+		dryRun             bool
+		statusMutex        *sync.RWMutex
+		// tokenRotationTicker is nil when token rotation is disabled
+		tokenRotationTicker *time.Ticker
+		priorityClasses     map[string]int
+		// proxyClient sends the requests of proxy tasks through the transport of this agent
+		proxyClient *retryablehttp.Client
 	}
 
 	// Status of the agent
 	Status struct {
 		Message string    `json:"message"`
 		Time    time.Time `json:"time"`
+		// Error of the last status report, empty when it was accepted by Codefresh
+		Error string `json:"error,omitempty"`
 	}
 
 	workflowCandidate struct {
@@ -96,9 +118,7 @@ type (
 )
 
 var (
-	httpClient = retryablehttp.NewClient()
-
-	agentTaskExecutors = map[string]func(t *task.AgentTask, log logger.Logger) error{
+	agentTaskExecutors = map[string]func(t *task.AgentTask, client *retryablehttp.Client, log logger.Logger) error{
 		"proxy": proxyRequest,
 	}
 )
@@ -122,12 +142,16 @@ func New(opt *Options) (*Agent, error) {
 	}
 	taskPullerTicker := time.NewTicker(taskPullingInterval)
 	reportStatusTicker := time.NewTicker(statusReportingInterval)
+	var tokenRotationTicker *time.Ticker
+	if opt.TokenRotationInterval > 0 {
+		tokenRotationTicker = time.NewTicker(opt.TokenRotationInterval)
+	}
 	wg := &sync.WaitGroup{}
 
 	if opt.Monitor == nil {
 		opt.Monitor = monitoring.NewEmpty()
 	}
-	httpClient.HTTPClient.Transport = opt.Monitor.NewRoundTripper(httpClient.HTTPClient.Transport)
+	proxyClient := newProxyClient(opt.ProxyTaskTransport, opt.Monitor)
 
 	return &Agent{
 		id,
@@ -140,6 +164,12 @@ func New(opt *Options) (*Agent, error) {
 		Status{},
 		wg,
 		opt.Monitor,
+		opt.Recorder,
+		opt.DryRun,
+		&sync.RWMutex{},
+		tokenRotationTicker,
+		opt.PriorityClasses,
+		proxyClient,
 	}, nil
 }
 
@@ -153,10 +183,11 @@ func (a *Agent) Start(ctx context.Context) error {
 
 	go a.startTaskPullerRoutine(ctx)
 	go a.startStatusReporterRoutine(ctx)
+	if a.tokenRotationTicker != nil {
+		go a.startTokenRotationRoutine(ctx)
+	}
 
-	reportStatus(ctx, a.cf, codefresh.AgentStatus{
-		Message: "All good",
-	}, a.log)
+	a.setStatus(reportStatus(ctx, a.cf, buildStatus(a.runtimes), a.log))
 
 	return nil
 }
@@ -170,15 +201,37 @@ func (a *Agent) Stop() error {
 	a.log.Warn("Received graceful termination request, stopping tasks...")
 	a.reportStatusTicker.Stop()
 	a.taskPullerTicker.Stop()
+	if a.tokenRotationTicker != nil {
+		a.tokenRotationTicker.Stop()
+	}
 	a.wg.Wait()
 	return nil
 }
 
 // Status returns the last knows status of the agent and related runtimes
 func (a *Agent) Status() Status {
+	a.statusMutex.RLock()
+	defer a.statusMutex.RUnlock()
 	return a.lastStatus
 }
 
+// ID returns the id of the agent
+func (a *Agent) ID() string {
+	return a.id
+}
+
+func (a *Agent) setStatus(status codefresh.AgentStatus, err error) {
+	a.statusMutex.Lock()
+	defer a.statusMutex.Unlock()
+	a.lastStatus = Status{
+		Message: status.Message,
+		Time:    time.Now(),
+	}
+	if err != nil {
+		a.lastStatus.Error = err.Error()
+	}
+}
+
 func (a *Agent) startTaskPullerRoutine(ctx context.Context) {
 	for {
 		select {
@@ -188,7 +241,9 @@ func (a *Agent) startTaskPullerRoutine(ctx context.Context) {
 			a.wg.Add(1)
 			go func(client codefresh.Codefresh, runtimes map[string]runtime.Runtime, wg *sync.WaitGroup, logger logger.Logger, monitor monitoring.Monitor) {
 				tasks := pullTasks(ctx, client, logger)
-				startTasks(ctx, tasks, runtimes, logger, monitor)
+				recordTasks(a.recorder, a.id, tasks, logger)
+				startTasks(ctx, tasks, runtimes, logger, monitor, a.dryRun, a.priorityClasses, a.proxyClient)
+				processQueues(ctx, runtimes, logger)
 				time.Sleep(time.Second * 10)
 				wg.Done()
 			}(a.cf, a.runtimes, a.wg, a.log, a.monitor)
@@ -203,21 +258,72 @@ func (a *Agent) startStatusReporterRoutine(ctx context.Context) {
 			return
 		case <-a.reportStatusTicker.C:
 			a.wg.Add(1)
-			go func(cf codefresh.Codefresh, wg *sync.WaitGroup, log logger.Logger) {
-				reportStatus(ctx, cf, codefresh.AgentStatus{
-					Message: "All good",
-				}, log)
+			go func(cf codefresh.Codefresh, runtimes map[string]runtime.Runtime, wg *sync.WaitGroup, log logger.Logger) {
+				a.setStatus(reportStatus(ctx, cf, buildStatus(runtimes), log))
 				wg.Done()
-			}(a.cf, a.wg, a.log)
+			}(a.cf, a.runtimes, a.wg, a.log)
 		}
 	}
 }
 
-func reportStatus(ctx context.Context, client codefresh.Codefresh, status codefresh.AgentStatus, logger logger.Logger) {
+func (a *Agent) startTokenRotationRoutine(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-a.tokenRotationTicker.C:
+			a.wg.Add(1)
+			rotateToken(ctx, a.cf, a.log)
+			a.wg.Done()
+		}
+	}
+}
+
+func rotateToken(ctx context.Context, client codefresh.Codefresh, logger logger.Logger) {
+	if err := client.RotateToken(ctx); err != nil {
+		logger.Error("Failed to rotate token", "error", err.Error())
+		return
+	}
+	logger.Info("Token rotated")
+}
+
+func reportStatus(ctx context.Context, client codefresh.Codefresh, status codefresh.AgentStatus, logger logger.Logger) (codefresh.AgentStatus, error) {
 	err := client.ReportStatus(ctx, status)
 	if err != nil {
 		logger.Error(err.Error())
 	}
+	return status, err
+}
+
+func buildStatus(runtimes map[string]runtime.Runtime) codefresh.AgentStatus {
+	status := codefresh.AgentStatus{
+		Message: "All good",
+	}
+	for name, re := range runtimes {
+		for _, wf := range re.QueuedWorkflows() {
+			status.Workflows = append(status.Workflows, codefresh.WorkflowStatus{
+				ID:      wf,
+				Runtime: name,
+				Status:  codefresh.WorkflowStatusQueued,
+			})
+		}
+		for _, wf := range re.RejectedWorkflows() {
+			status.Workflows = append(status.Workflows, codefresh.WorkflowStatus{
+				ID:      wf,
+				Runtime: name,
+				Status:  codefresh.WorkflowStatusRejected,
+			})
+		}
+	}
+	return status
+}
+
+func processQueues(ctx context.Context, runtimes map[string]runtime.Runtime, logger logger.Logger) {
+	for name, re := range runtimes {
+		for _, err := range re.ProcessQueue(ctx) {
+			logger.Error(err.Error(), "runtime", name)
+		}
+	}
 }
 
 func pullTasks(ctx context.Context, client codefresh.Codefresh, logger logger.Logger) []task.Task {
@@ -235,14 +341,23 @@ func pullTasks(ctx context.Context, client codefresh.Codefresh, logger logger.Lo
 	return tasks
 }
 
-func startTasks(ctx context.Context, tasks []task.Task, runtimes map[string]runtime.Runtime, logger logger.Logger, monitor monitoring.Monitor) {
+func recordTasks(recorder *Recorder, agentID string, tasks []task.Task, logger logger.Logger) {
+	if recorder == nil || len(tasks) == 0 {
+		return
+	}
+	if err := recorder.Record(time.Now(), agentID, tasks); err != nil {
+		logger.Error("Failed to record tasks", "error", err.Error())
+	}
+}
+
+func startTasks(ctx context.Context, tasks []task.Task, runtimes map[string]runtime.Runtime, log logger.Logger, monitor monitoring.Monitor, dryRun bool, priorityClasses map[string]int, proxyClient *retryablehttp.Client) {
 	creationTasks := []task.Task{}
 	deletionTasks := []task.Task{}
 	agentTasks := []task.Task{}
 
 	// divide tasks by types
 	for _, t := range tasks {
-		logger.Debug("Received task", "type", t.Type, "tid", t.Metadata.Workflow, "runtime", t.Metadata.ReName)
+		log.Debug("Received task", t.LogFields()...)
 		switch t.Type {
 		case task.TypeCreatePod, task.TypeCreatePVC:
 			creationTasks = append(creationTasks, t)
@@ -251,61 +366,76 @@ func startTasks(ctx context.Context, tasks []task.Task, runtimes map[string]runt
 		case task.TypeAgentTask:
 			agentTasks = append(agentTasks, t)
 		default:
-			logger.Error("unrecognized task type", "type", t.Type, "tid", t.Metadata.Workflow, "runtime", t.Metadata.ReName)
+			log.Error("unrecognized task type", t.LogFields()...)
 		}
 	}
 
 	// process agent tasks
 	for i := range agentTasks {
 		t := agentTasks[i]
-		logger.Info("executing agent task", "tid", t.Metadata.Workflow)
+		tlog := log.New(t.LogFields()...)
+		if dryRun {
+			tlog.Info("Dry run, agent task not executed")
+			continue
+		}
+		tlog.Info("executing agent task")
 		txn := newTransaction(monitor, t.Type, t.Metadata.Workflow, t.Metadata.ReName)
 		go func(tid string) {
-			if err := executeAgentTask(&t, logger); err != nil {
-				logger.Error(err.Error())
+			if err := executeAgentTask(&t, proxyClient, tlog); err != nil {
+				tlog.Error(err.Error())
 				txn.NoticeError(err)
 			}
 			txn.End()
-			logger.Info("finished agent task", "tid", t.Metadata.Workflow)
+			tlog.Info("finished agent task")
 		}(t.Metadata.Workflow)
 	}
 
-	// process creation tasks
-	for _, tasks := range groupTasks(creationTasks) {
+	// process deletion tasks before creation tasks, so cleanup is never delayed by a burst of workflows
+	for _, tasks := range groupTasks(deletionTasks) {
 		reName := tasks[0].Metadata.ReName
-		runtime, ok := runtimes[reName]
-		txn := newTransaction(monitor, "start-workflow", tasks[0].Metadata.Workflow, reName)
+		ctx := logger.WithFields(ctx, tasks[0].LogFields()...)
+		wlog := logger.FromContext(ctx, log)
+		re, ok := runtimes[reName]
+		txn := newTransaction(monitor, "terminate-workflow", tasks[0].Metadata.Workflow, reName)
 
 		if !ok {
-			logger.Error("Runtime not found", "workflow", tasks[0].Metadata.Workflow, "runtime", reName)
+			wlog.Error("Runtime not found")
 			txn.NoticeError(errRuntimeNotFound)
 			txn.End()
 			continue
 		}
-		logger.Info("Starting workflow", "workflow", tasks[0].Metadata.Workflow, "runtime", reName)
-		if err := runtime.StartWorkflow(ctx, tasks); err != nil {
-			logger.Error(err.Error())
-			txn.NoticeError(err)
+		wlog.Info("Terminating workflow")
+		if errs := re.TerminateWorkflow(ctx, tasks); len(errs) != 0 {
+			for _, err := range errs {
+				wlog.Error(err.Error())
+				txn.NoticeError(err)
+			}
 		}
 		txn.End()
 	}
 
-	// process deletion tasks
-	for _, tasks := range groupTasks(deletionTasks) {
+	// process creation tasks, higher priority first
+	for _, tasks := range prioritize(groupTasks(creationTasks), priorityClasses) {
 		reName := tasks[0].Metadata.ReName
-		runtime, ok := runtimes[reName]
-		txn := newTransaction(monitor, "terminate-workflow", tasks[0].Metadata.Workflow, reName)
+		ctx := logger.WithFields(ctx, tasks[0].LogFields()...)
+		wlog := logger.FromContext(ctx, log)
+		re, ok := runtimes[reName]
+		txn := newTransaction(monitor, "start-workflow", tasks[0].Metadata.Workflow, reName)
 
 		if !ok {
-			logger.Error("Runtime not found", "workflow", tasks[0].Metadata.Workflow, "runtime", reName)
+			wlog.Error("Runtime not found")
 			txn.NoticeError(errRuntimeNotFound)
 			txn.End()
 			continue
 		}
-		logger.Info("Terminating workflow", "workflow", tasks[0].Metadata.Workflow, "runtime", reName)
-		if errs := runtime.TerminateWorkflow(ctx, tasks); len(errs) != 0 {
-			for _, err := range errs {
-				logger.Error(err.Error())
+		wlog.Info("Starting workflow")
+		if err := re.StartWorkflow(ctx, tasks); err != nil {
+			if errors.Is(err, runtime.ErrWorkflowQueued) {
+				wlog.Info("Runtime is out of capacity, workflow queued")
+			} else if errors.Is(err, runtime.ErrWorkflowRejected) {
+				wlog.Warn(err.Error())
+			} else {
+				wlog.Error(err.Error())
 				txn.NoticeError(err)
 			}
 		}
@@ -313,7 +443,7 @@ func startTasks(ctx context.Context, tasks []task.Task, runtimes map[string]runt
 	}
 }
 
-func executeAgentTask(t *task.Task, log logger.Logger) error {
+func executeAgentTask(t *task.Task, client *retryablehttp.Client, log logger.Logger) error {
 	specJSON, err := json.Marshal(t.Spec)
 	if err != nil {
 		return errFailedToParseAgentTask
@@ -329,10 +459,10 @@ func executeAgentTask(t *task.Task, log logger.Logger) error {
 		return errUknownAgentTaskType
 	}
 
-	return e(&spec, log)
+	return e(&spec, client, log)
 }
 
-func proxyRequest(t *task.AgentTask, log logger.Logger) error {
+func proxyRequest(t *task.AgentTask, client *retryablehttp.Client, log logger.Logger) error {
 	spec := objx.Map(t.Params)
 	vars := objx.Map(spec.Get("runtimeContext.context.variables").MSI())
 	token := spec.Get("runtimeContext.context.eventReporting.token").Str()
@@ -367,7 +497,7 @@ func proxyRequest(t *task.AgentTask, log logger.Logger) error {
 
 	log.Info("executing proxy task", "url", url, "method", method)
 
-	resp, err := httpClient.Do(req)
+	resp, err := client.Do(req)
 	if err != nil {
 		return err
 	}
@@ -392,6 +522,41 @@ func groupTasks(tasks []task.Task) map[string][]task.Task {
 	return candidates
 }
 
+// prioritize orders the workflows by priority, then by creation time, and stores
+// the resolved priority in the metadata of their tasks for the runtime queue
+func prioritize(groups map[string][]task.Task, priorityClasses map[string]int) [][]task.Task {
+	res := make([][]task.Task, 0, len(groups))
+	createdAt := make(map[string]*time.Time, len(groups))
+	for _, tasks := range groups {
+		priority := task.Priority(tasks, priorityClasses)
+		for i := range tasks {
+			tasks[i].Metadata.Priority = priority
+		}
+		if t, err := time.Parse(time.RFC3339Nano, tasks[0].Metadata.CreatedAt); err == nil {
+			createdAt[tasks[0].Metadata.Workflow] = &t
+		}
+		res = append(res, tasks)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		a, b := res[i][0].Metadata, res[j][0].Metadata
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		// workflows without a valid creation time go after the others, ordered by ID
+		createdA, createdB := createdAt[a.Workflow], createdAt[b.Workflow]
+		switch {
+		case createdA != nil && createdB != nil && !createdA.Equal(*createdB):
+			return createdA.Before(*createdB)
+		case createdA != nil && createdB == nil:
+			return true
+		case createdA == nil && createdB != nil:
+			return false
+		}
+		return a.Workflow < b.Workflow
+	})
+	return res
+}
+
 func checkOptions(opt *Options) error {
 	if opt == nil {
 		return errOptionsRequired
@@ -420,7 +585,15 @@ func newTransaction(monitor monitoring.Monitor, taskType, tid, runtime string) m
 	return txn
 }
 
-func init() {
-	httpClient.RetryMax = defaultProxyRequestRetries
-	httpClient.HTTPClient.Timeout = defaultProxyRequestTimeout
+// newProxyClient builds the client of proxy tasks, every agent gets its own so
+// the transports of agents served by the same process are not shared
+func newProxyClient(transport http.RoundTripper, monitor monitoring.Monitor) *retryablehttp.Client {
+	client := retryablehttp.NewClient()
+	client.RetryMax = defaultProxyRequestRetries
+	client.HTTPClient.Timeout = defaultProxyRequestTimeout
+	if transport != nil {
+		client.HTTPClient.Transport = transport
+	}
+	client.HTTPClient.Transport = monitor.NewRoundTripper(client.HTTPClient.Transport)
+	return client
 }
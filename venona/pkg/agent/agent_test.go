@@ -16,17 +16,22 @@ package agent
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"reflect"
 	"testing"
 
 	"github.com/codefresh-io/go/venona/pkg/codefresh"
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
 	"github.com/codefresh-io/go/venona/pkg/logger"
 	"github.com/codefresh-io/go/venona/pkg/mocks"
+	"github.com/codefresh-io/go/venona/pkg/monitoring"
 	"github.com/codefresh-io/go/venona/pkg/runtime"
 	"github.com/codefresh-io/go/venona/pkg/task"
+	retryablehttp "github.com/hashicorp/go-retryablehttp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"k8s.io/apimachinery/pkg/api/resource"
 )
 
 func Test_groupTasks(t *testing.T) {
@@ -227,19 +232,19 @@ func TestNew(t *testing.T) {
 
 func Test_executeAgentTask(t *testing.T) {
 	executorCalled := false
-	okExecutor := func(t *task.AgentTask, log logger.Logger) error {
+	okExecutor := func(t *task.AgentTask, client *retryablehttp.Client, log logger.Logger) error {
 		executorCalled = true
 		return nil
 	}
 
-	badExecutor := func(t *task.AgentTask, log logger.Logger) error {
+	badExecutor := func(t *task.AgentTask, client *retryablehttp.Client, log logger.Logger) error {
 		executorCalled = true
 		return errProxyTaskWithoutURL
 	}
 
 	type args struct {
 		executorName string
-		executorFunc func(*task.AgentTask, logger.Logger) error
+		executorFunc func(*task.AgentTask, *retryablehttp.Client, logger.Logger) error
 		task         *task.Task
 	}
 
@@ -284,7 +289,7 @@ func Test_executeAgentTask(t *testing.T) {
 			name: "should pass the agent task spec to the executor",
 			args: &args{
 				executorName: "test",
-				executorFunc: func(t *task.AgentTask, l logger.Logger) error {
+				executorFunc: func(t *task.AgentTask, c *retryablehttp.Client, l logger.Logger) error {
 					executorCalled = true
 					data, ok := t.Params["data"].(float64)
 					if !ok {
@@ -314,7 +319,7 @@ func Test_executeAgentTask(t *testing.T) {
 		executorCalled = false
 		agentTaskExecutors[tt.args.executorName] = tt.args.executorFunc
 		t.Run(tt.name, func(t *testing.T) {
-			ret := executeAgentTask(tt.args.task, getLoggerMock())
+			ret := executeAgentTask(tt.args.task, nil, getLoggerMock())
 			if !executorCalled {
 				t.Errorf("executor function hasn't been called")
 			}
@@ -345,3 +350,129 @@ func createMockAgent() *Agent {
 
 	return a
 }
+
+func Test_buildStatus(t *testing.T) {
+	runtimes := map[string]runtime.Runtime{
+		"x": runtime.New(runtime.Options{}),
+	}
+	status := buildStatus(runtimes)
+	assert.Equal(t, "All good", status.Message)
+	assert.Empty(t, status.Workflows)
+}
+
+func Test_buildStatus_rejected(t *testing.T) {
+	k := &kubernetes.MockKubernetes{}
+	k.On("RecordEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	re := runtime.New(runtime.Options{
+		Kubernetes: k,
+		Admission: &runtime.AdmissionOptions{
+			Quotas: []runtime.Quota{{Account: "a", MaxCPU: resource.MustParse("1"), Reject: true}},
+		},
+	})
+	tasks := []task.Task{{
+		Type: task.TypeCreatePod,
+		Spec: map[string]interface{}{
+			"kind":       "Pod",
+			"apiVersion": "v1",
+			"spec": map[string]interface{}{
+				"containers": []interface{}{map[string]interface{}{
+					"name":      "dind",
+					"resources": map[string]interface{}{"requests": map[string]interface{}{"cpu": "2"}},
+				}},
+			},
+		},
+		Metadata: task.Metadata{Workflow: "wf", Account: "a"},
+	}}
+	assert.True(t, errors.Is(re.StartWorkflow(context.Background(), tasks), runtime.ErrWorkflowRejected))
+
+	status := buildStatus(map[string]runtime.Runtime{"x": re})
+	assert.Equal(t, []codefresh.WorkflowStatus{{ID: "wf", Runtime: "x", Status: codefresh.WorkflowStatusRejected}}, status.Workflows)
+}
+
+func Test_prioritize(t *testing.T) {
+	tasks := []task.Task{
+		{Type: task.TypeCreatePVC, Metadata: task.Metadata{Workflow: "old", CreatedAt: "2020-01-01T00:00:00Z"}},
+		{Type: task.TypeCreatePVC, Metadata: task.Metadata{Workflow: "new", CreatedAt: "2020-01-02T00:00:00Z"}},
+		{Type: task.TypeCreatePVC, Metadata: task.Metadata{Workflow: "urgent", Priority: 10, CreatedAt: "2020-01-03T00:00:00Z"}},
+		{
+			Type: task.TypeCreatePod,
+			Spec: map[string]interface{}{
+				"kind":       "Pod",
+				"apiVersion": "v1",
+				"spec":       map[string]interface{}{"priorityClassName": "background"},
+			},
+			Metadata: task.Metadata{Workflow: "background"},
+		},
+	}
+	got := prioritize(groupTasks(tasks), map[string]int{"background": -1})
+	workflows := []string{}
+	for _, tasks := range got {
+		workflows = append(workflows, tasks[0].Metadata.Workflow)
+	}
+	assert.Equal(t, []string{"urgent", "old", "new", "background"}, workflows)
+	assert.Equal(t, -1, got[3][0].Metadata.Priority)
+}
+
+func Test_prioritize_createdAt(t *testing.T) {
+	tests := []struct {
+		name      string
+		createdAt map[string]string
+		want      []string
+	}{
+		{
+			name:      "by creation time",
+			createdAt: map[string]string{"a": "2020-01-02T00:00:00Z", "b": "2020-01-01T00:00:00Z"},
+			want:      []string{"b", "a"},
+		},
+		{
+			name:      "by creation time in different zones",
+			createdAt: map[string]string{"a": "2020-01-01T10:00:00+02:00", "b": "2020-01-01T09:00:00Z"},
+			want:      []string{"a", "b"},
+		},
+		{
+			name:      "by creation time with fractions of seconds",
+			createdAt: map[string]string{"a": "2020-01-01T00:00:00.5Z", "b": "2020-01-01T00:00:00.25Z"},
+			want:      []string{"b", "a"},
+		},
+		{
+			name:      "by ID when the creation times are equal",
+			createdAt: map[string]string{"a": "2020-01-01T02:00:00+02:00", "b": "2020-01-01T00:00:00Z"},
+			want:      []string{"a", "b"},
+		},
+		{
+			name:      "invalid creation times last, by ID",
+			createdAt: map[string]string{"a": "yesterday", "b": "2020-01-02T00:00:00Z", "c": "", "d": "2020-01-01T00:00:00Z"},
+			want:      []string{"d", "b", "a", "c"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tasks := []task.Task{}
+			for wf, createdAt := range tt.createdAt {
+				tasks = append(tasks, task.Task{Type: task.TypeCreatePVC, Metadata: task.Metadata{Workflow: wf, CreatedAt: createdAt}})
+			}
+			workflows := []string{}
+			for _, tasks := range prioritize(groupTasks(tasks), nil) {
+				workflows = append(workflows, tasks[0].Metadata.Workflow)
+			}
+			assert.Equal(t, tt.want, workflows)
+		})
+	}
+}
+
+func Test_startTasks_deletionsFirst(t *testing.T) {
+	calls := []string{}
+	k := &kubernetes.MockKubernetes{}
+	k.On("CreateResource", mock.Anything, mock.Anything).Run(func(mock.Arguments) { calls = append(calls, "create") }).Return(nil)
+	k.On("DeleteResource", mock.Anything, mock.Anything).Run(func(mock.Arguments) { calls = append(calls, "delete") }).Return(nil)
+	k.On("RecordEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	runtimes := map[string]runtime.Runtime{"re": runtime.New(runtime.Options{Kubernetes: k})}
+	log := logger.New(logger.Options{Level: "error"})
+
+	tasks := []task.Task{
+		{Type: task.TypeCreatePVC, Spec: map[string]interface{}{}, Metadata: task.Metadata{Workflow: "1", ReName: "re"}},
+		{Type: task.TypeDeletePod, Spec: map[string]interface{}{"name": "pod"}, Metadata: task.Metadata{Workflow: "2", ReName: "re"}},
+	}
+	startTasks(context.Background(), tasks, runtimes, log, monitoring.NewEmpty(), false, nil, nil)
+	assert.Equal(t, []string{"delete", "create"}, calls)
+}
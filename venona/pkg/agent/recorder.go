@@ -0,0 +1,175 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/logger"
+	"github.com/codefresh-io/go/venona/pkg/monitoring"
+	"github.com/codefresh-io/go/venona/pkg/runtime"
+	"github.com/codefresh-io/go/venona/pkg/task"
+)
+
+const (
+	defaultRecordingMaxSize  = 10 * 1024 * 1024
+	defaultRecordingMaxFiles = 5
+	maxRecordingLineSize     = 64 * 1024 * 1024
+)
+
+type (
+	// RecorderOptions for creating a Recorder
+	RecorderOptions struct {
+		// Path of the recording, rotated files get a numeric suffix
+		Path string
+		// MaxSize in bytes of a file before it is rotated, defaults to 10MB
+		MaxSize int64
+		// MaxFiles is the number of rotated files to keep, defaults to 5
+		MaxFiles int
+	}
+
+	// Recorder writes every batch of pulled tasks to a file, one JSON line per batch
+	Recorder struct {
+		opt   RecorderOptions
+		mutex sync.Mutex
+		file  *os.File
+		size  int64
+	}
+
+	// Recording is one batch of tasks as it was pulled from Codefresh
+	Recording struct {
+		Time time.Time `json:"time"`
+		// Agent is the id of the agent that pulled the tasks
+		Agent string      `json:"agent,omitempty"`
+		Tasks []task.Task `json:"tasks"`
+	}
+)
+
+// NewRecorder opens the recording file, appending to an existing one
+func NewRecorder(opt RecorderOptions) (*Recorder, error) {
+	if opt.MaxSize <= 0 {
+		opt.MaxSize = defaultRecordingMaxSize
+	}
+	if opt.MaxFiles <= 0 {
+		opt.MaxFiles = defaultRecordingMaxFiles
+	}
+	r := &Recorder{opt: opt}
+	if err := r.open(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// Record writes the tasks pulled by the agent with sensitive values redacted
+func (r *Recorder) Record(at time.Time, agent string, tasks []task.Task) error {
+	redacted := make([]task.Task, 0, len(tasks))
+	for _, t := range tasks {
+		t.Spec = logger.Redact(t.Spec)
+		redacted = append(redacted, t)
+	}
+	b, err := json.Marshal(Recording{Time: at, Agent: agent, Tasks: redacted})
+	if err != nil {
+		return err
+	}
+	b = append(b, '\n')
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	if r.size > 0 && r.size+int64(len(b)) > r.opt.MaxSize {
+		if err := r.rotate(); err != nil {
+			return err
+		}
+	}
+	n, err := r.file.Write(b)
+	r.size += int64(n)
+	return err
+}
+
+// Close closes the recording file
+func (r *Recorder) Close() error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+	return r.file.Close()
+}
+
+func (r *Recorder) open() error {
+	f, err := os.OpenFile(r.opt.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	r.file = f
+	r.size = info.Size()
+	return nil
+}
+
+// rotate shifts path.N-1 to path.N, ..., path to path.1 and starts a new file
+func (r *Recorder) rotate() error {
+	if err := r.file.Close(); err != nil {
+		return err
+	}
+	for i := r.opt.MaxFiles - 1; i > 0; i-- {
+		from := fmt.Sprintf("%s.%d", r.opt.Path, i)
+		if _, err := os.Stat(from); err == nil {
+			if err := os.Rename(from, fmt.Sprintf("%s.%d", r.opt.Path, i+1)); err != nil {
+				return err
+			}
+		}
+	}
+	if err := os.Rename(r.opt.Path, r.opt.Path+".1"); err != nil {
+		return err
+	}
+	return r.open()
+}
+
+// ReadRecordings reads the batches written by a Recorder, oldest first
+func ReadRecordings(reader io.Reader) ([]Recording, error) {
+	recordings := []Recording{}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxRecordingLineSize)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		rec := Recording{}
+		if err := json.Unmarshal(scanner.Bytes(), &rec); err != nil {
+			return nil, fmt.Errorf("failed to read recording line %d: %w", line, err)
+		}
+		recordings = append(recordings, rec)
+	}
+	return recordings, scanner.Err()
+}
+
+// Replay hands recorded batches to the runtimes the same way the agent handles pulled tasks,
+// agent tasks are never executed since they send requests to external services
+func Replay(ctx context.Context, recordings []Recording, runtimes map[string]runtime.Runtime, log logger.Logger) {
+	monitor := monitoring.NewEmpty()
+	for _, rec := range recordings {
+		log.Info("Replaying tasks", "recorded-at", rec.Time.Format(time.RFC3339), "len", len(rec.Tasks))
+		startTasks(ctx, rec.Tasks, runtimes, log, monitor, true, nil, nil)
+		processQueues(ctx, runtimes, log)
+	}
+}
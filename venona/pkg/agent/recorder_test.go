@@ -0,0 +1,66 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package agent
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRecorder(t *testing.T) {
+	dir, err := ioutil.TempDir("", "recorder")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "tasks.jsonl")
+
+	r, err := NewRecorder(RecorderOptions{Path: path, MaxSize: 1, MaxFiles: 2})
+	assert.NoError(t, err)
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	for _, wf := range []string{"1", "2", "3", "4"} {
+		assert.NoError(t, r.Record(at, "agent", []task.Task{{
+			Type:     task.TypeCreatePod,
+			Spec:     map[string]interface{}{"name": "dind-" + wf},
+			Metadata: task.Metadata{Workflow: wf},
+		}}))
+	}
+	assert.NoError(t, r.Close())
+
+	workflows := func(p string) []string {
+		f, err := os.Open(p)
+		assert.NoError(t, err)
+		defer f.Close()
+		recordings, err := ReadRecordings(f)
+		assert.NoError(t, err)
+		res := []string{}
+		for _, rec := range recordings {
+			assert.Equal(t, at, rec.Time)
+			assert.Equal(t, "agent", rec.Agent)
+			res = append(res, rec.Tasks[0].Metadata.Workflow)
+		}
+		return res
+	}
+	// every record is bigger than the limit and gets its own file, the oldest is dropped
+	assert.Equal(t, []string{"4"}, workflows(path))
+	assert.Equal(t, []string{"3"}, workflows(path+".1"))
+	assert.Equal(t, []string{"2"}, workflows(path+".2"))
+	_, err = os.Stat(path + ".3")
+	assert.True(t, os.IsNotExist(err))
+}
@@ -17,9 +17,13 @@ package codefresh
 import (
 	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
+	"net/http/httputil"
 	"net/url"
 	"path"
 
@@ -31,11 +35,15 @@ const (
 	defaultHost = "https://g.codefresh.io"
 )
 
+var errEmptyRotatedToken = errors.New("Codefresh returned an empty token")
+
 type (
 	// Codefresh API client
 	Codefresh interface {
 		Tasks(ctx context.Context) ([]task.Task, error)
 		ReportStatus(ctx context.Context, status AgentStatus) error
+		// RotateToken replaces the agent token with a new one issued by Codefresh
+		RotateToken(ctx context.Context) error
 		Host() string
 	}
 
@@ -44,23 +52,32 @@ type (
 		Do(*http.Request) (*http.Response, error)
 	}
 
+	tokenResponse struct {
+		Token string `json:"token"`
+	}
+
 	// Options for codefresh
 	Options struct {
-		Host       string
-		Token      string
-		AgentID    string
-		Logger     logger.Logger
-		HTTPClient RequestDoer
-		Headers    http.Header
+		Host  string
+		Token string
+		// TokenSource is used instead of Token when set, to reload or rotate the token at runtime
+		TokenSource TokenSource
+		AgentID     string
+		Logger      logger.Logger
+		HTTPClient  RequestDoer
+		Headers     http.Header
+		// Dumper logs requests and responses while it is enabled, optional
+		Dumper *Dumper
 	}
 
 	cf struct {
 		host       string
-		token      string
+		tokens     TokenSource
 		agentID    string
 		logger     logger.Logger
 		httpClient RequestDoer
 		headers    http.Header
+		dumper     *Dumper
 	}
 )
 
@@ -70,14 +87,19 @@ func New(opt Options) Codefresh {
 	if host == "" {
 		host = defaultHost
 	}
+	tokens := opt.TokenSource
+	if tokens == nil {
+		tokens = NewStaticToken(opt.Token)
+	}
 
 	return &cf{
 		agentID:    opt.AgentID,
 		httpClient: opt.HTTPClient,
 		host:       host,
 		logger:     opt.Logger,
-		token:      opt.Token,
+		tokens:     tokens,
 		headers:    opt.Headers,
+		dumper:     opt.Dumper,
 	}
 }
 
@@ -114,6 +136,26 @@ func (c cf) ReportStatus(ctx context.Context, status AgentStatus) error {
 	return nil
 }
 
+// RotateToken asks Codefresh for a new agent token and replaces the current one
+func (c cf) RotateToken(ctx context.Context) error {
+	c.logger.Debug("Rotating token")
+	res, err := c.doRequest(ctx, "PUT", nil, "api", "agent", c.agentID, "token")
+	if err != nil {
+		return err
+	}
+	rotated := tokenResponse{}
+	if err := json.Unmarshal(res, &rotated); err != nil {
+		return err
+	}
+	if rotated.Token == "" {
+		return errEmptyRotatedToken
+	}
+	if err := c.tokens.Set(rotated.Token); err != nil {
+		return fmt.Errorf("token rotated but not persisted: %w", err)
+	}
+	return nil
+}
+
 func (c cf) buildErrorFromResponse(status int, body []byte) error {
 	return Error{
 		APIStatusCode: status,
@@ -148,16 +190,55 @@ func (c cf) prepareRequest(method string, data io.Reader, apis ...string) (*http
 	if err != nil {
 		return nil, err
 	}
-	req.Header = c.headers.Clone()
-	if c.token != "" {
-		req.Header.Add("Authorization", c.token)
+	if c.headers != nil {
+		req.Header = c.headers.Clone()
+	}
+	if token := c.tokens.Token(); token != "" {
+		req.Header.Add("Authorization", token)
 	}
 	req.Header.Add("Content-Type", "application/json")
 	return req, nil
 }
 
 func (c cf) doRequest(ctx context.Context, method string, body io.Reader, apis ...string) ([]byte, error) {
+	var payload []byte
+	if body != nil {
+		var err error
+		if payload, err = ioutil.ReadAll(body); err != nil {
+			return nil, err
+		}
+	}
+	data, err := c.send(ctx, method, payload, apis...)
+	var apiErr Error
+	if errors.As(err, &apiErr) && apiErr.APIStatusCode == http.StatusUnauthorized {
+		// the token may have been replaced since it was read, retry once with the new one
+		changed, reloadErr := c.tokens.Reload()
+		if reloadErr != nil {
+			c.logger.Error("Failed to reload token", "error", reloadErr.Error())
+		}
+		if changed {
+			c.logger.Info("Token reloaded after unauthorized response, retrying")
+			return c.send(ctx, method, payload, apis...)
+		}
+	}
+	return data, err
+}
+
+func (c cf) send(ctx context.Context, method string, payload []byte, apis ...string) ([]byte, error) {
+	var body io.Reader
+	if payload != nil {
+		body = bytes.NewReader(payload)
+	}
 	req, err := c.prepareRequest(method, body, apis...)
+	if err != nil {
+		return nil, err
+	}
+	dump := c.dumper.Enabled()
+	if dump {
+		if d, err := httputil.DumpRequestOut(req, false); err == nil {
+			c.logger.Info("Codefresh request", "dump", redactDump(d, payload))
+		}
+	}
 	resp, err := c.httpClient.Do(req.WithContext(ctx))
 	if err != nil {
 		return nil, err
@@ -167,6 +248,11 @@ func (c cf) doRequest(ctx context.Context, method string, body io.Reader, apis .
 	if err != nil {
 		return nil, err
 	}
+	if dump {
+		if d, err := httputil.DumpResponse(resp, false); err == nil {
+			c.logger.Info("Codefresh response", "dump", redactDump(d, data))
+		}
+	}
 	if resp.StatusCode >= 400 {
 		return nil, c.buildErrorFromResponse(resp.StatusCode, data)
 	}
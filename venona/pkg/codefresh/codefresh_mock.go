@@ -56,6 +56,20 @@ func (_m *MockCodefresh) ReportStatus(ctx context.Context, status AgentStatus) e
 	return r0
 }
 
+// RotateToken provides a mock function with given fields: ctx
+func (_m *MockCodefresh) RotateToken(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
 // Tasks provides a mock function with given fields: ctx
 func (_m *MockCodefresh) Tasks(ctx context.Context) ([]task.Task, error) {
 	ret := _m.Called(ctx)
@@ -127,7 +127,7 @@ func Test_cf_prepareURL(t *testing.T) {
 		t.Run(tt.name, func(t *testing.T) {
 			c := cf{
 				host:       tt.fields.host,
-				token:      tt.fields.token,
+				tokens:     NewStaticToken(tt.fields.token),
 				agentID:    tt.fields.agentID,
 				logger:     tt.fields.logger,
 				httpClient: tt.fields.httpClient,
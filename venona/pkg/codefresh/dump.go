@@ -0,0 +1,73 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codefresh
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sync"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/logger"
+)
+
+var authorizationHeader = regexp.MustCompile(`(?im)^(Authorization:)[^\r\n]*`)
+
+// Dumper turns on logging of the requests and responses of the Codefresh client for a limited time
+type Dumper struct {
+	mutex sync.RWMutex
+	until time.Time
+}
+
+// NewDumper creates a disabled Dumper
+func NewDumper() *Dumper {
+	return &Dumper{}
+}
+
+// Enable dumps requests and responses for the given duration, zero disables dumping
+func (d *Dumper) Enable(duration time.Duration) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	d.until = time.Now().Add(duration)
+}
+
+// Enabled returns true while dumping is on
+func (d *Dumper) Enabled() bool {
+	if d == nil {
+		return false
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return time.Now().Before(d.until)
+}
+
+// redactDump hides the credentials sent to Codefresh and the sensitive values of a JSON body,
+// bodies that are not JSON are not logged
+func redactDump(head []byte, body []byte) string {
+	res := authorizationHeader.ReplaceAllString(string(head), "$1 *****")
+	if len(body) == 0 {
+		return res
+	}
+	var v interface{}
+	if err := json.Unmarshal(body, &v); err != nil {
+		return res + fmt.Sprintf("[%d bytes not logged]", len(body))
+	}
+	redacted, err := json.Marshal(logger.Redact(v))
+	if err != nil {
+		return res + fmt.Sprintf("[%d bytes not logged]", len(body))
+	}
+	return res + string(redacted)
+}
@@ -0,0 +1,64 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codefresh
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDumper(t *testing.T) {
+	var nilDumper *Dumper
+	assert.False(t, nilDumper.Enabled())
+
+	d := NewDumper()
+	assert.False(t, d.Enabled())
+	d.Enable(time.Minute)
+	assert.True(t, d.Enabled())
+	d.Enable(0)
+	assert.False(t, d.Enabled())
+}
+
+func Test_redactDump(t *testing.T) {
+	head := "GET /api HTTP/1.1\r\nAuthorization: secret-token\r\nContent-Type: application/json\r\n\r\n"
+	tests := []struct {
+		name string
+		body string
+		want string
+	}{
+		{
+			name: "no body",
+			want: "GET /api HTTP/1.1\r\nAuthorization: *****\r\nContent-Type: application/json\r\n\r\n",
+		},
+		{
+			name: "json body",
+			body: `[{"spec":{"env":[{"name":"DOCKER_PASSWORD","value":"p4ss"}]},"eventReporting":{"token":"t0ken"}}]`,
+			want: "GET /api HTTP/1.1\r\nAuthorization: *****\r\nContent-Type: application/json\r\n\r\n" +
+				`[{"eventReporting":{"token":"*****"},"spec":{"env":[{"name":"DOCKER_PASSWORD","value":"*****"}]}}]`,
+		},
+		{
+			name: "not json body",
+			body: "token=t0ken",
+			want: "GET /api HTTP/1.1\r\nAuthorization: *****\r\nContent-Type: application/json\r\n\r\n[11 bytes not logged]",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, redactDump([]byte(head), []byte(tt.body)))
+		})
+	}
+}
@@ -0,0 +1,209 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package fake implements the part of the Codefresh API used by the agent,
+// to run the agent end to end without a Codefresh installation
+package fake
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"github.com/codefresh-io/go/venona/pkg/codefresh"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/gorilla/mux"
+)
+
+type (
+	// Options for the fake server
+	Options struct {
+		// AgentID is the only agent the server answers to
+		AgentID string
+		// Token is the expected value of the Authorization header, not checked when empty
+		Token string
+		// Headers that every request must carry
+		Headers http.Header
+	}
+
+	// Response is one scripted answer to a tasks request
+	Response struct {
+		// StatusCode defaults to 200
+		StatusCode int
+		Tasks      []task.Task
+	}
+
+	// Server is an http.Handler that serves the agent API from a scripted queue
+	Server struct {
+		opt       Options
+		router    *mux.Router
+		mutex     sync.Mutex
+		responses []Response
+		statuses  []codefresh.AgentStatus
+		errors    []error
+		polls     int
+		rotations int
+		previous  string
+	}
+)
+
+// NewServer creates a fake Codefresh server with an empty tasks queue
+func NewServer(opt Options) *Server {
+	s := &Server{
+		opt:    opt,
+		router: mux.NewRouter(),
+	}
+	api := s.router.PathPrefix("/api/agent/{id}").Subrouter()
+	api.Use(s.verify)
+	api.HandleFunc("/tasks", s.tasks).Methods(http.MethodGet)
+	api.HandleFunc("/status", s.status).Methods(http.MethodPut)
+	api.HandleFunc("/token", s.rotate).Methods(http.MethodPut)
+	return s
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.router.ServeHTTP(w, r)
+}
+
+// PushTasks adds a batch of tasks, returned by one of the next tasks requests
+func (s *Server) PushTasks(tasks ...task.Task) {
+	s.PushResponse(Response{Tasks: tasks})
+}
+
+// PushResponse adds a scripted answer, use it to simulate API failures
+func (s *Server) PushResponse(res Response) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.responses = append(s.responses, res)
+}
+
+// Pending returns the number of scripted answers that were not served yet
+func (s *Server) Pending() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return len(s.responses)
+}
+
+// Polls returns the number of tasks requests served
+func (s *Server) Polls() int {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.polls
+}
+
+// Statuses returns the status reports received so far, oldest first
+func (s *Server) Statuses() []codefresh.AgentStatus {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]codefresh.AgentStatus{}, s.statuses...)
+}
+
+// Errors returns the requests that did not match the expectations of the server
+func (s *Server) Errors() []error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]error{}, s.errors...)
+}
+
+func (s *Server) tasks(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.polls++
+	res := Response{Tasks: []task.Task{}}
+	if len(s.responses) != 0 {
+		res = s.responses[0]
+		s.responses = s.responses[1:]
+	}
+	s.mutex.Unlock()
+
+	if res.StatusCode >= 400 {
+		http.Error(w, http.StatusText(res.StatusCode), res.StatusCode)
+		return
+	}
+	if res.Tasks == nil {
+		res.Tasks = []task.Task{}
+	}
+	writeJSON(w, res.Tasks)
+}
+
+func (s *Server) status(w http.ResponseWriter, r *http.Request) {
+	status := codefresh.AgentStatus{}
+	if err := json.NewDecoder(r.Body).Decode(&status); err != nil {
+		s.fail(w, http.StatusBadRequest, fmt.Errorf("failed to decode status: %w", err))
+		return
+	}
+	s.mutex.Lock()
+	s.statuses = append(s.statuses, status)
+	s.mutex.Unlock()
+	writeJSON(w, status)
+}
+
+// rotate issues a new token, the previous one is still accepted until the next
+// rotation so requests that are already in flight do not fail
+func (s *Server) rotate(w http.ResponseWriter, r *http.Request) {
+	s.mutex.Lock()
+	s.rotations++
+	s.previous = s.opt.Token
+	s.opt.Token = fmt.Sprintf("rotated-%d", s.rotations)
+	token := s.opt.Token
+	s.mutex.Unlock()
+	writeJSON(w, map[string]string{"token": token})
+}
+
+// Token returns the token the server currently accepts
+func (s *Server) Token() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.opt.Token
+}
+
+// verify rejects requests of other agents, without the token or the expected headers
+func (s *Server) verify(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if id := mux.Vars(r)["id"]; s.opt.AgentID != "" && id != s.opt.AgentID {
+			s.fail(w, http.StatusNotFound, fmt.Errorf("request for unknown agent %s", id))
+			return
+		}
+		if !s.authorized(r.Header.Get("Authorization")) {
+			s.fail(w, http.StatusUnauthorized, fmt.Errorf("request to %s without a valid token", r.URL.Path))
+			return
+		}
+		for name := range s.opt.Headers {
+			if got, want := r.Header.Get(name), s.opt.Headers.Get(name); got != want {
+				s.fail(w, http.StatusBadRequest, fmt.Errorf("request to %s with header %s=%q, expected %q", r.URL.Path, name, got, want))
+				return
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) authorized(token string) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.opt.Token == "" || token == s.opt.Token || (s.previous != "" && token == s.previous)
+}
+
+func (s *Server) fail(w http.ResponseWriter, code int, err error) {
+	s.mutex.Lock()
+	s.errors = append(s.errors, err)
+	s.mutex.Unlock()
+	http.Error(w, err.Error(), code)
+}
+
+func writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(v)
+}
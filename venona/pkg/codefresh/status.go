@@ -19,10 +19,24 @@ import "encoding/json"
 type (
 	// AgentStatus is the latest status of the agent
 	AgentStatus struct {
-		Message string `json:"message"`
+		Message   string           `json:"message"`
+		Workflows []WorkflowStatus `json:"workflows,omitempty"`
+	}
+
+	// WorkflowStatus is the status of a workflow as seen by the agent
+	WorkflowStatus struct {
+		ID      string `json:"id"`
+		Runtime string `json:"runtime"`
+		Status  string `json:"status"`
 	}
 )
 
+// WorkflowStatusQueued is reported for workflows waiting for runtime capacity
+const WorkflowStatusQueued = "queued"
+
+// WorkflowStatusRejected is reported for workflows rejected by a quota of the runtime
+const WorkflowStatusRejected = "rejected"
+
 // Marshal status
 func (r *AgentStatus) Marshal() ([]byte, error) {
 	return json.Marshal(r)
@@ -0,0 +1,175 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codefresh
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/logger"
+)
+
+var errEmptyToken = errors.New("token file is empty")
+
+type (
+	// TokenSource provides the token sent to Codefresh
+	TokenSource interface {
+		Token() string
+		// Reload reads the token again, returns true when it changed
+		Reload() (bool, error)
+		// Set replaces the token after it was rotated
+		Set(token string) error
+	}
+
+	staticToken struct {
+		mutex sync.RWMutex
+		token string
+	}
+
+	// FileToken is a TokenSource backed by a file, usually a mounted secret
+	FileToken struct {
+		path  string
+		mutex sync.RWMutex
+		token string
+		// invalidated is the token the file still holds after a rotation that could not be
+		// written, reloading it would replace the rotated token with one Codefresh rejects
+		invalidated string
+	}
+)
+
+// NewStaticToken returns a TokenSource that never changes unless it is rotated
+func NewStaticToken(token string) TokenSource {
+	return &staticToken{token: token}
+}
+
+func (s *staticToken) Token() string {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	return s.token
+}
+
+func (s *staticToken) Reload() (bool, error) {
+	return false, nil
+}
+
+func (s *staticToken) Set(token string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.token = token
+	return nil
+}
+
+// NewFileToken reads the token from the file
+func NewFileToken(path string) (*FileToken, error) {
+	f := &FileToken{path: path}
+	if _, err := f.Reload(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// Token returns the last token read from the file
+func (f *FileToken) Token() string {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+	return f.token
+}
+
+// Reload reads the file, the current token is kept when the file can not be read
+// or still holds the token that was rotated
+func (f *FileToken) Reload() (bool, error) {
+	b, err := ioutil.ReadFile(f.path)
+	if err != nil {
+		return false, err
+	}
+	token := strings.TrimSpace(string(b))
+	if token == "" {
+		return false, errEmptyToken
+	}
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	if token == f.invalidated {
+		return false, nil
+	}
+	f.invalidated = ""
+	changed := token != f.token
+	f.token = token
+	return changed, nil
+}
+
+// Set replaces the token and writes it to the file, the token is kept in memory
+// when the file is read only, as mounted secrets are, until the file is updated
+// with a token other than the one that was rotated
+func (f *FileToken) Set(token string) error {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+	previous := f.token
+	f.token = token
+	// write and rename so a crash never leaves a partial token behind
+	tmp := f.path + ".tmp"
+	err := ioutil.WriteFile(tmp, []byte(token), 0600)
+	if err == nil {
+		if err = os.Rename(tmp, f.path); err != nil {
+			os.Remove(tmp)
+		}
+	}
+	if err != nil {
+		// after several failed rotations the file still holds the first one
+		if f.invalidated == "" {
+			f.invalidated = previous
+		}
+		return err
+	}
+	f.invalidated = ""
+	return nil
+}
+
+// Writable returns an error when a rotated token can not be written to the file,
+// as on a read only mounted secret
+func (f *FileToken) Writable() error {
+	tmp := f.path + ".tmp"
+	file, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+	file.Close()
+	return os.Remove(tmp)
+}
+
+// Watch reloads the token every interval until ctx is done
+func (f *FileToken) Watch(ctx context.Context, interval time.Duration, log logger.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := f.Reload()
+		if err != nil {
+			log.Error("Failed to reload token", "path", f.path, "error", err.Error())
+			continue
+		}
+		if changed {
+			log.Info("Token reloaded", "path", f.path)
+		}
+	}
+}
@@ -0,0 +1,145 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package codefresh
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/codefresh-io/go/venona/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func createTokenFile(t *testing.T, token string) (string, func()) {
+	dir, err := ioutil.TempDir("", "token")
+	assert.NoError(t, err)
+	path := filepath.Join(dir, "token")
+	assert.NoError(t, ioutil.WriteFile(path, []byte(token), 0600))
+	return path, func() { os.RemoveAll(dir) }
+}
+
+func TestFileToken(t *testing.T) {
+	path, cleanup := createTokenFile(t, "first\n")
+	defer cleanup()
+
+	f, err := NewFileToken(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "first", f.Token())
+
+	changed, err := f.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte(""), 0600))
+	_, err = f.Reload()
+	assert.Error(t, err)
+	assert.Equal(t, "first", f.Token(), "an empty file must not replace the token")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("second"), 0600))
+	changed, err = f.Reload()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "second", f.Token())
+
+	assert.NoError(t, f.Set("third"))
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "third", string(b))
+}
+
+func TestFileToken_Writable(t *testing.T) {
+	path, cleanup := createTokenFile(t, "first")
+	defer cleanup()
+	f, err := NewFileToken(path)
+	assert.NoError(t, err)
+	assert.NoError(t, f.Writable())
+	_, err = os.Stat(path + ".tmp")
+	assert.True(t, os.IsNotExist(err))
+
+	assert.NoError(t, os.Mkdir(path+".tmp", 0700))
+	assert.Error(t, f.Writable())
+}
+
+func TestFileToken_rotationNotPersisted(t *testing.T) {
+	path, cleanup := createTokenFile(t, "first")
+	defer cleanup()
+	f, err := NewFileToken(path)
+	assert.NoError(t, err)
+
+	// the temporary file can not be written, as on a read only mounted secret
+	assert.NoError(t, os.Mkdir(path+".tmp", 0700))
+	assert.Error(t, f.Set("rotated"))
+	assert.Error(t, f.Set("rotated-again"))
+
+	changed, err := f.Reload()
+	assert.NoError(t, err)
+	assert.False(t, changed)
+	assert.Equal(t, "rotated-again", f.Token(), "the file holds the token that was rotated")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("updated"), 0600))
+	changed, err = f.Reload()
+	assert.NoError(t, err)
+	assert.True(t, changed)
+	assert.Equal(t, "updated", f.Token())
+}
+
+func Test_cf_reloadsTokenOnUnauthorized(t *testing.T) {
+	path, cleanup := createTokenFile(t, "old")
+	defer cleanup()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "new" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		_, _ = w.Write([]byte("[]"))
+	}))
+	defer srv.Close()
+	log := &mocks.Logger{}
+	log.On("Debug", mock.Anything)
+	log.On("Info", mock.Anything)
+
+	tokens, err := NewFileToken(path)
+	assert.NoError(t, err)
+	c := New(Options{Host: srv.URL, TokenSource: tokens, AgentID: "agent", Logger: log, HTTPClient: srv.Client()})
+
+	_, err = c.Tasks(context.Background())
+	assert.Error(t, err, "the file was not updated, the request must fail")
+
+	assert.NoError(t, ioutil.WriteFile(path, []byte("new"), 0600))
+	_, err = c.Tasks(context.Background())
+	assert.NoError(t, err)
+}
+
+func Test_cf_RotateToken(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodPut, r.Method)
+		assert.Equal(t, "/api/agent/agent/token", r.URL.Path)
+		_, _ = w.Write([]byte(`{"token":"rotated"}`))
+	}))
+	defer srv.Close()
+	log := &mocks.Logger{}
+	log.On("Debug", mock.Anything)
+
+	tokens := NewStaticToken("old")
+	c := New(Options{Host: srv.URL, TokenSource: tokens, AgentID: "agent", Logger: log, HTTPClient: srv.Client()})
+	assert.NoError(t, c.RotateToken(context.Background()))
+	assert.Equal(t, "rotated", tokens.Token())
+}
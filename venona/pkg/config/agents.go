@@ -0,0 +1,79 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"errors"
+	"fmt"
+
+	"gopkg.in/yaml.v2"
+)
+
+var (
+	errAgentIDRequired    = errors.New("agent id is required")
+	errAgentTokenRequired = errors.New("agent token or token file is required")
+	errDuplicateAgentID   = errors.New("agent id is used more than once")
+	errRuntimesRequired   = errors.New("runtimes are required when serving more than one agent")
+)
+
+type (
+	// Agents lists the agents served by a single process
+	Agents struct {
+		Agents []Agent `yaml:"agents" json:"agents"`
+	}
+
+	// Agent is the connection of one agent to Codefresh
+	Agent struct {
+		ID    string `yaml:"id" json:"id"`
+		Token string `yaml:"token" json:"token"`
+		// TokenFile is watched and reloaded when it changes, used instead of Token
+		TokenFile string `yaml:"tokenFile" json:"tokenFile"`
+		// Host defaults to the host of the process
+		Host string `yaml:"host" json:"host"`
+		// Runtimes are names of runtimes loaded from the config dir, all of them when empty,
+		// required when the file lists more than one agent so an agent never reports the
+		// workflows of another account
+		Runtimes []string `yaml:"runtimes" json:"runtimes"`
+	}
+)
+
+// LoadAgents reads the agents config file
+func LoadAgents(path string) ([]Agent, error) {
+	b, err := readfile(path)
+	if err != nil {
+		return nil, err
+	}
+	cnf := Agents{}
+	if err := yaml.Unmarshal(b, &cnf); err != nil {
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for i, a := range cnf.Agents {
+		if a.ID == "" {
+			return nil, fmt.Errorf("%w: agent %d", errAgentIDRequired, i)
+		}
+		if a.Token == "" && a.TokenFile == "" {
+			return nil, fmt.Errorf("%w: %s", errAgentTokenRequired, a.ID)
+		}
+		if len(cnf.Agents) > 1 && len(a.Runtimes) == 0 {
+			return nil, fmt.Errorf("%w: %s", errRuntimesRequired, a.ID)
+		}
+		if ids[a.ID] {
+			return nil, fmt.Errorf("%w: %s", errDuplicateAgentID, a.ID)
+		}
+		ids[a.ID] = true
+	}
+	return cnf.Agents, nil
+}
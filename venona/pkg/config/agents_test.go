@@ -0,0 +1,75 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadAgents(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    []Agent
+		wantErr bool
+	}{
+		{
+			name: "should load agents",
+			data: "agents:\n- id: a\n  token: t1\n  runtimes: [re1, re2]\n- id: b\n  tokenFile: /etc/b/token\n  host: https://cf.local\n  runtimes: [re3]\n",
+			want: []Agent{
+				{ID: "a", Token: "t1", Runtimes: []string{"re1", "re2"}},
+				{ID: "b", TokenFile: "/etc/b/token", Host: "https://cf.local", Runtimes: []string{"re3"}},
+			},
+		},
+		{
+			name: "should load a single agent without runtimes",
+			data: "agents:\n- id: a\n  token: t1\n",
+			want: []Agent{{ID: "a", Token: "t1"}},
+		},
+		{
+			name:    "should fail on agent without runtimes when there are several agents",
+			data:    "agents:\n- id: a\n  token: t1\n  runtimes: [re1]\n- id: b\n  token: t2\n",
+			wantErr: true,
+		},
+		{
+			name:    "should fail on agent without token",
+			data:    "agents:\n- id: a\n",
+			wantErr: true,
+		},
+		{
+			name:    "should fail on duplicate agent id",
+			data:    "agents:\n- id: a\n  token: t1\n  runtimes: [re1]\n- id: a\n  token: t2\n  runtimes: [re2]\n",
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			defer func() { readfile = ioutil.ReadFile }()
+			readfile = func(string) ([]byte, error) {
+				return []byte(tt.data), nil
+			}
+			got, err := LoadAgents("agents.yaml")
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
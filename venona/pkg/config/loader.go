@@ -37,6 +37,48 @@ type (
 		Token string `yaml:"token" json:"token"`
 		Host  string `yaml:"host" json:"host"`
 		Name  string `yaml:"name" json:"name"`
+		// Priority and Weight are used when several configs share the same name,
+		// the runtime then fails over between the clusters
+		Priority int `yaml:"priority" json:"priority"`
+		Weight   int `yaml:"weight" json:"weight"`
+		// Admission is optional, when set workflows are queued while the runtime is out of capacity
+		Admission *Admission `yaml:"admission,omitempty" json:"admission,omitempty"`
+		// Deletion is optional, controls how TerminateWorkflow deletes the workflow resources
+		Deletion *Deletion `yaml:"deletion,omitempty" json:"deletion,omitempty"`
+	}
+
+	// Admission defines the capacity budget of a runtime
+	Admission struct {
+		Namespace          string `yaml:"namespace" json:"namespace"`
+		LabelSelector      string `yaml:"labelSelector" json:"labelSelector"`
+		MaxConcurrentPods  int    `yaml:"maxConcurrentPods" json:"maxConcurrentPods"`
+		MaxPendingPods     int    `yaml:"maxPendingPods" json:"maxPendingPods"`
+		CheckFreeResources bool   `yaml:"checkFreeResources" json:"checkFreeResources"`
+		// Quotas limit the workflows of each account, see runtime.Quota
+		Quotas []Quota `yaml:"quotas,omitempty" json:"quotas,omitempty"`
+		// PriorityAgingSeconds is the time a queued workflow waits to gain one priority level
+		PriorityAgingSeconds int64 `yaml:"priorityAgingSeconds" json:"priorityAgingSeconds"`
+	}
+
+	// Quota limits the concurrent workflows of an account, "*" applies it to every account.
+	// MaxCPU and MaxMemory are Kubernetes quantities, Action is either queue (default) or reject
+	Quota struct {
+		Account      string            `yaml:"account" json:"account"`
+		Labels       map[string]string `yaml:"labels,omitempty" json:"labels,omitempty"`
+		MaxWorkflows int               `yaml:"maxWorkflows" json:"maxWorkflows"`
+		MaxCPU       string            `yaml:"maxCPU" json:"maxCPU"`
+		MaxMemory    string            `yaml:"maxMemory" json:"maxMemory"`
+		Action       string            `yaml:"action" json:"action"`
+	}
+
+	// Deletion defines how workflow resources are deleted from the runtime
+	Deletion struct {
+		GracePeriodSeconds  *int64 `yaml:"gracePeriodSeconds" json:"gracePeriodSeconds"`
+		PropagationPolicy   string `yaml:"propagationPolicy" json:"propagationPolicy"`
+		IgnoreNotFound      bool   `yaml:"ignoreNotFound" json:"ignoreNotFound"`
+		WaitTimeoutSeconds  int64  `yaml:"waitTimeoutSeconds" json:"waitTimeoutSeconds"`
+		ForceAfterSeconds   int64  `yaml:"forceAfterSeconds" json:"forceAfterSeconds"`
+		RemovePVCFinalizers bool   `yaml:"removePVCFinalizers" json:"removePVCFinalizers"`
 	}
 
 	// Options to load the config
@@ -115,6 +115,33 @@ func TestLoad(t *testing.T) {
 				return []byte{}, nil
 			},
 		},
+		{
+			name: "return config with admission budget",
+			args: args{
+				dir:     "location",
+				logger:  mockLogger(),
+				pattern: ".*",
+			},
+			wantErr: false,
+			want: map[string]Config{
+				"location/file.a.yaml": {
+					Name: "runtime",
+					Admission: &Admission{
+						Namespace:         "codefresh",
+						MaxConcurrentPods: 10,
+					},
+				},
+			},
+			walkFileFunc: func(root string, fn filepath.WalkFunc) error {
+				return fn("location/file.a.yaml", &info{
+					name:  "file.a.yaml",
+					isDir: false,
+				}, nil)
+			},
+			fileReadFunc: func(string) ([]byte, error) {
+				return []byte("name: runtime\nadmission:\n  namespace: codefresh\n  maxConcurrentPods: 10\n"), nil
+			},
+		},
 	}
 	for _, tt := range tests {
 		t.Run(tt.name, func(t *testing.T) {
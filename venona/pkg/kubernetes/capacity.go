@@ -0,0 +1,142 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"encoding/json"
+
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+type (
+	// CapacityOptions to select what is taken into account when calculating capacity
+	CapacityOptions struct {
+		Namespace     string
+		LabelSelector string
+		// Resources calculates the free allocatable cpu and memory of the cluster,
+		// requires listing all the nodes and pods of the cluster
+		Resources bool
+	}
+
+	// Capacity is a snapshot of the workload running on the cluster
+	Capacity struct {
+		Pods        int
+		PendingPods int
+		FreeCPU     resource.Quantity
+		FreeMemory  resource.Quantity
+	}
+
+	// Resources requested by a set of objects
+	Resources struct {
+		CPU    resource.Quantity
+		Memory resource.Quantity
+	}
+)
+
+func (k kube) Capacity(ctx context.Context, opt CapacityOptions) (*Capacity, error) {
+	pods, err := k.client.CoreV1().Pods(opt.Namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: opt.LabelSelector,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	c := &Capacity{}
+	for i := range pods.Items {
+		switch pods.Items[i].Status.Phase {
+		case v1.PodSucceeded, v1.PodFailed:
+			continue
+		case v1.PodPending:
+			c.PendingPods++
+		}
+		c.Pods++
+	}
+
+	if !opt.Resources {
+		return c, nil
+	}
+
+	nodes, err := k.client.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for _, n := range nodes.Items {
+		if n.Spec.Unschedulable {
+			continue
+		}
+		c.FreeCPU.Add(*n.Status.Allocatable.Cpu())
+		c.FreeMemory.Add(*n.Status.Allocatable.Memory())
+	}
+
+	all, err := k.client.CoreV1().Pods(metav1.NamespaceAll).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		return nil, err
+	}
+	for i := range all.Items {
+		p := &all.Items[i]
+		if p.Spec.NodeName == "" || p.Status.Phase == v1.PodSucceeded || p.Status.Phase == v1.PodFailed {
+			continue
+		}
+		r := podRequests(p)
+		c.FreeCPU.Sub(r.CPU)
+		c.FreeMemory.Sub(r.Memory)
+	}
+	return c, nil
+}
+
+// RequestedResources returns the sum of the resources requested by the given pod specs,
+// specs of any other kind are ignored
+func RequestedResources(specs ...interface{}) (Resources, error) {
+	total := Resources{}
+	for _, spec := range specs {
+		bytes, err := json.Marshal(spec)
+		if err != nil {
+			return total, err
+		}
+		obj, _, err := kubeDecode(bytes, nil, nil)
+		if err != nil {
+			return total, err
+		}
+		pod, ok := obj.(*v1.Pod)
+		if !ok {
+			continue
+		}
+		r := podRequests(pod)
+		total.CPU.Add(r.CPU)
+		total.Memory.Add(r.Memory)
+	}
+	return total, nil
+}
+
+func podRequests(p *v1.Pod) Resources {
+	r := Resources{}
+	for _, c := range p.Spec.Containers {
+		r.CPU.Add(*c.Resources.Requests.Cpu())
+		r.Memory.Add(*c.Resources.Requests.Memory())
+	}
+	// init containers run one by one, the pod needs the biggest of them
+	for _, c := range p.Spec.InitContainers {
+		if c.Resources.Requests.Cpu().Cmp(r.CPU) > 0 {
+			r.CPU = c.Resources.Requests.Cpu().DeepCopy()
+		}
+		if c.Resources.Requests.Memory().Cmp(r.Memory) > 0 {
+			r.Memory = c.Resources.Requests.Memory().DeepCopy()
+		}
+	}
+	return r
+}
@@ -0,0 +1,113 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func newPod(ns, name, node string, phase v1.PodPhase, cpu string) *v1.Pod {
+	return &v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: ns,
+		},
+		Spec: v1.PodSpec{
+			NodeName: node,
+			Containers: []v1.Container{
+				{
+					Name: "c",
+					Resources: v1.ResourceRequirements{
+						Requests: v1.ResourceList{
+							v1.ResourceCPU: resource.MustParse(cpu),
+						},
+					},
+				},
+			},
+		},
+		Status: v1.PodStatus{
+			Phase: phase,
+		},
+	}
+}
+
+func Test_kube_Capacity(t *testing.T) {
+	client := fake.NewSimpleClientset(
+		&v1.Node{
+			ObjectMeta: metav1.ObjectMeta{Name: "node"},
+			Status: v1.NodeStatus{
+				Allocatable: v1.ResourceList{
+					v1.ResourceCPU:    resource.MustParse("4"),
+					v1.ResourceMemory: resource.MustParse("8Gi"),
+				},
+			},
+		},
+		newPod("ns", "running", "node", v1.PodRunning, "1"),
+		newPod("ns", "pending", "", v1.PodPending, "1"),
+		newPod("ns", "done", "node", v1.PodSucceeded, "1"),
+		newPod("other", "other", "node", v1.PodRunning, "500m"),
+	)
+	k := kube{client: client}
+
+	c, err := k.Capacity(context.Background(), CapacityOptions{Namespace: "ns"})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, c.Pods)
+	assert.Equal(t, 1, c.PendingPods)
+	assert.True(t, c.FreeCPU.IsZero())
+
+	c, err = k.Capacity(context.Background(), CapacityOptions{Namespace: "ns", Resources: true})
+	assert.NoError(t, err)
+	assert.Equal(t, 0, c.FreeCPU.Cmp(resource.MustParse("2500m")))
+	assert.Equal(t, 0, c.FreeMemory.Cmp(resource.MustParse("8Gi")))
+}
+
+func TestRequestedResources(t *testing.T) {
+	r, err := RequestedResources(
+		map[string]interface{}{
+			"kind":       "Pod",
+			"apiVersion": "v1",
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name": "a",
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{"cpu": "1", "memory": "1Gi"},
+						},
+					},
+					map[string]interface{}{
+						"name": "b",
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{"cpu": "500m"},
+						},
+					},
+				},
+			},
+		},
+		map[string]interface{}{
+			"kind":       "PersistentVolumeClaim",
+			"apiVersion": "v1",
+		},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, r.CPU.Cmp(resource.MustParse("1500m")))
+	assert.Equal(t, 0, r.Memory.Cmp(resource.MustParse("1Gi")))
+}
@@ -0,0 +1,191 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/logger"
+	"github.com/codefresh-io/go/venona/pkg/task"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+var deletionPollInterval = time.Second
+
+type (
+	// DeletionPolicy controls how workflow resources are deleted,
+	// the zero value deletes with the cluster defaults and does not wait
+	DeletionPolicy struct {
+		// GracePeriodSeconds overrides the grace period of the deleted object
+		GracePeriodSeconds *int64
+		// PropagationPolicy is one of Orphan, Background or Foreground
+		PropagationPolicy string
+		// IgnoreNotFound treats objects that are already gone as deleted
+		IgnoreNotFound bool
+		// WaitTimeout waits in the background for the object to be removed from the cluster,
+		// to force the deletion of objects stuck terminating
+		WaitTimeout time.Duration
+		// ForceAfter is the time to wait, while waiting for deletion, before a pod stuck
+		// in Terminating is deleted with zero grace period. Should be smaller than WaitTimeout
+		ForceAfter time.Duration
+		// RemovePVCFinalizers removes the finalizers of a PersistentVolumeClaim that
+		// is still there after ForceAfter
+		RemovePVCFinalizers bool
+	}
+
+	deleteFunc func(ctx context.Context, name string, opt metav1.DeleteOptions) error
+	getFunc    func(ctx context.Context, name string) error
+)
+
+func (p DeletionPolicy) deleteOptions() metav1.DeleteOptions {
+	opt := metav1.DeleteOptions{
+		GracePeriodSeconds: p.GracePeriodSeconds,
+	}
+	if p.PropagationPolicy != "" {
+		policy := metav1.DeletionPropagation(p.PropagationPolicy)
+		opt.PropagationPolicy = &policy
+	}
+	return opt
+}
+
+func (k kube) deletePod(ctx context.Context, opt DeleteOptions) error {
+	pods := k.client.CoreV1().Pods(opt.Namespace)
+	force := func(ctx context.Context) error {
+		zero := int64(0)
+		logger.FromContext(ctx, k.logger).Warn("Pod is stuck terminating, forcing deletion", "name", opt.Name, "namespace", opt.Namespace)
+		o := k.deletion.deleteOptions()
+		o.GracePeriodSeconds = &zero
+		return pods.Delete(ctx, opt.Name, o)
+	}
+	return k.delete(ctx, opt,
+		func(ctx context.Context, name string, o metav1.DeleteOptions) error {
+			return pods.Delete(ctx, name, o)
+		},
+		func(ctx context.Context, name string) error {
+			_, err := pods.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		force,
+	)
+}
+
+func (k kube) deletePVC(ctx context.Context, opt DeleteOptions) error {
+	pvcs := k.client.CoreV1().PersistentVolumeClaims(opt.Namespace)
+	var force func(ctx context.Context) error
+	if k.deletion.RemovePVCFinalizers {
+		force = func(ctx context.Context) error {
+			logger.FromContext(ctx, k.logger).Warn("PersistentVolumeClaim is stuck terminating, removing finalizers", "name", opt.Name, "namespace", opt.Namespace)
+			_, err := pvcs.Patch(ctx, opt.Name, types.MergePatchType, []byte(`{"metadata":{"finalizers":null}}`), metav1.PatchOptions{})
+			return err
+		}
+	}
+	return k.delete(ctx, opt,
+		func(ctx context.Context, name string, o metav1.DeleteOptions) error {
+			return pvcs.Delete(ctx, name, o)
+		},
+		func(ctx context.Context, name string) error {
+			_, err := pvcs.Get(ctx, name, metav1.GetOptions{})
+			return err
+		},
+		force,
+	)
+}
+
+// delete deletes the object according to the deletion policy. The wait for the object to be
+// removed runs in the background, so the deletions of other workflows are not held by it
+func (k kube) delete(ctx context.Context, opt DeleteOptions, del deleteFunc, get getFunc, force func(context.Context) error) error {
+	o := k.deletion.deleteOptions()
+	o.DryRun = k.dryRunOption()
+	err := del(ctx, opt.Name, o)
+	if apierrors.IsNotFound(err) && k.deletion.IgnoreNotFound {
+		return nil
+	}
+	// a dry run deletion leaves the object in place, there is nothing to wait for
+	if err != nil || k.deletion.WaitTimeout == time.Duration(0) || k.dryRun {
+		return err
+	}
+	go k.waitDeleted(detached{ctx}, opt, get, force)
+	return nil
+}
+
+// waitDeleted waits up to WaitTimeout for the object to be removed, force is called once
+// if the object is still there after ForceAfter. Failures are logged and recorded on the object
+func (k kube) waitDeleted(ctx context.Context, opt DeleteOptions, get getFunc, force func(context.Context) error) {
+	err := k.wait(ctx, opt, get, force)
+	if err == nil {
+		return
+	}
+	logger.FromContext(ctx, k.logger).Error("Failed waiting for deletion", "name", opt.Name, "namespace", opt.Namespace, "err", err.Error())
+	k.RecordEvent(WorkflowResource(opt), v1.EventTypeWarning, EventReasonDeleteFailed,
+		fmt.Sprintf("Failed waiting for %s to be deleted: %s", opt.Name, err.Error()))
+}
+
+func (k kube) wait(ctx context.Context, opt DeleteOptions, get getFunc, force func(context.Context) error) error {
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, k.deletion.WaitTimeout)
+	defer cancel()
+	ticker := time.NewTicker(deletionPollInterval)
+	defer ticker.Stop()
+	forced := force == nil || k.deletion.ForceAfter == time.Duration(0)
+	for {
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("timed out waiting for %s %s/%s to be deleted", kindName(opt.Kind), opt.Namespace, opt.Name)
+		case <-ticker.C:
+		}
+		err := get(ctx, opt.Name)
+		if apierrors.IsNotFound(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if !forced && time.Since(start) >= k.deletion.ForceAfter {
+			forced = true
+			if err := force(ctx); err != nil && !apierrors.IsNotFound(err) {
+				return err
+			}
+		}
+	}
+}
+
+// detached keeps the values of the context, the logger fields of the workflow,
+// without its deadline and cancellation
+type detached struct {
+	context.Context
+}
+
+func (detached) Deadline() (time.Time, bool) { return time.Time{}, false }
+func (detached) Done() <-chan struct{}       { return nil }
+func (detached) Err() error                  { return nil }
+
+// kindNames are the kinds of the objects deleted by the deletion tasks
+var kindNames = map[string]string{
+	task.TypeDeletePod: "Pod",
+	task.TypeDeletePVC: "PersistentVolumeClaim",
+}
+
+func kindName(kind string) string {
+	if name, ok := kindNames[kind]; ok {
+		return name
+	}
+	return kind
+}
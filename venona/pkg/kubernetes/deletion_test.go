@@ -0,0 +1,162 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/mocks"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes/fake"
+	k8stesting "k8s.io/client-go/testing"
+	"k8s.io/client-go/tools/record"
+)
+
+// createStuckPodClientSet returns a clientset where the first deletion of the pod
+// leaves it in the cluster, as if it was stuck terminating
+func createStuckPodClientSet() *fake.Clientset {
+	client := fake.NewSimpleClientset(&v1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "dind", Namespace: "ns"},
+	})
+	deletions := 0
+	client.Fake.PrependReactor("delete", "pods", func(action k8stesting.Action) (bool, runtime.Object, error) {
+		deletions++
+		return deletions == 1, nil, nil
+	})
+	return client
+}
+
+func createLoggerMock() *mocks.Logger {
+	l := &mocks.Logger{}
+	l.On("Info", mock.Anything).Return(nil)
+	l.On("New", mock.Anything, mock.Anything).Return(l)
+	l.On("Warn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	l.On("Error", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(nil)
+	return l
+}
+
+func Test_kube_DeleteResource_policy(t *testing.T) {
+	deletionPollInterval = time.Millisecond
+	defer func() { deletionPollInterval = time.Second }()
+
+	tests := []struct {
+		name    string
+		client  *fake.Clientset
+		policy  DeletionPolicy
+		dryRun  bool
+		opt     DeleteOptions
+		wantErr bool
+		// wantDeleted waits for the pod to be removed by the background wait
+		wantDeleted bool
+		// wantEvent waits for the event recorded by the background wait
+		wantEvent string
+	}{
+		{
+			name:    "should fail on not found by default",
+			client:  fake.NewSimpleClientset(),
+			opt:     DeleteOptions{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"},
+			wantErr: true,
+		},
+		{
+			name:   "should ignore not found",
+			client: fake.NewSimpleClientset(),
+			policy: DeletionPolicy{IgnoreNotFound: true},
+			opt:    DeleteOptions{Kind: task.TypeDeletePVC, Name: "pvc", Namespace: "ns"},
+		},
+		{
+			name:      "should record a timeout waiting for a stuck pod",
+			client:    createStuckPodClientSet(),
+			policy:    DeletionPolicy{WaitTimeout: time.Millisecond * 50},
+			opt:       DeleteOptions{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"},
+			wantEvent: "Warning WorkflowResourceDeleteFailed Failed waiting for dind to be deleted: timed out waiting for Pod ns/dind to be deleted",
+		},
+		{
+			name:        "should force delete a stuck pod",
+			client:      createStuckPodClientSet(),
+			policy:      DeletionPolicy{WaitTimeout: time.Second, ForceAfter: time.Millisecond * 10},
+			opt:         DeleteOptions{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"},
+			wantDeleted: true,
+		},
+		{
+			name:   "should not wait for a dry run deletion",
+			client: createStuckPodClientSet(),
+			policy: DeletionPolicy{WaitTimeout: time.Millisecond * 50},
+			dryRun: true,
+			opt:    DeleteOptions{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			k := kube{
+				client:   tt.client,
+				logger:   createLoggerMock(),
+				deletion: tt.policy,
+				dryRun:   tt.dryRun,
+				recorder: recorder,
+			}
+			err := k.DeleteResource(context.Background(), tt.opt)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			if tt.wantDeleted {
+				assert.Eventually(t, func() bool {
+					_, err := tt.client.CoreV1().Pods(tt.opt.Namespace).Get(context.Background(), tt.opt.Name, metav1.GetOptions{})
+					return apierrors.IsNotFound(err)
+				}, time.Second, time.Millisecond*10)
+			}
+			if tt.wantEvent != "" {
+				select {
+				case event := <-recorder.Events:
+					assert.Equal(t, tt.wantEvent, event)
+				case <-time.After(time.Second):
+					t.Fatal("no event recorded")
+				}
+			}
+		})
+	}
+}
+
+func Test_kube_DeleteResource_doesNotWait(t *testing.T) {
+	k := kube{
+		client:   createStuckPodClientSet(),
+		logger:   createLoggerMock(),
+		deletion: DeletionPolicy{WaitTimeout: time.Second * 2},
+	}
+	start := time.Now()
+	assert.NoError(t, k.DeleteResource(context.Background(), DeleteOptions{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"}))
+	assert.Less(t, int64(time.Since(start)), int64(time.Second))
+}
+
+func TestDeletionPolicy_deleteOptions(t *testing.T) {
+	grace := int64(10)
+	opt := DeletionPolicy{
+		GracePeriodSeconds: &grace,
+		PropagationPolicy:  "Foreground",
+	}.deleteOptions()
+	assert.Equal(t, int64(10), *opt.GracePeriodSeconds)
+	assert.Equal(t, metav1.DeletePropagationForeground, *opt.PropagationPolicy)
+	assert.Nil(t, DeletionPolicy{}.deleteOptions().PropagationPolicy)
+}
@@ -0,0 +1,88 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/kubernetes/scheme"
+	typedcorev1 "k8s.io/client-go/kubernetes/typed/core/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+// eventComponent is the source of the events recorded by the agent
+const eventComponent = "codefresh-runner"
+
+// Reasons of the events recorded on workflow resources
+const (
+	EventReasonCreated      = "WorkflowResourceCreated"
+	EventReasonCreateFailed = "WorkflowResourceCreateFailed"
+	EventReasonDeleted      = "WorkflowResourceDeleted"
+	EventReasonDeleteFailed = "WorkflowResourceDeleteFailed"
+	EventReasonStartFailed  = "WorkflowStartFailed"
+	EventReasonQueued       = "WorkflowQueued"
+	EventReasonRejected     = "WorkflowRejected"
+)
+
+func newEventRecorder(client kubernetes.Interface) record.EventRecorder {
+	broadcaster := record.NewBroadcaster()
+	broadcaster.StartRecordingToSink(&typedcorev1.EventSinkImpl{
+		Interface: client.CoreV1().Events(""),
+	})
+	return broadcaster.NewRecorder(scheme.Scheme, v1.EventSource{
+		Component: eventComponent,
+	})
+}
+
+// EventObject is the object an event is recorded on
+type EventObject struct {
+	// Kind of the object, the event is recorded on the namespace when the kind or the name is not known
+	Kind      string
+	Name      string
+	Namespace string
+}
+
+// WorkflowResource returns the workflow resource to record events on
+func WorkflowResource(opt DeleteOptions) EventObject {
+	return EventObject{Kind: kindNames[opt.Kind], Name: opt.Name, Namespace: opt.Namespace}
+}
+
+// RecordEvent records an event on the object. Does nothing when events are disabled
+func (k kube) RecordEvent(obj EventObject, eventType, reason, message string) {
+	if k.recorder == nil {
+		return
+	}
+	k.recorder.Event(eventReference(obj), eventType, reason, message)
+}
+
+// eventReference is built from the object in hand, the object is not read back from the cluster
+func eventReference(obj EventObject) *v1.ObjectReference {
+	if obj.Kind != "" && obj.Name != "" {
+		return &v1.ObjectReference{
+			APIVersion: "v1",
+			Kind:       obj.Kind,
+			Name:       obj.Name,
+			Namespace:  obj.Namespace,
+		}
+	}
+	// the namespace is cluster scoped, setting the namespace of the reference
+	// keeps the event in the runtime namespace
+	return &v1.ObjectReference{
+		APIVersion: "v1",
+		Kind:       "Namespace",
+		Name:       obj.Namespace,
+		Namespace:  obj.Namespace,
+	}
+}
@@ -0,0 +1,65 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package kubernetes
+
+import (
+	"testing"
+
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/fake"
+)
+
+func Test_eventReference(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  EventObject
+		want *v1.ObjectReference
+	}{
+		{
+			name: "should reference a pod",
+			obj:  WorkflowResource(DeleteOptions{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"}),
+			want: &v1.ObjectReference{APIVersion: "v1", Kind: "Pod", Name: "dind", Namespace: "ns"},
+		},
+		{
+			name: "should reference a pvc",
+			obj:  WorkflowResource(DeleteOptions{Kind: task.TypeDeletePVC, Name: "pvc", Namespace: "ns"}),
+			want: &v1.ObjectReference{APIVersion: "v1", Kind: "PersistentVolumeClaim", Name: "pvc", Namespace: "ns"},
+		},
+		{
+			name: "should reference the namespace of a resource of an unknown kind",
+			obj:  WorkflowResource(DeleteOptions{Kind: "DeleteSecret", Name: "secret", Namespace: "ns"}),
+			want: &v1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: "ns", Namespace: "ns"},
+		},
+		{
+			name: "should reference the namespace of an object without a kind",
+			obj:  EventObject{Namespace: "ns"},
+			want: &v1.ObjectReference{APIVersion: "v1", Kind: "Namespace", Name: "ns", Namespace: "ns"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, eventReference(tt.obj))
+		})
+	}
+}
+
+func Test_kube_RecordEvent_disabled(t *testing.T) {
+	client := fake.NewSimpleClientset()
+	k := kube{client: client}
+	k.RecordEvent(EventObject{Namespace: "ns"}, v1.EventTypeNormal, EventReasonQueued, "msg")
+	assert.Empty(t, client.Actions())
+}
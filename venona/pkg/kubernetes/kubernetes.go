@@ -18,6 +18,7 @@ import (
 	"context"
 	"encoding/json"
 	"errors"
+	"sync"
 
 	"github.com/codefresh-io/go/venona/pkg/logger"
 	"github.com/codefresh-io/go/venona/pkg/task"
@@ -27,6 +28,7 @@ import (
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/kubernetes/scheme"
 	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/record"
 )
 
 var errNotValidType = errors.New("not a valid type")
@@ -37,6 +39,9 @@ type (
 	Kubernetes interface {
 		CreateResource(ctx context.Context, spec interface{}) error
 		DeleteResource(ctx context.Context, opt DeleteOptions) error
+		Capacity(ctx context.Context, opt CapacityOptions) (*Capacity, error)
+		Health(ctx context.Context) error
+		RecordEvent(obj EventObject, eventType, reason, message string)
 	}
 	// Options for Kubernetes
 	Options struct {
@@ -45,6 +50,12 @@ type (
 		Token    string
 		Host     string
 		Insecure bool
+		Deletion DeletionPolicy
+		Logger   logger.Logger
+		// Events records Kubernetes events for workflow resources
+		Events bool
+		// DryRun sends every create and delete as a server side dry run, nothing is changed in the cluster
+		DryRun bool
 	}
 
 	// DeleteOptions to delete resource from the cluster
@@ -54,19 +65,37 @@ type (
 		Kind      string
 	}
 
+	// Clients builds the Kubernetes APIs of clusters that are used more than once,
+	// on the same clientset when they share the host and the credentials
+	Clients struct {
+		mutex      sync.Mutex
+		clientsets map[clientKey]kubernetes.Interface
+	}
+
+	clientKey struct {
+		host     string
+		token    string
+		cert     string
+		insecure bool
+	}
+
 	kube struct {
-		client kubernetes.Interface
-		logger logger.Logger
+		client   kubernetes.Interface
+		logger   logger.Logger
+		deletion DeletionPolicy
+		recorder record.EventRecorder
+		dryRun   bool
 	}
 )
 
-// NewInCluster build Kubernetes API based on local in cluster runtime
-func NewInCluster() (Kubernetes, error) {
+// NewInCluster build Kubernetes API based on local in cluster runtime,
+// connection options are ignored
+func NewInCluster(opt Options) (Kubernetes, error) {
 	client, err := buildKubeInCluster()
-	return &kube{
-		client: client,
-		logger: logger.New(logger.Options{}),
-	}, err
+	if err != nil {
+		return nil, err
+	}
+	return newKube(client, opt), nil
 }
 
 // New build Kubernetes API
@@ -75,10 +104,69 @@ func New(opt Options) (Kubernetes, error) {
 		return nil, errNotValidType
 	}
 	client, err := buildKubeClient(opt.Host, opt.Token, opt.Cert, opt.Insecure)
-	return &kube{
-		client: client,
-		logger: logger.New(logger.Options{}),
-	}, err
+	if err != nil {
+		return nil, err
+	}
+	return newKube(client, opt), nil
+}
+
+// NewClients creates an empty client cache
+func NewClients() *Clients {
+	return &Clients{
+		clientsets: map[clientKey]kubernetes.Interface{},
+	}
+}
+
+// New build Kubernetes API, the clientset of the cluster is reused when it was already built
+func (c *Clients) New(opt Options) (Kubernetes, error) {
+	if opt.Type != "runtime" {
+		return nil, errNotValidType
+	}
+	key := clientKey{
+		host:     opt.Host,
+		token:    opt.Token,
+		cert:     opt.Cert,
+		insecure: opt.Insecure,
+	}
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	client, ok := c.clientsets[key]
+	if !ok {
+		var err error
+		client, err = buildKubeClient(opt.Host, opt.Token, opt.Cert, opt.Insecure)
+		if err != nil {
+			return nil, err
+		}
+		c.clientsets[key] = client
+	}
+	return newKube(client, opt), nil
+}
+
+// NewForClient builds Kubernetes API on top of an existing clientset,
+// used to run the agent against a fake cluster
+func NewForClient(client kubernetes.Interface, opt Options) Kubernetes {
+	return newKube(client, opt)
+}
+
+func newKube(client kubernetes.Interface, opt Options) *kube {
+	k := &kube{
+		client:   client,
+		logger:   buildLogger(opt.Logger),
+		deletion: opt.Deletion,
+		dryRun:   opt.DryRun,
+	}
+	// events of a dry run would describe changes that never happened
+	if opt.Events && !opt.DryRun {
+		k.recorder = newEventRecorder(client)
+	}
+	return k
+}
+
+func buildLogger(l logger.Logger) logger.Logger {
+	if l != nil {
+		return l
+	}
+	return logger.New(logger.Options{})
 }
 
 func (k kube) CreateResource(ctx context.Context, spec interface{}) error {
@@ -93,49 +181,106 @@ func (k kube) CreateResource(ctx context.Context, spec interface{}) error {
 		return err
 	}
 
+	log := logger.FromContext(ctx, k.logger)
+	if k.dryRun {
+		log = log.New("dry-run", true)
+		if rendered, err := json.Marshal(logger.Redact(spec)); err == nil {
+			log.Info("Rendered object", "object", string(rendered))
+		}
+	}
+	opt := metav1.CreateOptions{DryRun: k.dryRunOption()}
 	var namespace string
 	switch obj := obj.(type) {
 	case *v1.PersistentVolumeClaim:
 		namespace = obj.ObjectMeta.Namespace
-		_, err = k.client.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, obj, metav1.CreateOptions{})
+		_, err = k.client.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, obj, opt)
 		if err != nil {
 			return err
 		}
-		k.logger.Info("PersistentVolumeClaim has been created")
+		log.Info("PersistentVolumeClaim has been created")
 
 	case *v1.Pod:
 		namespace = obj.ObjectMeta.Namespace
-		_, err = k.client.CoreV1().Pods(namespace).Create(ctx, obj, metav1.CreateOptions{})
+		_, err = k.client.CoreV1().Pods(namespace).Create(ctx, obj, opt)
 		if err != nil {
 			return err
 		}
-		k.logger.Info("Pod has been created")
+		log.Info("Pod has been created")
 
 	}
 	return err
 }
 
+// DeleteOptionsFromSpec returns the options to delete the object described by the spec
+func DeleteOptionsFromSpec(spec interface{}) (DeleteOptions, error) {
+	opt := DeleteOptions{}
+	bytes, err := json.Marshal(spec)
+	if err != nil {
+		return opt, err
+	}
+	obj, _, err := kubeDecode(bytes, nil, nil)
+	if err != nil {
+		return opt, err
+	}
+	switch obj := obj.(type) {
+	case *v1.PersistentVolumeClaim:
+		opt.Kind = task.TypeDeletePVC
+		opt.Name = obj.ObjectMeta.Name
+		opt.Namespace = obj.ObjectMeta.Namespace
+	case *v1.Pod:
+		opt.Kind = task.TypeDeletePod
+		opt.Name = obj.ObjectMeta.Name
+		opt.Namespace = obj.ObjectMeta.Namespace
+	}
+	return opt, nil
+}
+
 func (k kube) DeleteResource(ctx context.Context, opt DeleteOptions) error {
+	log := logger.FromContext(ctx, k.logger)
+	if k.dryRun {
+		log = log.New("dry-run", true)
+	}
 	switch opt.Kind {
 	case task.TypeDeletePVC:
-		err := k.client.CoreV1().PersistentVolumeClaims(opt.Namespace).Delete(ctx, opt.Name, metav1.DeleteOptions{})
-		if err != nil {
+		if err := k.deletePVC(ctx, opt); err != nil {
 			return err
 		}
-		k.logger.Info("PersistentVolumeClaim has been deleted")
+		log.Info("PersistentVolumeClaim has been deleted")
 
 	case task.TypeDeletePod:
-		err := k.client.CoreV1().Pods(opt.Namespace).Delete(ctx, opt.Name, metav1.DeleteOptions{})
-		if err != nil {
+		if err := k.deletePod(ctx, opt); err != nil {
 			return err
 		}
-		k.logger.Info("Pod has been deleted")
+		log.Info("Pod has been deleted")
 
 	}
 
 	return nil
 }
 
+// dryRunOption returns the DryRun field of create and delete options
+func (k kube) dryRunOption() []string {
+	if !k.dryRun {
+		return nil
+	}
+	return []string{metav1.DryRunAll}
+}
+
+// Health checks that the Kubernetes API server is reachable
+func (k kube) Health(ctx context.Context) error {
+	errc := make(chan error, 1)
+	go func() {
+		_, err := k.client.Discovery().ServerVersion()
+		errc <- err
+	}()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case err := <-errc:
+		return err
+	}
+}
+
 func buildKubeClient(host string, token string, crt string, insecure bool) (kubernetes.Interface, error) {
 	var tlsconf rest.TLSClientConfig
 	if insecure {
@@ -54,3 +54,45 @@ func (_m *MockKubernetes) DeleteResource(ctx context.Context, opt DeleteOptions)
 
 	return r0
 }
+
+// Capacity provides a mock function with given fields: ctx, opt
+func (_m *MockKubernetes) Capacity(ctx context.Context, opt CapacityOptions) (*Capacity, error) {
+	ret := _m.Called(ctx, opt)
+
+	var r0 *Capacity
+	if rf, ok := ret.Get(0).(func(context.Context, CapacityOptions) *Capacity); ok {
+		r0 = rf(ctx, opt)
+	} else {
+		if ret.Get(0) != nil {
+			r0 = ret.Get(0).(*Capacity)
+		}
+	}
+
+	var r1 error
+	if rf, ok := ret.Get(1).(func(context.Context, CapacityOptions) error); ok {
+		r1 = rf(ctx, opt)
+	} else {
+		r1 = ret.Error(1)
+	}
+
+	return r0, r1
+}
+
+// Health provides a mock function with given fields: ctx
+func (_m *MockKubernetes) Health(ctx context.Context) error {
+	ret := _m.Called(ctx)
+
+	var r0 error
+	if rf, ok := ret.Get(0).(func(context.Context) error); ok {
+		r0 = rf(ctx)
+	} else {
+		r0 = ret.Error(0)
+	}
+
+	return r0
+}
+
+// RecordEvent provides a mock function with given fields: obj, eventType, reason, message
+func (_m *MockKubernetes) RecordEvent(obj EventObject, eventType string, reason string, message string) {
+	_m.Called(obj, eventType, reason, message)
+}
@@ -72,6 +72,29 @@ func TestNew(t *testing.T) {
 	}
 }
 
+func TestClients_New(t *testing.T) {
+	clients := NewClients()
+	opt := Options{Type: "runtime", Host: "https://cluster", Token: "token", Deletion: DeletionPolicy{IgnoreNotFound: true}}
+	a, err := clients.New(opt)
+	assert.NoError(t, err)
+	// the same cluster with another deletion policy shares the clientset
+	opt.Deletion = DeletionPolicy{}
+	b, err := clients.New(opt)
+	assert.NoError(t, err)
+	assert.Same(t, a.(*kube).client, b.(*kube).client)
+	assert.True(t, a.(*kube).deletion.IgnoreNotFound)
+	assert.False(t, b.(*kube).deletion.IgnoreNotFound)
+
+	// other credentials get their own clientset
+	opt.Token = "other"
+	c, err := clients.New(opt)
+	assert.NoError(t, err)
+	assert.NotSame(t, a.(*kube).client, c.(*kube).client)
+
+	_, err = clients.New(Options{Type: "secret"})
+	assert.EqualError(t, err, "not a valid type")
+}
+
 func createFakeClientSetForPodOperation(t *testing.T, ns string) kubernetes.Interface {
 	client := fake.NewSimpleClientset()
 	client.Fake.PrependReactor("create", "pods", func(action k8stesting.Action) (handled bool, ret runtime.Object, err error) {
@@ -233,3 +256,10 @@ func Test_kube_DeleteResource(t *testing.T) {
 		})
 	}
 }
+
+func Test_kube_Health(t *testing.T) {
+	k := kube{
+		client: fake.NewSimpleClientset(),
+	}
+	assert.NoError(t, k.Health(context.Background()))
+}
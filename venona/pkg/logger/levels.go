@@ -0,0 +1,111 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"sync"
+
+	log "github.com/inconshreveable/log15"
+)
+
+// DefaultModule is the name used to set the level of modules without a level of their own
+const DefaultModule = "default"
+
+// Levels holds the log level of each module, can be changed while the process is running.
+// The module of a log line is the value of its "module" field
+type Levels struct {
+	mutex   sync.RWMutex
+	def     log.Lvl
+	modules map[string]log.Lvl
+	initial log.Lvl
+}
+
+// NewLevels creates Levels, the default level is set by the logger created with it
+func NewLevels() *Levels {
+	return &Levels{
+		def:     log.LvlInfo,
+		initial: log.LvlInfo,
+		modules: map[string]log.Lvl{},
+	}
+}
+
+// Set changes the level of a module, DefaultModule changes the default level
+func (l *Levels) Set(module string, level string) error {
+	lvl, err := log.LvlFromString(level)
+	if err != nil {
+		return err
+	}
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	if module == DefaultModule || module == "" {
+		l.def = lvl
+		return nil
+	}
+	l.modules[module] = lvl
+	return nil
+}
+
+// Get returns the current level of every module that was set and the default level
+func (l *Levels) Get() map[string]string {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	res := map[string]string{
+		DefaultModule: l.def.String(),
+	}
+	for m, lvl := range l.modules {
+		res[m] = lvl.String()
+	}
+	return res
+}
+
+// Reset restores the level the logger was created with, for all modules
+func (l *Levels) Reset() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.def = l.initial
+	l.modules = map[string]log.Lvl{}
+}
+
+func (l *Levels) setInitial(lvl log.Lvl) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.def = lvl
+	l.initial = lvl
+}
+
+func (l *Levels) handler(h log.Handler) log.Handler {
+	return log.FilterHandler(func(r *log.Record) bool {
+		return r.Lvl <= l.level(r.Ctx)
+	}, h)
+}
+
+func (l *Levels) level(ctx []interface{}) log.Lvl {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	if len(l.modules) != 0 {
+		// the last module field wins, same as a sub logger overriding its parent
+		for i := len(ctx) - 2; i >= 0; i -= 2 {
+			if k, ok := ctx[i].(string); ok && k == "module" {
+				if m, ok := ctx[i+1].(string); ok {
+					if lvl, ok := l.modules[m]; ok {
+						return lvl
+					}
+				}
+				break
+			}
+		}
+	}
+	return l.def
+}
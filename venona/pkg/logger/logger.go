@@ -15,9 +15,31 @@
 package logger
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+
 	log "github.com/inconshreveable/log15"
 )
 
+// Log formats
+const (
+	FormatTerminal = "terminal"
+	FormatJSON     = "json"
+)
+
+// Redacted replaces sensitive values
+const Redacted = "*****"
+
+var (
+	errUnknownFormat = errors.New("unknown log format")
+
+	// keys of values that are never written to the log
+	sensitiveKey = regexp.MustCompile(`(?i)(token|secret|password|license|authorization|cert)`)
+)
+
 type (
 	// Logger interface
 	Logger interface {
@@ -26,8 +48,17 @@ type (
 
 	// Options for logger
 	Options struct {
+		// Verbose sets the level to debug, overrides Level
 		Verbose bool
+		// Level is one of debug, info, warn, error or crit. Defaults to info
+		Level string
+		// Format is one of terminal or json. Defaults to terminal
+		Format string
+		// Levels allows changing the level of each module after the logger was created
+		Levels *Levels
 	}
+
+	ctxKey struct{}
 )
 
 // New creates new logger
@@ -35,11 +66,98 @@ func New(o Options) Logger {
 	l := log.New(log.Ctx{})
 	handlers := []log.Handler{}
 	lvl := log.LvlInfo
+	if parsed, err := log.LvlFromString(o.Level); err == nil {
+		lvl = parsed
+	}
 	if o.Verbose {
 		lvl = log.LvlDebug
 	}
-	verboseHandler := log.LvlFilterHandler(lvl, log.StdoutHandler)
+	out := log.StdoutHandler
+	if o.Format == FormatJSON {
+		out = log.StreamHandler(os.Stdout, log.JsonFormat())
+	}
+	levels := o.Levels
+	if levels == nil {
+		levels = NewLevels()
+	}
+	levels.setInitial(lvl)
+	verboseHandler := levels.handler(redactHandler(out))
 	handlers = append(handlers, verboseHandler)
 	l.SetHandler(log.MultiHandler(handlers...))
 	return l
 }
+
+// Validate returns an error if the options are not valid
+func (o Options) Validate() error {
+	if o.Level != "" {
+		if _, err := log.LvlFromString(o.Level); err != nil {
+			return err
+		}
+	}
+	if o.Format != "" && o.Format != FormatTerminal && o.Format != FormatJSON {
+		return fmt.Errorf("%w: %s", errUnknownFormat, o.Format)
+	}
+	return nil
+}
+
+// WithFields returns a copy of ctx that carries the given correlation fields,
+// the fields are added to every log line written with FromContext
+func WithFields(ctx context.Context, fields ...interface{}) context.Context {
+	existing, _ := ctx.Value(ctxKey{}).([]interface{})
+	all := make([]interface{}, 0, len(existing)+len(fields))
+	all = append(all, existing...)
+	all = append(all, fields...)
+	return context.WithValue(ctx, ctxKey{}, all)
+}
+
+// FromContext returns l with the correlation fields carried by ctx
+func FromContext(ctx context.Context, l Logger) Logger {
+	fields, _ := ctx.Value(ctxKey{}).([]interface{})
+	if len(fields) == 0 {
+		return l
+	}
+	return l.New(fields...)
+}
+
+// IsSensitive returns true for keys whose values must never be written to the log
+func IsSensitive(key string) bool {
+	return sensitiveKey.MatchString(key)
+}
+
+// Redact returns a copy of a decoded JSON value without sensitive values, both keys
+// like "token" and name/value pairs like container env vars are redacted
+func Redact(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(v))
+		name, _ := v["name"].(string)
+		for key, value := range v {
+			if IsSensitive(key) || (key == "value" && IsSensitive(name)) {
+				res[key] = Redacted
+				continue
+			}
+			res[key] = Redact(value)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, 0, len(v))
+		for _, item := range v {
+			res = append(res, Redact(item))
+		}
+		return res
+	default:
+		return v
+	}
+}
+
+// redactHandler replaces the values of sensitive keys before the record is written
+func redactHandler(h log.Handler) log.Handler {
+	return log.FuncHandler(func(r *log.Record) error {
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			if k, ok := r.Ctx[i].(string); ok && IsSensitive(k) {
+				r.Ctx[i+1] = Redacted
+			}
+		}
+		return h.Log(r)
+	})
+}
@@ -0,0 +1,150 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logger
+
+import (
+	"context"
+	"testing"
+
+	log "github.com/inconshreveable/log15"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Options
+		wantErr bool
+	}{
+		{
+			name: "should accept empty options",
+			opt:  Options{},
+		},
+		{
+			name: "should accept json format and warn level",
+			opt:  Options{Format: FormatJSON, Level: "warn"},
+		},
+		{
+			name:    "should reject unknown level",
+			opt:     Options{Level: "loud"},
+			wantErr: true,
+		},
+		{
+			name:    "should reject unknown format",
+			opt:     Options{Format: "xml"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.opt.Validate()
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func Test_redactHandler(t *testing.T) {
+	var got *log.Record
+	l := log.New()
+	l.SetHandler(redactHandler(log.FuncHandler(func(r *log.Record) error {
+		got = r
+		return nil
+	})))
+
+	l.Info("msg", "codefresh-token", "secret-value", "license-key", "abc", "workflow", "1")
+	assert.Equal(t, []interface{}{"codefresh-token", Redacted, "license-key", Redacted, "workflow", "1"}, got.Ctx)
+}
+
+func TestRedact(t *testing.T) {
+	spec := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "dind"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"env": []interface{}{
+						map[string]interface{}{"name": "DOCKER_PASSWORD", "value": "p4ss"},
+						map[string]interface{}{"name": "WORKFLOW_ID", "value": "1"},
+					},
+				},
+			},
+		},
+		"eventReporting": map[string]interface{}{"token": "t0ken"},
+	}
+	want := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "dind"},
+		"spec": map[string]interface{}{
+			"containers": []interface{}{
+				map[string]interface{}{
+					"env": []interface{}{
+						map[string]interface{}{"name": "DOCKER_PASSWORD", "value": Redacted},
+						map[string]interface{}{"name": "WORKFLOW_ID", "value": "1"},
+					},
+				},
+			},
+		},
+		"eventReporting": map[string]interface{}{"token": Redacted},
+	}
+	assert.Equal(t, want, Redact(spec))
+	assert.Equal(t, "t0ken", spec["eventReporting"].(map[string]interface{})["token"], "the original spec must not change")
+}
+
+func TestFromContext(t *testing.T) {
+	var got *log.Record
+	l := log.New()
+	l.SetHandler(log.FuncHandler(func(r *log.Record) error {
+		got = r
+		return nil
+	}))
+
+	ctx := context.Background()
+	assert.Equal(t, l, FromContext(ctx, l))
+
+	ctx = WithFields(ctx, "workflow", "1")
+	ctx = WithFields(ctx, "runtime", "re")
+	FromContext(ctx, l).Info("msg")
+	assert.Equal(t, []interface{}{"workflow", "1", "runtime", "re"}, got.Ctx)
+}
+
+func TestLevels(t *testing.T) {
+	var got []string
+	levels := NewLevels()
+	l := log.New()
+	l.SetHandler(levels.handler(log.FuncHandler(func(r *log.Record) error {
+		got = append(got, r.Msg)
+		return nil
+	})))
+	levels.setInitial(log.LvlInfo)
+	agent := l.New("module", "agent")
+	server := l.New("module", "server")
+
+	agent.Debug("agent-1")
+	assert.NoError(t, levels.Set("agent", "debug"))
+	agent.Debug("agent-2")
+	server.Debug("server-1")
+	assert.Error(t, levels.Set("agent", "loud"))
+	assert.Equal(t, map[string]string{DefaultModule: "info", "agent": "dbug"}, levels.Get())
+
+	assert.NoError(t, levels.Set(DefaultModule, "error"))
+	server.Info("server-2")
+	levels.Reset()
+	agent.Debug("agent-3")
+	server.Info("server-3")
+
+	assert.Equal(t, []string{"agent-2", "server-3"}, got)
+}
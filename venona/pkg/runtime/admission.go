@@ -0,0 +1,347 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/task"
+)
+
+// ErrWorkflowQueued is returned when the runtime has no capacity to start the workflow,
+// the workflow is kept in the runtime queue and started once capacity frees up
+var ErrWorkflowQueued = errors.New("Workflow queued, runtime is out of capacity")
+
+// defaultPriorityAging is the time a queued workflow waits to gain one priority level
+const defaultPriorityAging = time.Minute
+
+type (
+	// AdmissionOptions defines the budget of the runtime,
+	// zero value of a field means there is no limit
+	AdmissionOptions struct {
+		// Namespace and LabelSelector select the workflow pods that are counted
+		Namespace          string
+		LabelSelector      string
+		MaxConcurrentPods  int
+		MaxPendingPods     int
+		CheckFreeResources bool
+		// Quotas are checked before the capacity, a workflow over one of them is queued
+		// or rejected without delaying the workflows of other accounts
+		Quotas []Quota
+		// PriorityAging raises the priority of a queued workflow by one every interval it waits,
+		// so low priority workflows are not starved. Defaults to a minute
+		PriorityAging time.Duration
+	}
+
+	admission struct {
+		opt   AdmissionOptions
+		queue []queuedWorkflow
+		// starting is the number of pods of the admitted workflows that are being created and
+		// startingResources what they request, they are not counted by the capacity of the cluster yet
+		starting          int
+		startingResources kubernetes.Resources
+		now               func() time.Time
+		// processing serializes the queue processing of the runtime, starting workflows
+		// and reading the queue do not wait for it
+		processing sync.Mutex
+		*quotaState
+	}
+
+	// quotaState is the quota state of a runtime, the members of a pool share it so the quotas
+	// hold across all of their clusters. The mutex also guards the queues of the members,
+	// it is only held while the state changes and never across calls to the cluster
+	quotaState struct {
+		mutex  sync.Mutex
+		quotas []Quota
+		// running keeps the usage of the started workflows, by workflow id
+		running  map[string]workflowUsage
+		rejected map[string]bool
+	}
+
+	queuedWorkflow struct {
+		tasks    []task.Task
+		priority int
+		since    time.Time
+	}
+)
+
+func newAdmission(opt *AdmissionOptions) *admission {
+	if opt == nil {
+		return nil
+	}
+	return newSharedAdmission(opt, newQuotaState(opt.Quotas))
+}
+
+// newSharedAdmission creates the admission of a pool member, quotas are those of the pool
+func newSharedAdmission(opt *AdmissionOptions, quotas *quotaState) *admission {
+	if opt.PriorityAging <= 0 {
+		opt.PriorityAging = defaultPriorityAging
+	}
+	return &admission{
+		opt:        *opt,
+		queue:      []queuedWorkflow{},
+		now:        time.Now,
+		quotaState: quotas,
+	}
+}
+
+func newQuotaState(quotas []Quota) *quotaState {
+	return &quotaState{
+		quotas:   quotas,
+		running:  map[string]workflowUsage{},
+		rejected: map[string]bool{},
+	}
+}
+
+// admit checks if there is capacity to start the given workflow tasks, when there is the pods
+// of the workflow are counted as starting until started is called.
+// Must be called with the mutex unlocked, the capacity is read from the cluster
+func (a *admission) admit(ctx context.Context, client kubernetes.Kubernetes, tasks []task.Task) (bool, error) {
+	c, err := client.Capacity(ctx, kubernetes.CapacityOptions{
+		Namespace:     a.opt.Namespace,
+		LabelSelector: a.opt.LabelSelector,
+		Resources:     a.opt.CheckFreeResources,
+	})
+	if err != nil {
+		return false, err
+	}
+
+	specs := podSpecs(tasks)
+	requested, err := a.requested(specs)
+	if err != nil {
+		return false, err
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	if a.opt.MaxConcurrentPods > 0 && c.Pods+a.starting+len(specs) > a.opt.MaxConcurrentPods {
+		return false, nil
+	}
+	if a.opt.MaxPendingPods > 0 && c.PendingPods+a.starting >= a.opt.MaxPendingPods {
+		return false, nil
+	}
+	if a.opt.CheckFreeResources {
+		cpu, memory := requested.CPU.DeepCopy(), requested.Memory.DeepCopy()
+		cpu.Add(a.startingResources.CPU)
+		memory.Add(a.startingResources.Memory)
+		if cpu.Cmp(c.FreeCPU) > 0 || memory.Cmp(c.FreeMemory) > 0 {
+			return false, nil
+		}
+	}
+	a.starting += len(specs)
+	a.startingResources.CPU.Add(requested.CPU)
+	a.startingResources.Memory.Add(requested.Memory)
+	return true, nil
+}
+
+// started ends the start of an admitted workflow, the quotas it took are released when
+// its resources were not created
+func (a *admission) started(tasks []task.Task, created bool) {
+	specs := podSpecs(tasks)
+	// admit already read the requests of the pods
+	requested, _ := a.requested(specs)
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.starting -= len(specs)
+	a.startingResources.CPU.Sub(requested.CPU)
+	a.startingResources.Memory.Sub(requested.Memory)
+	if !created {
+		delete(a.running, tasks[0].Metadata.Workflow)
+	}
+}
+
+// requested returns the resources requested by the pods, only when they are checked
+func (a *admission) requested(specs []interface{}) (kubernetes.Resources, error) {
+	if !a.opt.CheckFreeResources {
+		return kubernetes.Resources{}, nil
+	}
+	return kubernetes.RequestedResources(specs...)
+}
+
+func podSpecs(tasks []task.Task) []interface{} {
+	specs := []interface{}{}
+	for _, t := range tasks {
+		if t.Type == task.TypeCreatePod {
+			specs = append(specs, t.Spec)
+		}
+	}
+	return specs
+}
+
+// take counts the workflow against the quotas, must be called with the mutex locked
+func (a *admission) take(workflow string, u workflowUsage) {
+	if workflow != "" {
+		a.running[workflow] = u
+	}
+}
+
+// withinQuotas checks the workflow against the quotas, returns the first quota it exceeds
+// and whether the workflow must be rejected. Must be called with the mutex locked
+func (a *admission) withinQuotas(u workflowUsage) (*Quota, bool) {
+	var exceeded *Quota
+	for i := range a.quotas {
+		q := &a.quotas[i]
+		if !q.matches(u) {
+			continue
+		}
+		// the workflow would never fit, waiting does not help
+		if q.exceeded(1, u.resources) {
+			return q, true
+		}
+		workflows := 1
+		used := kubernetes.Resources{CPU: u.resources.CPU.DeepCopy(), Memory: u.resources.Memory.DeepCopy()}
+		for _, r := range a.running {
+			if q.shares(u, r) {
+				workflows++
+				used.CPU.Add(r.resources.CPU)
+				used.Memory.Add(r.resources.Memory)
+			}
+		}
+		if !q.exceeded(workflows, used) {
+			continue
+		}
+		if q.Reject {
+			return q, true
+		}
+		if exceeded == nil {
+			exceeded = q
+		}
+	}
+	return exceeded, false
+}
+
+// waitingForCapacity returns true when a queued workflow is within its quotas, a workflow
+// over its quota must not hold back the workflows of other accounts.
+// Must be called with the mutex locked
+func (a *admission) waitingForCapacity() bool {
+	for _, wf := range a.queue {
+		u, err := newWorkflowUsage(wf.tasks)
+		if err != nil {
+			return true
+		}
+		if q, _ := a.withinQuotas(u); q == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// release frees the quotas taken by the workflow and forgets it was rejected
+func (s *quotaState) release(workflow string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.running, workflow)
+	delete(s.rejected, workflow)
+}
+
+func (s *quotaState) rejectedWorkflows() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := make([]string, 0, len(s.rejected))
+	for wf := range s.rejected {
+		res = append(res, wf)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// restore takes the quotas of the workflows that were running when the agent restarted
+// from their placement records, records without the account of the workflow are skipped.
+// Records without an agent were saved before agents were recorded and belong to any of them
+func (s *quotaState) restore(placements PlacementStore, runtime string, agent string) error {
+	records, err := placements.List()
+	if err != nil {
+		return err
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, p := range records {
+		if p.Runtime != runtime || p.Account == "" || (p.Agent != "" && p.Agent != agent) {
+			continue
+		}
+		s.running[p.Workflow] = p.usage()
+	}
+	return nil
+}
+
+// dequeue removes the workflow from the queue, returns false if it is not queued
+func (a *admission) dequeue(workflow string) bool {
+	if workflow == "" {
+		return false
+	}
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	return a.remove(workflow)
+}
+
+func (a *admission) workflows() []string {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	res := make([]string, 0, len(a.queue))
+	for _, wf := range a.queue {
+		res = append(res, wf.tasks[0].Metadata.Workflow)
+	}
+	return res
+}
+
+// push adds the workflow to the queue and returns the length of the queue,
+// must be called with the mutex locked
+func (a *admission) push(tasks []task.Task) int {
+	a.queue = append(a.queue, queuedWorkflow{
+		tasks:    tasks,
+		priority: task.Priority(tasks, nil),
+		since:    a.now(),
+	})
+	return len(a.queue)
+}
+
+// remove removes the workflow from the queue, returns false if it is not queued.
+// Must be called with the mutex locked
+func (a *admission) remove(workflow string) bool {
+	for i, wf := range a.queue {
+		if wf.tasks[0].Metadata.Workflow == workflow {
+			a.queue = append(a.queue[:i], a.queue[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// isQueued returns true when the workflow is in the queue, must be called with the mutex locked
+func (a *admission) isQueued(workflow string) bool {
+	for _, wf := range a.queue {
+		if wf.tasks[0].Metadata.Workflow == workflow {
+			return true
+		}
+	}
+	return false
+}
+
+// sortQueue orders the queue by priority, aged by the time each workflow waits,
+// workflows of the same priority keep their order. Must be called with the mutex locked
+func (a *admission) sortQueue() {
+	now := a.now()
+	effective := func(wf queuedWorkflow) int {
+		return wf.priority + int(now.Sub(wf.since)/a.opt.PriorityAging)
+	}
+	sort.SliceStable(a.queue, func(i, j int) bool {
+		return effective(a.queue[i]) > effective(a.queue[j])
+	})
+}
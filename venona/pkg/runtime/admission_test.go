@@ -0,0 +1,236 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func createCapacityMock(c *kubernetes.Capacity) *kubernetes.MockKubernetes {
+	m := createKubernetesMock()
+	m.On("Capacity", mock.Anything, mock.Anything).Return(c, nil)
+	return m
+}
+
+func podTask(workflow string, cpu string) task.Task {
+	return task.Task{
+		Type: task.TypeCreatePod,
+		Spec: map[string]interface{}{
+			"kind":       "Pod",
+			"apiVersion": "v1",
+			"metadata": map[string]interface{}{
+				"name": "dind",
+			},
+			"spec": map[string]interface{}{
+				"containers": []interface{}{
+					map[string]interface{}{
+						"name": "dind",
+						"resources": map[string]interface{}{
+							"requests": map[string]interface{}{
+								"cpu": cpu,
+							},
+						},
+					},
+				},
+			},
+		},
+		Metadata: task.Metadata{
+			Workflow: workflow,
+		},
+	}
+}
+
+func Test_admission_admit(t *testing.T) {
+	tests := []struct {
+		name     string
+		opt      AdmissionOptions
+		capacity kubernetes.Capacity
+		want     bool
+	}{
+		{
+			name:     "should admit when there is no limit",
+			opt:      AdmissionOptions{},
+			capacity: kubernetes.Capacity{Pods: 100, PendingPods: 100},
+			want:     true,
+		},
+		{
+			name:     "should admit when below max concurrent pods",
+			opt:      AdmissionOptions{MaxConcurrentPods: 2},
+			capacity: kubernetes.Capacity{Pods: 1},
+			want:     true,
+		},
+		{
+			name:     "should not admit when max concurrent pods is reached",
+			opt:      AdmissionOptions{MaxConcurrentPods: 2},
+			capacity: kubernetes.Capacity{Pods: 2},
+			want:     false,
+		},
+		{
+			name:     "should not admit when max pending pods is reached",
+			opt:      AdmissionOptions{MaxPendingPods: 1},
+			capacity: kubernetes.Capacity{Pods: 1, PendingPods: 1},
+			want:     false,
+		},
+		{
+			name:     "should admit when requested resources fit",
+			opt:      AdmissionOptions{CheckFreeResources: true},
+			capacity: kubernetes.Capacity{FreeCPU: resource.MustParse("2"), FreeMemory: resource.MustParse("1Gi")},
+			want:     true,
+		},
+		{
+			name:     "should not admit when requested resources do not fit",
+			opt:      AdmissionOptions{CheckFreeResources: true},
+			capacity: kubernetes.Capacity{FreeCPU: resource.MustParse("500m"), FreeMemory: resource.MustParse("1Gi")},
+			want:     false,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmission(&tt.opt)
+			c := tt.capacity
+			got, err := a.admit(context.Background(), createCapacityMock(&c), []task.Task{podTask("1", "1")})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func Test_runtime_StartWorkflow_queue(t *testing.T) {
+	c := &kubernetes.Capacity{Pods: 1}
+	m := createCapacityMock(c)
+	r := New(Options{
+		Kubernetes: m,
+		Admission: &AdmissionOptions{
+			MaxConcurrentPods: 1,
+		},
+	})
+	ctx := context.Background()
+
+	err := r.StartWorkflow(ctx, []task.Task{podTask("1", "1")})
+	assert.Equal(t, ErrWorkflowQueued, err)
+	err = r.StartWorkflow(ctx, []task.Task{podTask("2", "1")})
+	assert.Equal(t, ErrWorkflowQueued, err)
+	assert.Equal(t, []string{"1", "2"}, r.QueuedWorkflows())
+	m.AssertNotCalled(t, "CreateResource", mock.Anything, mock.Anything)
+
+	// still no capacity
+	assert.Empty(t, r.ProcessQueue(ctx))
+	assert.Equal(t, []string{"1", "2"}, r.QueuedWorkflows())
+
+	// terminating a queued workflow removes it without deleting anything
+	assert.Empty(t, r.TerminateWorkflow(ctx, []task.Task{{Type: task.TypeDeletePod, Metadata: task.Metadata{Workflow: "2"}}}))
+	assert.Equal(t, []string{"1"}, r.QueuedWorkflows())
+	m.AssertNotCalled(t, "DeleteResource", mock.Anything, mock.Anything)
+
+	c.Pods = 0
+	assert.Empty(t, r.ProcessQueue(ctx))
+	assert.Empty(t, r.QueuedWorkflows())
+	m.AssertNumberOfCalls(t, "CreateResource", 1)
+}
+
+func Test_admission_sortQueue(t *testing.T) {
+	now := time.Now()
+	a := newAdmission(&AdmissionOptions{PriorityAging: time.Minute})
+	a.now = func() time.Time { return now }
+	low := podTask("low", "1")
+	high := podTask("high", "1")
+	high.Metadata.Priority = 2
+	same := podTask("same", "1")
+	a.push([]task.Task{low})
+	a.push([]task.Task{high})
+	a.push([]task.Task{same})
+
+	a.sortQueue()
+	assert.Equal(t, []string{"high", "low", "same"}, a.workflows())
+
+	// the low priority workflow waited long enough to catch up
+	a.queue[1].since = now.Add(-3 * time.Minute)
+	a.sortQueue()
+	assert.Equal(t, []string{"low", "high", "same"}, a.workflows())
+}
+
+func Test_runtime_StartWorkflow_unlockedClusterCalls(t *testing.T) {
+	creating := make(chan struct{})
+	created := make(chan struct{})
+	m := &kubernetes.MockKubernetes{}
+	m.On("Capacity", mock.Anything, mock.Anything).Return(&kubernetes.Capacity{}, nil)
+	m.On("CreateResource", mock.Anything, mock.Anything).Run(func(mock.Arguments) {
+		close(creating)
+		<-created
+	}).Return(nil).Once()
+	m.On("CreateResource", mock.Anything, mock.Anything).Return(nil)
+	m.On("RecordEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	r := New(Options{
+		Kubernetes: m,
+		Admission: &AdmissionOptions{
+			MaxConcurrentPods: 1,
+			Quotas:            []Quota{{Account: "a", MaxWorkflows: 1}},
+		},
+	})
+	ctx := context.Background()
+
+	started := make(chan error)
+	go func() { started <- r.StartWorkflow(ctx, []task.Task{accountTask("1", "a", "1", nil)}) }()
+	<-creating
+
+	// the state is available while the resources of the workflow are created
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		assert.Empty(t, r.QueuedWorkflows())
+		assert.Empty(t, r.RejectedWorkflows())
+		// the starting workflow holds its quota and its pod counts against the capacity
+		assert.Equal(t, ErrWorkflowQueued, r.StartWorkflow(ctx, []task.Task{accountTask("2", "a", "1", nil)}))
+		assert.Equal(t, ErrWorkflowQueued, r.StartWorkflow(ctx, []task.Task{accountTask("3", "b", "1", nil)}))
+		assert.Equal(t, []string{"2", "3"}, r.QueuedWorkflows())
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the admission is locked while the resources are created")
+	}
+
+	close(created)
+	assert.NoError(t, <-started)
+	m.AssertNumberOfCalls(t, "CreateResource", 1)
+}
+
+func Test_runtime_StartWorkflow_createFailedReleasesQuota(t *testing.T) {
+	m := &kubernetes.MockKubernetes{}
+	m.On("Capacity", mock.Anything, mock.Anything).Return(&kubernetes.Capacity{}, nil)
+	m.On("CreateResource", mock.Anything, mock.Anything).Return(errors.New("forbidden")).Once()
+	m.On("CreateResource", mock.Anything, mock.Anything).Return(nil)
+	m.On("RecordEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	r := New(Options{
+		Kubernetes: m,
+		Admission: &AdmissionOptions{
+			MaxConcurrentPods: 1,
+			Quotas:            []Quota{{Account: "a", MaxWorkflows: 1}},
+		},
+	})
+	ctx := context.Background()
+
+	assert.Error(t, r.StartWorkflow(ctx, []task.Task{accountTask("1", "a", "1", nil)}))
+	assert.NoError(t, r.StartWorkflow(ctx, []task.Task{accountTask("2", "a", "1", nil)}))
+}
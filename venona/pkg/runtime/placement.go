@@ -0,0 +1,192 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+)
+
+type (
+	// Placement records where a workflow was started and what was created for it
+	Placement struct {
+		Workflow  string                     `json:"workflow"`
+		Runtime   string                     `json:"runtime"`
+		Cluster   string                     `json:"cluster"`
+		Agent     string                     `json:"agent,omitempty"`
+		Namespace string                     `json:"namespace"`
+		Objects   []kubernetes.DeleteOptions `json:"objects"`
+		CreatedAt time.Time                  `json:"createdAt"`
+		// Account, Labels and Resources of the workflow restore its quotas after a restart
+		Account   string                `json:"account,omitempty"`
+		Labels    []map[string]string   `json:"labels,omitempty"`
+		Resources *kubernetes.Resources `json:"resources,omitempty"`
+	}
+
+	// PlacementStore keeps the placement records of the running workflows
+	PlacementStore interface {
+		Get(workflow string) (*Placement, error)
+		Save(p *Placement) error
+		Delete(workflow string) error
+		// List returns the records of all the workflows
+		List() ([]*Placement, error)
+	}
+
+	memoryPlacementStore struct {
+		mutex      sync.Mutex
+		placements map[string]Placement
+	}
+
+	filePlacementStore struct {
+		mutex sync.Mutex
+		dir   string
+	}
+)
+
+// NewMemoryPlacementStore creates a PlacementStore that is lost when the agent restarts
+func NewMemoryPlacementStore() PlacementStore {
+	return &memoryPlacementStore{
+		placements: map[string]Placement{},
+	}
+}
+
+// NewFilePlacementStore creates a PlacementStore that keeps a json file per workflow in dir
+func NewFilePlacementStore(dir string) (PlacementStore, error) {
+	if err := os.MkdirAll(dir, 0750); err != nil {
+		return nil, err
+	}
+	return &filePlacementStore{
+		dir: dir,
+	}, nil
+}
+
+func (s *memoryPlacementStore) Get(workflow string) (*Placement, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	p, ok := s.placements[workflow]
+	if !ok {
+		return nil, nil
+	}
+	return &p, nil
+}
+
+func (s *memoryPlacementStore) Save(p *Placement) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.placements[p.Workflow] = *p
+	return nil
+}
+
+func (s *memoryPlacementStore) Delete(workflow string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.placements, workflow)
+	return nil
+}
+
+func (s *memoryPlacementStore) List() ([]*Placement, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	res := make([]*Placement, 0, len(s.placements))
+	for _, p := range s.placements {
+		p := p
+		res = append(res, &p)
+	}
+	return res, nil
+}
+
+func (s *filePlacementStore) Get(workflow string) (*Placement, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, err := ioutil.ReadFile(s.path(workflow))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	p := &Placement{}
+	if err := json.Unmarshal(data, p); err != nil {
+		return nil, err
+	}
+	return p, nil
+}
+
+func (s *filePlacementStore) Save(p *Placement) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	data, err := json.Marshal(p)
+	if err != nil {
+		return err
+	}
+	// write and rename so a crash never leaves a partial record behind
+	tmp := s.path(p.Workflow) + ".tmp"
+	if err := ioutil.WriteFile(tmp, data, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.path(p.Workflow))
+}
+
+func (s *filePlacementStore) Delete(workflow string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	err := os.Remove(s.path(workflow))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (s *filePlacementStore) List() ([]*Placement, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	res := make([]*Placement, 0, len(files))
+	for _, f := range files {
+		data, err := ioutil.ReadFile(f)
+		if err != nil {
+			return nil, err
+		}
+		p := &Placement{}
+		if err := json.Unmarshal(data, p); err != nil {
+			return nil, fmt.Errorf("failed to read placement %s: %w", f, err)
+		}
+		res = append(res, p)
+	}
+	return res, nil
+}
+
+// usage returns what the workflow takes from the quotas
+func (p *Placement) usage() workflowUsage {
+	u := workflowUsage{account: p.Account, labels: p.Labels}
+	if p.Resources != nil {
+		u.resources = *p.Resources
+	}
+	return u
+}
+
+func (s *filePlacementStore) path(workflow string) string {
+	return filepath.Join(s.dir, filepath.Base(workflow)+".json")
+}
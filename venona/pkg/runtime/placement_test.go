@@ -0,0 +1,137 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func Test_filePlacementStore(t *testing.T) {
+	dir, err := ioutil.TempDir("", "placements")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	s, err := NewFilePlacementStore(dir)
+	assert.NoError(t, err)
+
+	p, err := s.Get("1")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+
+	assert.NoError(t, s.Save(&Placement{
+		Workflow: "1",
+		Runtime:  "re",
+		Objects: []kubernetes.DeleteOptions{
+			{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"},
+		},
+	}))
+	p, err = s.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "re", p.Runtime)
+	assert.Len(t, p.Objects, 1)
+
+	all, err := s.List()
+	assert.NoError(t, err)
+	assert.Equal(t, []*Placement{p}, all)
+
+	assert.NoError(t, s.Delete("1"))
+	assert.NoError(t, s.Delete("1"))
+	p, err = s.Get("1")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func Test_runtime_TerminateWorkflow_placement(t *testing.T) {
+	m := createKubernetesMock()
+	store := NewMemoryPlacementStore()
+	r := New(Options{
+		Kubernetes: m,
+		Name:       "re",
+		Cluster:    "cluster",
+		Placements: store,
+	})
+	ctx := context.Background()
+
+	pvc := task.Task{
+		Type: task.TypeCreatePVC,
+		Spec: map[string]interface{}{
+			"kind":       "PersistentVolumeClaim",
+			"apiVersion": "v1",
+			"metadata": map[string]interface{}{
+				"name":      "pvc",
+				"namespace": "ns",
+			},
+		},
+		Metadata: task.Metadata{Workflow: "1"},
+	}
+	assert.NoError(t, r.StartWorkflow(ctx, []task.Task{pvc, podTask("1", "1")}))
+
+	p, err := store.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "cluster", p.Cluster)
+	assert.Equal(t, "ns", p.Namespace)
+	assert.Len(t, p.Objects, 2)
+
+	// only the pod is named by the deletion tasks, the pvc is deleted from the placement record
+	errs := r.TerminateWorkflow(ctx, []task.Task{
+		{
+			Type:     task.TypeDeletePod,
+			Spec:     map[string]interface{}{"name": "dind"},
+			Metadata: task.Metadata{Workflow: "1"},
+		},
+	})
+	assert.Empty(t, errs)
+	m.AssertCalled(t, "DeleteResource", ctx, kubernetes.DeleteOptions{Kind: task.TypeDeletePVC, Name: "pvc", Namespace: "ns"})
+	m.AssertNumberOfCalls(t, "DeleteResource", 2)
+
+	p, err = store.Get("1")
+	assert.NoError(t, err)
+	assert.Nil(t, p)
+}
+
+func Test_runtime_TerminateWorkflow_placementFailedDelete(t *testing.T) {
+	pvc := kubernetes.DeleteOptions{Kind: task.TypeDeletePVC, Name: "pvc", Namespace: "ns"}
+	pod := kubernetes.DeleteOptions{Kind: task.TypeDeletePod, Name: "dind", Namespace: "ns"}
+	m := &kubernetes.MockKubernetes{}
+	m.On("DeleteResource", mock.Anything, pvc).Return(errors.New("forbidden"))
+	m.On("DeleteResource", mock.Anything, pod).Return(nil)
+	m.On("RecordEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	store := NewMemoryPlacementStore()
+	assert.NoError(t, store.Save(&Placement{Workflow: "1", Namespace: "ns", Objects: []kubernetes.DeleteOptions{pvc, pod}}))
+	r := New(Options{Kubernetes: m, Placements: store})
+
+	errs := r.TerminateWorkflow(context.Background(), []task.Task{
+		{
+			Type:     task.TypeDeletePod,
+			Spec:     map[string]interface{}{"name": "dind", "namespace": "ns"},
+			Metadata: task.Metadata{Workflow: "1"},
+		},
+	})
+	assert.Len(t, errs, 1)
+
+	// the record keeps only the object that failed so termination can retry
+	p, err := store.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []kubernetes.DeleteOptions{pvc}, p.Objects)
+}
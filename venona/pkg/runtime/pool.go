@@ -0,0 +1,361 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/task"
+)
+
+// ErrNoHealthyMember is returned when none of the pool clusters is healthy
+var ErrNoHealthyMember = errors.New("No healthy cluster in runtime pool")
+
+const (
+	defaultHealthCheckInterval = time.Second * 10
+	defaultHealthCheckTimeout  = time.Second * 5
+)
+
+type (
+	// PoolOptions to create a runtime backed by multiple clusters
+	PoolOptions struct {
+		Name    string
+		Members []PoolMember
+		// Placements is used to send the termination to the cluster that ran the workflow,
+		// an in-memory store is used when not set
+		Placements PlacementStore
+		// Agent serving the pool, stored in the placement records
+		Agent string
+		// HealthCheckInterval is the time a health check result is trusted, and the interval
+		// of the background checks when the health is monitored
+		HealthCheckInterval time.Duration
+	}
+
+	// PoolMember is a single cluster of the pool
+	PoolMember struct {
+		Name       string
+		Kubernetes kubernetes.Kubernetes
+		// Admission limits the capacity of the member, the quotas of all the members
+		// are enforced across the whole pool
+		Admission *AdmissionOptions
+		// Priority orders the members, lower is preferred.
+		// Ignored when any of the members has a weight
+		Priority int
+		// Weight routes workflows to healthy members proportionally
+		Weight int
+	}
+
+	pool struct {
+		name    string
+		agent   string
+		members []*poolMember
+		// quotas is shared by the members, nil when none of them has admission
+		quotas              *quotaState
+		weighted            bool
+		healthCheckInterval time.Duration
+		mutex               sync.Mutex
+		placements          PlacementStore
+		// monitored is set once the background checks ran, the members are no longer probed
+		// when a workflow is started
+		monitored bool
+	}
+
+	poolMember struct {
+		PoolMember
+		runtime   Runtime
+		healthy   bool
+		checkedAt time.Time
+	}
+)
+
+// NewPool creates a Runtime that routes workflows to healthy members of the pool
+func NewPool(opt PoolOptions) Runtime {
+	p := &pool{
+		name:                opt.Name,
+		agent:               opt.Agent,
+		members:             make([]*poolMember, 0, len(opt.Members)),
+		healthCheckInterval: opt.HealthCheckInterval,
+		placements:          opt.Placements,
+	}
+	if p.placements == nil {
+		p.placements = NewMemoryPlacementStore()
+	}
+	if p.healthCheckInterval == time.Duration(0) {
+		p.healthCheckInterval = defaultHealthCheckInterval
+	}
+	quotas := []Quota{}
+	admitted := false
+	for _, m := range opt.Members {
+		if m.Admission != nil {
+			admitted = true
+			quotas = append(quotas, m.Admission.Quotas...)
+		}
+	}
+	if admitted {
+		p.quotas = newQuotaState(quotas)
+	}
+	for _, m := range opt.Members {
+		if m.Weight > 0 {
+			p.weighted = true
+		}
+		r := &runtime{
+			client:     m.Kubernetes,
+			name:       opt.Name,
+			cluster:    m.Name,
+			agent:      opt.Agent,
+			placements: p.placements,
+		}
+		if p.quotas != nil {
+			// a member without admission has no capacity limits but still takes from the quotas
+			admission := AdmissionOptions{}
+			if m.Admission != nil {
+				admission = *m.Admission
+			}
+			r.admission = newSharedAdmission(&admission, p.quotas)
+		}
+		p.members = append(p.members, &poolMember{
+			PoolMember: m,
+			runtime:    r,
+		})
+	}
+	sort.SliceStable(p.members, func(i, j int) bool {
+		return p.members[i].Priority < p.members[j].Priority
+	})
+	return p
+}
+
+func (p *pool) StartWorkflow(ctx context.Context, tasks []task.Task) error {
+	m := p.pick(ctx)
+	if m == nil {
+		return ErrNoHealthyMember
+	}
+	return m.runtime.StartWorkflow(ctx, tasks)
+}
+
+func (p *pool) TerminateWorkflow(ctx context.Context, tasks []task.Task) []error {
+	m, err := p.placement(tasks)
+	if err != nil {
+		return []error{err}
+	}
+	if m != nil {
+		return m.runtime.TerminateWorkflow(ctx, tasks)
+	}
+
+	// the workflow is unknown (e.g. still queued or the placement was lost), send the deletion
+	// to every member and consider it done when one of them succeeded
+	var errs []error
+	for _, m := range p.members {
+		memberErrs := m.runtime.TerminateWorkflow(ctx, tasks)
+		if len(memberErrs) == 0 {
+			return []error{}
+		}
+		if errs == nil {
+			errs = memberErrs
+		}
+	}
+	return errs
+}
+
+func (p *pool) ProcessQueue(ctx context.Context) []error {
+	errs := []error{}
+	for _, m := range p.members {
+		errs = append(errs, m.runtime.ProcessQueue(ctx)...)
+	}
+	return errs
+}
+
+func (p *pool) QueuedWorkflows() []string {
+	res := []string{}
+	for _, m := range p.members {
+		res = append(res, m.runtime.QueuedWorkflows()...)
+	}
+	return res
+}
+
+func (p *pool) RejectedWorkflows() []string {
+	if p.quotas == nil {
+		return []string{}
+	}
+	return p.quotas.rejectedWorkflows()
+}
+
+// MonitorHealth checks the members of a pool in the background until the context is done,
+// workflows queued on a member that is not healthy are moved to the healthy ones.
+// Returns right away for a runtime that is not a pool
+func MonitorHealth(ctx context.Context, re Runtime) {
+	p, ok := re.(*pool)
+	if !ok {
+		return
+	}
+	ticker := time.NewTicker(p.healthCheckInterval)
+	defer ticker.Stop()
+	for {
+		p.checkHealth(ctx)
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// checkHealth probes all the members and re-places the queues of those that are not healthy
+func (p *pool) checkHealth(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, m := range p.members {
+		wg.Add(1)
+		go func(m *poolMember) {
+			defer wg.Done()
+			p.probe(ctx, m)
+		}(m)
+	}
+	wg.Wait()
+
+	p.mutex.Lock()
+	p.monitored = true
+	p.mutex.Unlock()
+	p.replaceQueued(ctx)
+}
+
+// replaceQueued moves the workflows queued on members that are not healthy to the healthy ones,
+// they stay where they are when no member is healthy
+func (p *pool) replaceQueued(ctx context.Context) {
+	if p.quotas == nil {
+		return
+	}
+	healthy := []*poolMember{}
+	unhealthy := []*poolMember{}
+	for _, m := range p.members {
+		if p.isHealthy(ctx, m) {
+			healthy = append(healthy, m)
+		} else {
+			unhealthy = append(unhealthy, m)
+		}
+	}
+	if len(healthy) == 0 {
+		return
+	}
+
+	// the queues of all the members are guarded by the mutex of the shared quotas, the workflows
+	// are moved at once so they are never missing from the queued workflows
+	p.quotas.mutex.Lock()
+	defer p.quotas.mutex.Unlock()
+	for _, m := range unhealthy {
+		from := m.admission()
+		kept := []queuedWorkflow{}
+		for _, wf := range from.queue {
+			to := p.choose(healthy)
+			if to == nil {
+				// none of the healthy members has a weight
+				kept = append(kept, wf)
+				continue
+			}
+			to.admission().queue = append(to.admission().queue, wf)
+		}
+		from.queue = kept
+	}
+}
+
+// pick returns the member to run the next workflow on, nil if none is healthy
+func (p *pool) pick(ctx context.Context) *poolMember {
+	healthy := []*poolMember{}
+	for _, m := range p.members {
+		if p.isHealthy(ctx, m) {
+			if !p.weighted {
+				return m
+			}
+			healthy = append(healthy, m)
+		}
+	}
+	return p.choose(healthy)
+}
+
+// choose returns the member to run the next workflow on among the healthy ones,
+// nil if none of them can take it
+func (p *pool) choose(healthy []*poolMember) *poolMember {
+	if !p.weighted {
+		if len(healthy) == 0 {
+			return nil
+		}
+		return healthy[0]
+	}
+	total := 0
+	for _, m := range healthy {
+		total += m.Weight
+	}
+	if total == 0 {
+		return nil
+	}
+	// #nosec
+	n := rand.Intn(total)
+	for _, m := range healthy {
+		if n < m.Weight {
+			return m
+		}
+		n -= m.Weight
+	}
+	return healthy[len(healthy)-1]
+}
+
+// isHealthy returns the last known health of the member, it is probed when the health is not
+// monitored and the last result is too old
+func (p *pool) isHealthy(ctx context.Context, m *poolMember) bool {
+	p.mutex.Lock()
+	if p.monitored || time.Since(m.checkedAt) < p.healthCheckInterval {
+		defer p.mutex.Unlock()
+		return m.healthy
+	}
+	p.mutex.Unlock()
+	return p.probe(ctx, m)
+}
+
+func (p *pool) probe(ctx context.Context, m *poolMember) bool {
+	ctx, cancel := context.WithTimeout(ctx, defaultHealthCheckTimeout)
+	defer cancel()
+	healthy := m.Kubernetes.Health(ctx) == nil
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+	m.healthy = healthy
+	m.checkedAt = time.Now()
+	return healthy
+}
+
+func (p *pool) placement(tasks []task.Task) (*poolMember, error) {
+	if len(tasks) == 0 || tasks[0].Metadata.Workflow == "" {
+		return nil, nil
+	}
+	placement, err := p.placements.Get(tasks[0].Metadata.Workflow)
+	if err != nil || placement == nil {
+		return nil, err
+	}
+	for _, m := range p.members {
+		if m.Name == placement.Cluster {
+			return m, nil
+		}
+	}
+	return nil, nil
+}
+
+// admission returns the admission of the member, nil when the pool has no quotas
+func (m *poolMember) admission() *admission {
+	return m.runtime.(*runtime).admission
+}
@@ -0,0 +1,249 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+func createHealthMock(err error) *kubernetes.MockKubernetes {
+	m := createKubernetesMock()
+	m.On("Health", mock.Anything).Return(err)
+	return m
+}
+
+func workflowTasks(typ string, workflow string) []task.Task {
+	return []task.Task{
+		{
+			Type: typ,
+			Spec: map[string]interface{}{
+				"name":      "dind",
+				"namespace": "ns",
+			},
+			Metadata: task.Metadata{
+				Workflow: workflow,
+			},
+		},
+	}
+}
+
+func Test_pool_StartWorkflow(t *testing.T) {
+	tests := []struct {
+		name    string
+		members func() []PoolMember
+		want    int
+		wantErr error
+	}{
+		{
+			name: "should start on the member with the lowest priority",
+			members: func() []PoolMember {
+				return []PoolMember{
+					{Name: "a", Kubernetes: createHealthMock(nil), Priority: 2},
+					{Name: "b", Kubernetes: createHealthMock(nil), Priority: 1},
+				}
+			},
+			want: 1,
+		},
+		{
+			name: "should fail over to the next healthy member",
+			members: func() []PoolMember {
+				return []PoolMember{
+					{Name: "a", Kubernetes: createHealthMock(errors.New("down")), Priority: 1},
+					{Name: "b", Kubernetes: createHealthMock(nil), Priority: 2},
+				}
+			},
+			want: 1,
+		},
+		{
+			name: "should only route to weighted members",
+			members: func() []PoolMember {
+				return []PoolMember{
+					{Name: "a", Kubernetes: createHealthMock(nil)},
+					{Name: "b", Kubernetes: createHealthMock(nil), Weight: 1},
+				}
+			},
+			want: 1,
+		},
+		{
+			name: "should fail when no member is healthy",
+			members: func() []PoolMember {
+				return []PoolMember{
+					{Name: "a", Kubernetes: createHealthMock(errors.New("down"))},
+				}
+			},
+			want:    -1,
+			wantErr: ErrNoHealthyMember,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			members := tt.members()
+			p := NewPool(PoolOptions{Members: members})
+			err := p.StartWorkflow(context.Background(), workflowTasks(task.TypeCreatePod, "1"))
+			assert.Equal(t, tt.wantErr, err)
+			for i, m := range members {
+				mo := m.Kubernetes.(*kubernetes.MockKubernetes)
+				if i == tt.want {
+					mo.AssertNumberOfCalls(t, "CreateResource", 1)
+				} else {
+					mo.AssertNotCalled(t, "CreateResource", mock.Anything, mock.Anything)
+				}
+			}
+		})
+	}
+}
+
+func Test_pool_TerminateWorkflow(t *testing.T) {
+	a := createHealthMock(nil)
+	b := createHealthMock(nil)
+	p := NewPool(PoolOptions{
+		Members: []PoolMember{
+			{Name: "a", Kubernetes: a, Priority: 1},
+			{Name: "b", Kubernetes: b, Priority: 2},
+		},
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, p.StartWorkflow(ctx, []task.Task{podTask("1", "1")}))
+	// member "a" goes down after the workflow started, new workflows go to "b"
+	p.(*pool).members[0].healthy = false
+	assert.NoError(t, p.StartWorkflow(ctx, []task.Task{podTask("2", "1")}))
+	a.AssertNumberOfCalls(t, "CreateResource", 1)
+	b.AssertNumberOfCalls(t, "CreateResource", 1)
+
+	// the termination is sent to the cluster that ran the workflow
+	assert.Empty(t, p.TerminateWorkflow(ctx, workflowTasks(task.TypeDeletePod, "2")))
+	a.AssertNotCalled(t, "DeleteResource", mock.Anything, mock.Anything)
+	b.AssertNumberOfCalls(t, "DeleteResource", 2)
+
+	// unknown workflows are sent to the members until one succeeds
+	assert.Empty(t, p.TerminateWorkflow(ctx, workflowTasks(task.TypeDeletePod, "3")))
+	a.AssertNumberOfCalls(t, "DeleteResource", 1)
+	b.AssertNumberOfCalls(t, "DeleteResource", 2)
+}
+
+func Test_pool_quotas(t *testing.T) {
+	member := func() *kubernetes.MockKubernetes {
+		m := createHealthMock(nil)
+		m.On("Capacity", mock.Anything, mock.Anything).Return(&kubernetes.Capacity{}, nil)
+		return m
+	}
+	a := member()
+	b := member()
+	quota := &AdmissionOptions{Quotas: []Quota{{Account: "a", MaxWorkflows: 1}, {Account: "b", MaxWorkflows: 1, Reject: true}}}
+	p := NewPool(PoolOptions{
+		Name: "re",
+		Members: []PoolMember{
+			{Name: "a", Kubernetes: a, Admission: quota, Priority: 1},
+			{Name: "b", Kubernetes: b, Priority: 2},
+		},
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, p.StartWorkflow(ctx, []task.Task{accountTask("1", "a", "1", nil)}))
+	assert.NoError(t, p.StartWorkflow(ctx, []task.Task{accountTask("2", "b", "1", nil)}))
+	// member "a" goes down, the quotas taken on it still hold on "b"
+	p.(*pool).members[0].healthy = false
+	assert.Equal(t, ErrWorkflowQueued, p.StartWorkflow(ctx, []task.Task{accountTask("3", "a", "1", nil)}))
+	err := p.StartWorkflow(ctx, []task.Task{accountTask("4", "b", "1", nil)})
+	assert.True(t, errors.Is(err, ErrWorkflowRejected))
+	b.AssertNotCalled(t, "CreateResource", mock.Anything, mock.Anything)
+	assert.Equal(t, []string{"3"}, p.QueuedWorkflows())
+	assert.Equal(t, []string{"4"}, p.RejectedWorkflows())
+
+	// terminating the workflow on "a" frees the quota for the queue of "b"
+	assert.Empty(t, p.TerminateWorkflow(ctx, []task.Task{{Type: task.TypeDeletePod, Spec: map[string]interface{}{"name": "dind"}, Metadata: task.Metadata{Workflow: "1"}}}))
+	assert.Empty(t, p.ProcessQueue(ctx))
+	assert.Empty(t, p.QueuedWorkflows())
+	b.AssertNumberOfCalls(t, "CreateResource", 1)
+}
+
+func Test_MonitorHealth(t *testing.T) {
+	a := createKubernetesMock()
+	a.On("Health", mock.Anything).Return(nil).Once()
+	a.On("Health", mock.Anything).Return(errors.New("down"))
+	a.On("Capacity", mock.Anything, mock.Anything).Return(&kubernetes.Capacity{}, nil)
+	b := createHealthMock(nil)
+	b.On("Capacity", mock.Anything, mock.Anything).Return(&kubernetes.Capacity{}, nil)
+	p := NewPool(PoolOptions{
+		Name: "re",
+		Members: []PoolMember{
+			{Name: "a", Kubernetes: a, Admission: &AdmissionOptions{Quotas: []Quota{{Account: "a", MaxWorkflows: 1}}}, Priority: 1},
+			{Name: "b", Kubernetes: b, Priority: 2},
+		},
+		HealthCheckInterval: time.Hour,
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, p.StartWorkflow(ctx, []task.Task{accountTask("1", "a", "1", nil)}))
+	assert.Equal(t, ErrWorkflowQueued, p.StartWorkflow(ctx, []task.Task{accountTask("2", "a", "1", nil)}))
+
+	// member "a" goes down, its queue is moved to "b"
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	MonitorHealth(done, p)
+	members := p.(*pool).members
+	assert.Empty(t, members[0].runtime.QueuedWorkflows())
+	assert.Equal(t, []string{"2"}, members[1].runtime.QueuedWorkflows())
+	assert.Equal(t, []string{"2"}, p.QueuedWorkflows())
+
+	// the members are no longer probed when workflows start
+	assert.NoError(t, p.StartWorkflow(ctx, []task.Task{accountTask("3", "b", "1", nil)}))
+	a.AssertNumberOfCalls(t, "Health", 2)
+	b.AssertNumberOfCalls(t, "Health", 1)
+
+	// the quota taken on "a" still holds, the queued workflow starts on "b" once it is released
+	assert.Empty(t, p.ProcessQueue(ctx))
+	assert.Equal(t, []string{"2"}, p.QueuedWorkflows())
+	assert.Empty(t, p.TerminateWorkflow(ctx, workflowTasks(task.TypeDeletePod, "1")))
+	assert.Empty(t, p.ProcessQueue(ctx))
+	assert.Empty(t, p.QueuedWorkflows())
+	a.AssertNumberOfCalls(t, "CreateResource", 1)
+	b.AssertNumberOfCalls(t, "CreateResource", 2)
+}
+
+func Test_MonitorHealth_noHealthyMember(t *testing.T) {
+	a := createKubernetesMock()
+	a.On("Health", mock.Anything).Return(nil).Once()
+	a.On("Health", mock.Anything).Return(errors.New("down"))
+	a.On("Capacity", mock.Anything, mock.Anything).Return(&kubernetes.Capacity{}, nil)
+	p := NewPool(PoolOptions{
+		Name: "re",
+		Members: []PoolMember{
+			{Name: "a", Kubernetes: a, Admission: &AdmissionOptions{Quotas: []Quota{{Account: "a", MaxWorkflows: 1}}}},
+			{Name: "b", Kubernetes: createHealthMock(errors.New("down"))},
+		},
+		HealthCheckInterval: time.Hour,
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, p.StartWorkflow(ctx, []task.Task{accountTask("1", "a", "1", nil)}))
+	assert.Equal(t, ErrWorkflowQueued, p.StartWorkflow(ctx, []task.Task{accountTask("2", "a", "1", nil)}))
+
+	done, cancel := context.WithCancel(ctx)
+	cancel()
+	MonitorHealth(done, p)
+	// the workflow stays queued where it is
+	assert.Equal(t, []string{"2"}, p.(*pool).members[0].runtime.QueuedWorkflows())
+	assert.Equal(t, ErrNoHealthyMember, p.StartWorkflow(ctx, []task.Task{accountTask("3", "b", "1", nil)}))
+}
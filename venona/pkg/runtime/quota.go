@@ -0,0 +1,144 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+// ErrWorkflowRejected is returned when the workflow exceeds a quota that rejects workflows,
+// or when the workflow alone is bigger than one of its quotas
+var ErrWorkflowRejected = errors.New("Workflow rejected, quota exceeded")
+
+// QuotaAnyAccount gives every account its own quota
+const QuotaAnyAccount = "*"
+
+type (
+	// Quota limits the workflows of an account running on the runtime at the same time,
+	// zero value of a limit means there is no limit
+	Quota struct {
+		// Account the quota applies to, QuotaAnyAccount applies it to each account separately
+		Account string
+		// Labels narrow the quota to workflows with a pod that has all of them
+		Labels       map[string]string
+		MaxWorkflows int
+		MaxCPU       resource.Quantity
+		MaxMemory    resource.Quantity
+		// Reject fails the workflows over the quota instead of queueing them
+		Reject bool
+	}
+
+	// workflowUsage is what a workflow takes from the quotas while it is running
+	workflowUsage struct {
+		account   string
+		labels    []map[string]string
+		resources kubernetes.Resources
+	}
+)
+
+func newWorkflowUsage(tasks []task.Task) (workflowUsage, error) {
+	u := workflowUsage{account: tasks[0].Metadata.Account}
+	specs := []interface{}{}
+	for _, t := range tasks {
+		if t.Type != task.TypeCreatePod {
+			continue
+		}
+		specs = append(specs, t.Spec)
+		b, err := json.Marshal(t.Spec)
+		if err != nil {
+			return u, err
+		}
+		meta := struct {
+			Metadata struct {
+				Labels map[string]string `json:"labels"`
+			} `json:"metadata"`
+		}{}
+		if err := json.Unmarshal(b, &meta); err != nil {
+			return u, err
+		}
+		u.labels = append(u.labels, meta.Metadata.Labels)
+	}
+	r, err := kubernetes.RequestedResources(specs...)
+	if err != nil {
+		return u, err
+	}
+	u.resources = r
+	return u, nil
+}
+
+func (q Quota) matches(u workflowUsage) bool {
+	if q.Account != QuotaAnyAccount && q.Account != u.account {
+		return false
+	}
+	if len(q.Labels) == 0 {
+		return true
+	}
+	for _, labels := range u.labels {
+		if hasLabels(labels, q.Labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// shares returns true when both workflows are counted against the same quota
+func (q Quota) shares(a workflowUsage, b workflowUsage) bool {
+	if !q.matches(a) || !q.matches(b) {
+		return false
+	}
+	return q.Account != QuotaAnyAccount || a.account == b.account
+}
+
+// exceeded returns true when used is over one of the limits
+func (q Quota) exceeded(workflows int, used kubernetes.Resources) bool {
+	if q.MaxWorkflows > 0 && workflows > q.MaxWorkflows {
+		return true
+	}
+	if !q.MaxCPU.IsZero() && used.CPU.Cmp(q.MaxCPU) > 0 {
+		return true
+	}
+	return !q.MaxMemory.IsZero() && used.Memory.Cmp(q.MaxMemory) > 0
+}
+
+func (q Quota) String() string {
+	s := fmt.Sprintf("account %s", q.Account)
+	if len(q.Labels) != 0 {
+		keys := make([]string, 0, len(q.Labels))
+		for k := range q.Labels {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			s += fmt.Sprintf(" %s=%s", k, q.Labels[k])
+		}
+	}
+	return s
+}
+
+func hasLabels(labels map[string]string, selector map[string]string) bool {
+	for k, v := range selector {
+		if labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
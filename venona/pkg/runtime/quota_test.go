@@ -0,0 +1,218 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package runtime
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/task"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"k8s.io/apimachinery/pkg/api/resource"
+)
+
+func accountTask(workflow string, account string, cpu string, labels map[string]interface{}) task.Task {
+	t := podTask(workflow, cpu)
+	t.Metadata.Account = account
+	if labels != nil {
+		t.Spec.(map[string]interface{})["metadata"].(map[string]interface{})["labels"] = labels
+	}
+	return t
+}
+
+func Test_admission_withinQuotas(t *testing.T) {
+	tests := []struct {
+		name       string
+		quotas     []Quota
+		running    []task.Task
+		workflow   task.Task
+		wantQuota  int
+		wantReject bool
+	}{
+		{
+			name:      "should pass without quotas",
+			running:   []task.Task{accountTask("1", "a", "1", nil)},
+			workflow:  accountTask("2", "a", "1", nil),
+			wantQuota: -1,
+		},
+		{
+			name:      "should pass when below max workflows",
+			quotas:    []Quota{{Account: "a", MaxWorkflows: 2}},
+			running:   []task.Task{accountTask("1", "a", "1", nil)},
+			workflow:  accountTask("2", "a", "1", nil),
+			wantQuota: -1,
+		},
+		{
+			name:      "should exceed max workflows",
+			quotas:    []Quota{{Account: "a", MaxWorkflows: 1}},
+			running:   []task.Task{accountTask("1", "a", "1", nil)},
+			workflow:  accountTask("2", "a", "1", nil),
+			wantQuota: 0,
+		},
+		{
+			name:      "should not count workflows of other accounts",
+			quotas:    []Quota{{Account: "a", MaxWorkflows: 1}},
+			running:   []task.Task{accountTask("1", "b", "1", nil)},
+			workflow:  accountTask("2", "a", "1", nil),
+			wantQuota: -1,
+		},
+		{
+			name:      "should count every account separately with any account quota",
+			quotas:    []Quota{{Account: QuotaAnyAccount, MaxWorkflows: 1}},
+			running:   []task.Task{accountTask("1", "b", "1", nil)},
+			workflow:  accountTask("2", "a", "1", nil),
+			wantQuota: -1,
+		},
+		{
+			name:      "should exceed max cpu",
+			quotas:    []Quota{{Account: "a", MaxCPU: resource.MustParse("2")}},
+			running:   []task.Task{accountTask("1", "a", "1500m", nil)},
+			workflow:  accountTask("2", "a", "1", nil),
+			wantQuota: 0,
+		},
+		{
+			name:       "should reject when the quota rejects",
+			quotas:     []Quota{{Account: "a", MaxWorkflows: 1, Reject: true}},
+			running:    []task.Task{accountTask("1", "a", "1", nil)},
+			workflow:   accountTask("2", "a", "1", nil),
+			wantQuota:  0,
+			wantReject: true,
+		},
+		{
+			name:       "should reject a workflow bigger than the quota",
+			quotas:     []Quota{{Account: "a", MaxCPU: resource.MustParse("1")}},
+			workflow:   accountTask("1", "a", "2", nil),
+			wantQuota:  0,
+			wantReject: true,
+		},
+		{
+			name:      "should apply the quota to workflows with the labels only",
+			quotas:    []Quota{{Account: "a", Labels: map[string]string{"pipeline": "p1"}, MaxWorkflows: 1}},
+			running:   []task.Task{accountTask("1", "a", "1", map[string]interface{}{"pipeline": "p1"})},
+			workflow:  accountTask("2", "a", "1", map[string]interface{}{"pipeline": "p2"}),
+			wantQuota: -1,
+		},
+		{
+			name:      "should exceed the quota of workflows with the labels",
+			quotas:    []Quota{{Account: "a", Labels: map[string]string{"pipeline": "p1"}, MaxWorkflows: 1}},
+			running:   []task.Task{accountTask("1", "a", "1", map[string]interface{}{"pipeline": "p1"})},
+			workflow:  accountTask("2", "a", "1", map[string]interface{}{"pipeline": "p1"}),
+			wantQuota: 0,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAdmission(&AdmissionOptions{Quotas: tt.quotas})
+			for _, r := range tt.running {
+				u, err := newWorkflowUsage([]task.Task{r})
+				assert.NoError(t, err)
+				a.running[r.Metadata.Workflow] = u
+			}
+			u, err := newWorkflowUsage([]task.Task{tt.workflow})
+			assert.NoError(t, err)
+			q, reject := a.withinQuotas(u)
+			if tt.wantQuota < 0 {
+				assert.Nil(t, q)
+			} else {
+				assert.Equal(t, &a.quotas[tt.wantQuota], q)
+			}
+			assert.Equal(t, tt.wantReject, reject)
+		})
+	}
+}
+
+func Test_runtime_StartWorkflow_quota(t *testing.T) {
+	m := createCapacityMock(&kubernetes.Capacity{})
+	r := New(Options{
+		Kubernetes: m,
+		Admission: &AdmissionOptions{
+			Quotas: []Quota{
+				{Account: "a", MaxWorkflows: 1},
+				{Account: "b", MaxWorkflows: 1, Reject: true},
+			},
+		},
+	})
+	ctx := context.Background()
+
+	assert.NoError(t, r.StartWorkflow(ctx, []task.Task{accountTask("1", "a", "1", nil)}))
+	assert.Equal(t, ErrWorkflowQueued, r.StartWorkflow(ctx, []task.Task{accountTask("2", "a", "1", nil)}))
+	assert.NoError(t, r.StartWorkflow(ctx, []task.Task{accountTask("3", "b", "1", nil)}))
+	err := r.StartWorkflow(ctx, []task.Task{accountTask("4", "b", "1", nil)})
+	assert.True(t, errors.Is(err, ErrWorkflowRejected))
+	assert.Equal(t, []string{"2"}, r.QueuedWorkflows())
+	assert.Equal(t, []string{"4"}, r.RejectedWorkflows())
+	m.AssertCalled(t, "RecordEvent", mock.Anything, mock.Anything, kubernetes.EventReasonRejected, mock.Anything)
+
+	// the workflow over its quota does not hold back the other accounts
+	assert.NoError(t, r.StartWorkflow(ctx, []task.Task{accountTask("5", "c", "1", nil)}))
+	assert.Empty(t, r.ProcessQueue(ctx))
+	assert.Equal(t, []string{"2"}, r.QueuedWorkflows())
+
+	// terminating a running workflow frees its quota
+	assert.Empty(t, r.TerminateWorkflow(ctx, []task.Task{{Type: task.TypeDeletePod, Spec: map[string]interface{}{"name": "dind"}, Metadata: task.Metadata{Workflow: "1"}}}))
+	assert.Empty(t, r.ProcessQueue(ctx))
+	assert.Empty(t, r.QueuedWorkflows())
+
+	// terminating a rejected workflow stops reporting it
+	assert.Empty(t, r.TerminateWorkflow(ctx, []task.Task{{Type: task.TypeDeletePod, Metadata: task.Metadata{Workflow: "4"}}}))
+	assert.Empty(t, r.RejectedWorkflows())
+	m.AssertNumberOfCalls(t, "CreateResource", 4)
+}
+
+func Test_Restore(t *testing.T) {
+	placements := NewMemoryPlacementStore()
+	opt := Options{
+		Kubernetes: createCapacityMock(&kubernetes.Capacity{}),
+		Admission:  &AdmissionOptions{Quotas: []Quota{{Account: "a", MaxCPU: resource.MustParse("1")}}},
+		Name:       "re",
+		Agent:      "agent",
+		Placements: placements,
+	}
+	ctx := context.Background()
+	assert.NoError(t, New(opt).StartWorkflow(ctx, []task.Task{accountTask("1", "a", "1", nil)}))
+	p, err := placements.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, "agent", p.Agent)
+	assert.Equal(t, "a", p.Account)
+	assert.Equal(t, "1", p.Resources.CPU.String())
+
+	// the agent restarted, the running workflow still takes the quota
+	r := New(opt)
+	assert.NoError(t, Restore(r))
+	assert.Equal(t, ErrWorkflowQueued, r.StartWorkflow(ctx, []task.Task{accountTask("2", "a", "500m", nil)}))
+
+	// workflows of other runtimes are not counted
+	opt.Name = "other"
+	r = New(opt)
+	assert.NoError(t, Restore(r))
+	assert.NoError(t, r.StartWorkflow(ctx, []task.Task{accountTask("3", "a", "500m", nil)}))
+
+	// nor those of other agents serving the same runtime
+	opt.Name = "re"
+	opt.Agent = "other"
+	r = New(opt)
+	assert.NoError(t, Restore(r))
+	assert.NoError(t, r.StartWorkflow(ctx, []task.Task{accountTask("4", "a", "500m", nil)}))
+
+	// records saved before agents were recorded belong to any of them
+	p.Agent = ""
+	assert.NoError(t, placements.Save(p))
+	r = New(opt)
+	assert.NoError(t, Restore(r))
+	assert.Equal(t, ErrWorkflowQueued, r.StartWorkflow(ctx, []task.Task{accountTask("5", "a", "500m", nil)}))
+}
@@ -18,9 +18,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/codefresh-io/go/venona/pkg/kubernetes"
+	"github.com/codefresh-io/go/venona/pkg/logger"
 	"github.com/codefresh-io/go/venona/pkg/task"
+
+	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 )
 
 type (
@@ -28,36 +33,301 @@ type (
 	Runtime interface {
 		StartWorkflow(context.Context, []task.Task) error
 		TerminateWorkflow(context.Context, []task.Task) []error
+		// ProcessQueue starts the queued workflows that fit into the runtime capacity
+		ProcessQueue(context.Context) []error
+		// QueuedWorkflows returns the ids of the workflows waiting for capacity or quota
+		QueuedWorkflows() []string
+		// RejectedWorkflows returns the ids of the workflows rejected by a quota,
+		// until they are terminated
+		RejectedWorkflows() []string
 	}
 
 	// Options for runtime
 	Options struct {
 		Kubernetes kubernetes.Kubernetes
+		// Admission enables queueing of workflows when the runtime is out of capacity
+		Admission *AdmissionOptions
+		// Name of the runtime and Cluster it is running on, stored in the placement records
+		Name    string
+		Cluster string
+		// Agent serving the runtime, stored in the placement records so each agent of the
+		// process restores its own quotas
+		Agent string
+		// Placements keeps what was created for each workflow, when set TerminateWorkflow
+		// deletes the recorded objects in addition to the ones named by the tasks
+		Placements PlacementStore
 	}
 
 	runtime struct {
-		client kubernetes.Kubernetes
+		client     kubernetes.Kubernetes
+		admission  *admission
+		name       string
+		cluster    string
+		agent      string
+		placements PlacementStore
 	}
 )
 
 // New creates new Runtime client
 func New(opt Options) Runtime {
 	return &runtime{
-		client: opt.Kubernetes,
+		client:     opt.Kubernetes,
+		admission:  newAdmission(opt.Admission),
+		name:       opt.Name,
+		cluster:    opt.Cluster,
+		agent:      opt.Agent,
+		placements: opt.Placements,
 	}
 }
 
+// Restore takes the quotas of the workflows that were running when the agent restarted,
+// from the placement records, so a restart does not let accounts exceed their quotas
+func Restore(re Runtime) error {
+	switch r := re.(type) {
+	case *runtime:
+		if r.admission == nil || r.placements == nil {
+			return nil
+		}
+		return r.admission.restore(r.placements, r.name, r.agent)
+	case *pool:
+		if r.quotas == nil {
+			return nil
+		}
+		return r.quotas.restore(r.placements, r.name, r.agent)
+	}
+	return nil
+}
+
 func (r runtime) StartWorkflow(ctx context.Context, tasks []task.Task) error {
-	for _, task := range tasks {
-		err := r.client.CreateResource(ctx, task.Spec)
+	if r.admission == nil {
+		return r.createResources(ctx, tasks, nil)
+	}
+
+	usage, err := newWorkflowUsage(tasks)
+	if err != nil {
+		return err
+	}
+	workflow := tasks[0].Metadata.Workflow
+	// the quotas are taken before the capacity is read, concurrent workflows cannot exceed them
+	r.admission.mutex.Lock()
+	if q, reject := r.admission.withinQuotas(usage); q != nil {
+		if reject {
+			r.admission.rejected[workflow] = true
+			r.admission.mutex.Unlock()
+			return r.reject(tasks, q)
+		}
+		queued := r.admission.push(tasks)
+		r.admission.mutex.Unlock()
+		return r.enqueue(tasks, fmt.Sprintf("quota of %s", q), queued)
+	}
+	if r.admission.waitingForCapacity() {
+		// keep the order, workflows that are already waiting go first
+		queued := r.admission.push(tasks)
+		r.admission.mutex.Unlock()
+		return r.enqueue(tasks, "capacity", queued)
+	}
+	r.admission.take(workflow, usage)
+	r.admission.mutex.Unlock()
+
+	ok, err := r.admission.admit(ctx, r.client, tasks)
+	if err != nil || !ok {
+		r.admission.mutex.Lock()
+		delete(r.admission.running, workflow)
+		queued := 0
+		if err == nil {
+			queued = r.admission.push(tasks)
+		}
+		r.admission.mutex.Unlock()
 		if err != nil {
-			return err // TODO: Return already executed tasks in order to terminate them
+			return err
 		}
+		return r.enqueue(tasks, "capacity", queued)
+	}
+	return r.start(ctx, tasks, usage)
+}
+
+// enqueue records that the workflow was added to the queue
+func (r runtime) enqueue(tasks []task.Task, waitingFor string, queued int) error {
+	r.client.RecordEvent(kubernetes.EventObject{Namespace: r.admission.opt.Namespace}, v1.EventTypeNormal, kubernetes.EventReasonQueued,
+		fmt.Sprintf("Workflow %s is waiting for %s, %d workflows in queue", tasks[0].Metadata.Workflow, waitingFor, queued))
+	return ErrWorkflowQueued
+}
+
+// reject records that the workflow was marked as rejected
+func (r runtime) reject(tasks []task.Task, q *Quota) error {
+	r.client.RecordEvent(kubernetes.EventObject{Namespace: r.admission.opt.Namespace}, v1.EventTypeWarning, kubernetes.EventReasonRejected,
+		fmt.Sprintf("Workflow %s rejected, exceeds quota of %s", tasks[0].Metadata.Workflow, q))
+	return fmt.Errorf("%w: %s", ErrWorkflowRejected, q)
+}
+
+// start creates the resources of an admitted workflow that took its quotas
+func (r runtime) start(ctx context.Context, tasks []task.Task, usage workflowUsage) error {
+	err := r.createResources(ctx, tasks, &usage)
+	r.admission.started(tasks, err == nil)
+	return err
+}
+
+func (r runtime) ProcessQueue(ctx context.Context) []error {
+	errs := []error{}
+	if r.admission == nil {
+		return errs
+	}
+
+	r.admission.processing.Lock()
+	defer r.admission.processing.Unlock()
+	r.admission.mutex.Lock()
+	r.admission.sortQueue()
+	queue := append([]queuedWorkflow{}, r.admission.queue...)
+	r.admission.mutex.Unlock()
+	for _, wf := range queue {
+		tasks := wf.tasks
+		workflow := tasks[0].Metadata.Workflow
+		ctx := logger.WithFields(ctx, tasks[0].LogFields()...)
+		usage, err := newWorkflowUsage(tasks)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to read queued workflow %s: %w", workflow, err))
+			continue
+		}
+
+		r.admission.mutex.Lock()
+		if !r.admission.isQueued(workflow) {
+			// terminated since the queue was read
+			r.admission.mutex.Unlock()
+			continue
+		}
+		if q, reject := r.admission.withinQuotas(usage); q != nil {
+			if reject {
+				r.admission.remove(workflow)
+				r.admission.rejected[workflow] = true
+			}
+			r.admission.mutex.Unlock()
+			if reject {
+				errs = append(errs, r.reject(tasks, q))
+			}
+			continue
+		}
+		r.admission.take(workflow, usage)
+		r.admission.mutex.Unlock()
+
+		ok, err := r.admission.admit(ctx, r.client, tasks)
+		if err != nil || !ok {
+			r.admission.mutex.Lock()
+			delete(r.admission.running, workflow)
+			r.admission.mutex.Unlock()
+			if err != nil {
+				errs = append(errs, err)
+			}
+			break
+		}
+		r.admission.mutex.Lock()
+		dequeued := r.admission.remove(workflow)
+		r.admission.mutex.Unlock()
+		if !dequeued {
+			// terminated while the capacity was read
+			r.admission.started(tasks, false)
+			continue
+		}
+		if err := r.start(ctx, tasks, usage); err != nil {
+			errs = append(errs, fmt.Errorf("failed to start queued workflow %s: %w", workflow, err))
+		}
+	}
+	return errs
+}
+
+func (r runtime) QueuedWorkflows() []string {
+	if r.admission == nil {
+		return []string{}
+	}
+	return r.admission.workflows()
+}
+
+func (r runtime) RejectedWorkflows() []string {
+	if r.admission == nil {
+		return []string{}
+	}
+	return r.admission.rejectedWorkflows()
+}
+
+// createResources creates the workflow objects, usage is recorded in the placement when the
+// workflow is counted against quotas
+func (r runtime) createResources(ctx context.Context, tasks []task.Task, usage *workflowUsage) error {
+	workflow := tasks[0].Metadata.Workflow
+	created := []kubernetes.DeleteOptions{}
+	for _, task := range tasks {
+		ref, refErr := kubernetes.DeleteOptionsFromSpec(task.Spec)
+		if err := r.client.CreateResource(ctx, task.Spec); err != nil {
+			if refErr == nil {
+				r.client.RecordEvent(kubernetes.WorkflowResource(ref), v1.EventTypeWarning, kubernetes.EventReasonCreateFailed,
+					fmt.Sprintf("Failed to create %s for workflow %s: %s", ref.Name, workflow, err.Error()))
+			}
+			// what was created is recorded so termination deletes it
+			if namespace := startNamespace(ref, created); namespace != "" {
+				r.client.RecordEvent(kubernetes.EventObject{Namespace: namespace}, v1.EventTypeWarning, kubernetes.EventReasonStartFailed,
+					fmt.Sprintf("Workflow %s failed to start, %d created objects are kept until it is terminated", workflow, len(created)))
+			}
+			if saveErr := r.savePlacement(workflow, created, usage); saveErr != nil {
+				return fmt.Errorf("%v, %w", err, saveErr)
+			}
+			return err
+		}
+		if refErr != nil || ref.Name == "" {
+			continue
+		}
+		created = append(created, ref)
+		r.client.RecordEvent(kubernetes.WorkflowResource(ref), v1.EventTypeNormal, kubernetes.EventReasonCreated,
+			fmt.Sprintf("Created %s for workflow %s", ref.Name, workflow))
+	}
+	return r.savePlacement(workflow, created, usage)
+}
+
+// startNamespace returns the namespace of the workflow, taken from its resources
+func startNamespace(failed kubernetes.DeleteOptions, created []kubernetes.DeleteOptions) string {
+	if failed.Namespace != "" || len(created) == 0 {
+		return failed.Namespace
+	}
+	return created[0].Namespace
+}
+
+func (r runtime) savePlacement(workflow string, objects []kubernetes.DeleteOptions, usage *workflowUsage) error {
+	if r.placements == nil || workflow == "" || len(objects) == 0 {
+		return nil
+	}
+	placement := &Placement{
+		Workflow:  workflow,
+		Runtime:   r.name,
+		Cluster:   r.cluster,
+		Agent:     r.agent,
+		Namespace: objects[0].Namespace,
+		Objects:   objects,
+		CreatedAt: time.Now(),
+	}
+	if usage != nil {
+		placement.Account = usage.account
+		placement.Labels = usage.labels
+		placement.Resources = &kubernetes.Resources{CPU: usage.resources.CPU.DeepCopy(), Memory: usage.resources.Memory.DeepCopy()}
+	}
+	if err := r.placements.Save(placement); err != nil {
+		return fmt.Errorf("failed to save workflow placement: %w", err)
 	}
 	return nil
 }
+
 func (r runtime) TerminateWorkflow(ctx context.Context, tasks []task.Task) []error {
 	errs := make([]error, 0, 3)
+	if len(tasks) == 0 {
+		return errs
+	}
+	workflow := tasks[0].Metadata.Workflow
+	if r.admission != nil {
+		r.admission.release(workflow)
+		if r.admission.dequeue(workflow) {
+			// the workflow never started, nothing to delete
+			return errs
+		}
+	}
+
+	deleted := map[kubernetes.DeleteOptions]bool{}
+	failed := map[kubernetes.DeleteOptions]bool{}
 	for _, task := range tasks {
 		opt := kubernetes.DeleteOptions{}
 		opt.Kind = task.Type
@@ -70,10 +340,63 @@ func (r runtime) TerminateWorkflow(ctx context.Context, tasks []task.Task) []err
 			errs = append(errs, fmt.Errorf("failed to unmarshal task spec"))
 			continue
 		}
-		if err = r.client.DeleteResource(ctx, opt); err != nil {
+		deleted[opt] = true
+		if err = r.deleteResource(ctx, workflow, opt); err != nil {
+			if !apierrors.IsNotFound(err) {
+				failed[opt] = true
+			}
 			errs = append(errs, err)
 			continue
 		}
 	}
+
+	if r.placements == nil || workflow == "" {
+		return errs
+	}
+	placement, err := r.placements.Get(workflow)
+	if err != nil {
+		return append(errs, fmt.Errorf("failed to read workflow placement: %w", err))
+	}
+	if placement == nil {
+		return errs
+	}
+	// delete what was created but not named by the deletion tasks,
+	// objects that could not be deleted stay in the record so termination can retry
+	left := []kubernetes.DeleteOptions{}
+	for _, opt := range placement.Objects {
+		if deleted[opt] {
+			if failed[opt] {
+				left = append(left, opt)
+			}
+			continue
+		}
+		if err := r.deleteResource(ctx, workflow, opt); err != nil && !apierrors.IsNotFound(err) {
+			errs = append(errs, err)
+			left = append(left, opt)
+		}
+	}
+	if len(left) != 0 {
+		placement.Objects = left
+		if err := r.placements.Save(placement); err != nil {
+			errs = append(errs, fmt.Errorf("failed to save workflow placement: %w", err))
+		}
+		return errs
+	}
+	if err := r.placements.Delete(workflow); err != nil {
+		errs = append(errs, fmt.Errorf("failed to delete workflow placement: %w", err))
+	}
 	return errs
 }
+
+func (r runtime) deleteResource(ctx context.Context, workflow string, opt kubernetes.DeleteOptions) error {
+	if err := r.client.DeleteResource(ctx, opt); err != nil {
+		if !apierrors.IsNotFound(err) {
+			r.client.RecordEvent(kubernetes.WorkflowResource(opt), v1.EventTypeWarning, kubernetes.EventReasonDeleteFailed,
+				fmt.Sprintf("Failed to delete %s for workflow %s: %s", opt.Name, workflow, err.Error()))
+		}
+		return err
+	}
+	r.client.RecordEvent(kubernetes.WorkflowResource(opt), v1.EventTypeNormal, kubernetes.EventReasonDeleted,
+		fmt.Sprintf("Deleted %s for workflow %s", opt.Name, workflow))
+	return nil
+}
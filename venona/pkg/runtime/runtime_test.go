@@ -16,18 +16,21 @@ package runtime
 
 import (
 	"context"
+	"errors"
 	"testing"
 
 	"github.com/codefresh-io/go/venona/pkg/kubernetes"
 	"github.com/codefresh-io/go/venona/pkg/task"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	v1 "k8s.io/api/core/v1"
 )
 
 func createKubernetesMock() *kubernetes.MockKubernetes {
 	m := &kubernetes.MockKubernetes{}
 	m.On("CreateResource", mock.Anything, mock.Anything).Return(nil)
 	m.On("DeleteResource", mock.Anything, mock.Anything).Return(nil)
+	m.On("RecordEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
 	return m
 }
 
@@ -72,6 +75,33 @@ func Test_runtime_StartWorkflow(t *testing.T) {
 	}
 }
 
+func Test_runtime_StartWorkflow_createFailed(t *testing.T) {
+	m := &kubernetes.MockKubernetes{}
+	first, second := podTask("1", "1"), podTask("1", "1")
+	second.Spec.(map[string]interface{})["metadata"].(map[string]interface{})["name"] = "second"
+	for _, t := range []task.Task{first, second} {
+		t.Spec.(map[string]interface{})["metadata"].(map[string]interface{})["namespace"] = "ns"
+	}
+	m.On("CreateResource", mock.Anything, first.Spec).Return(nil).Once()
+	m.On("CreateResource", mock.Anything, second.Spec).Return(errors.New("quota exceeded")).Once()
+	m.On("RecordEvent", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return()
+	store := NewMemoryPlacementStore()
+	r := runtime{client: m, placements: store}
+
+	err := r.StartWorkflow(context.Background(), []task.Task{first, second})
+	assert.EqualError(t, err, "quota exceeded")
+	failed, _ := kubernetes.DeleteOptionsFromSpec(second.Spec)
+	m.AssertCalled(t, "RecordEvent", kubernetes.WorkflowResource(failed), v1.EventTypeWarning, kubernetes.EventReasonCreateFailed, mock.Anything)
+	m.AssertCalled(t, "RecordEvent", kubernetes.EventObject{Namespace: "ns"}, v1.EventTypeWarning, kubernetes.EventReasonStartFailed,
+		"Workflow 1 failed to start, 1 created objects are kept until it is terminated")
+	m.AssertNotCalled(t, "DeleteResource", mock.Anything, mock.Anything)
+	// the created pod is left for the termination of the workflow
+	created, _ := kubernetes.DeleteOptionsFromSpec(first.Spec)
+	placement, err := store.Get("1")
+	assert.NoError(t, err)
+	assert.Equal(t, []kubernetes.DeleteOptions{created}, placement.Objects)
+}
+
 func Test_runtime_TerminateWorkflow(t *testing.T) {
 	type args struct {
 		tasks []task.Task
@@ -0,0 +1,116 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/logger"
+	"github.com/gorilla/mux"
+)
+
+const maxDumpDuration = time.Hour
+
+type (
+	// Dumper turns on dumping of requests for a limited time
+	Dumper interface {
+		Enable(time.Duration)
+	}
+
+	logLevelRequest struct {
+		Module string `json:"module"`
+		Level  string `json:"level"`
+	}
+
+	dumpRequest struct {
+		Duration string `json:"duration"`
+	}
+)
+
+// registerAdmin adds the debug endpoints, all of them require the admin token
+func registerAdmin(r *mux.Router, token string, levels *logger.Levels, dumper Dumper, log logger.Logger) {
+	admin := r.PathPrefix("/debug").Subrouter()
+	admin.Use(authenticate(token))
+
+	if levels != nil {
+		admin.HandleFunc("/log-level", func(w http.ResponseWriter, r *http.Request) {
+			writeJSON(w, http.StatusOK, levels.Get())
+		}).Methods(http.MethodGet)
+
+		admin.HandleFunc("/log-level", func(w http.ResponseWriter, r *http.Request) {
+			req := logLevelRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if err := levels.Set(req.Module, req.Level); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			log.Warn("Log level changed", "target-module", req.Module, "level", req.Level)
+			writeJSON(w, http.StatusOK, levels.Get())
+		}).Methods(http.MethodPut)
+
+		admin.HandleFunc("/log-level", func(w http.ResponseWriter, r *http.Request) {
+			levels.Reset()
+			log.Warn("Log levels reset")
+			writeJSON(w, http.StatusOK, levels.Get())
+		}).Methods(http.MethodDelete)
+	}
+
+	if dumper != nil {
+		admin.HandleFunc("/codefresh-dump", func(w http.ResponseWriter, r *http.Request) {
+			req := dumpRequest{}
+			if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			d, err := time.ParseDuration(req.Duration)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			if d > maxDumpDuration {
+				d = maxDumpDuration
+			}
+			dumper.Enable(d)
+			log.Warn("Codefresh requests dump enabled", "duration", d.String())
+			writeJSON(w, http.StatusOK, map[string]string{"duration": d.String()})
+		}).Methods(http.MethodPut)
+	}
+}
+
+func authenticate(token string) mux.MiddlewareFunc {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+				http.Error(w, http.StatusText(http.StatusUnauthorized), http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(v)
+}
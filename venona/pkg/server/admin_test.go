@@ -0,0 +1,129 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/codefresh-io/go/venona/pkg/logger"
+	"github.com/codefresh-io/go/venona/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+)
+
+type fakeDumper struct {
+	duration time.Duration
+}
+
+func (d *fakeDumper) Enable(duration time.Duration) {
+	d.duration = duration
+}
+
+func Test_adminEndpoints(t *testing.T) {
+	log := &mocks.Logger{}
+	log.On("Warn", mock.Anything, mock.Anything, mock.Anything, mock.Anything, mock.Anything)
+	log.On("Warn", mock.Anything, mock.Anything, mock.Anything)
+	levels := logger.NewLevels()
+	dumper := &fakeDumper{}
+	s, err := New(&Options{
+		Logger:     log,
+		AdminToken: "secret",
+		Levels:     levels,
+		Dumper:     dumper,
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		method     string
+		path       string
+		token      string
+		body       string
+		wantStatus int
+	}{
+		{
+			name:       "should reject request without token",
+			method:     http.MethodGet,
+			path:       "/debug/log-level",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "should reject request with wrong token",
+			method:     http.MethodGet,
+			path:       "/debug/log-level",
+			token:      "other",
+			wantStatus: http.StatusUnauthorized,
+		},
+		{
+			name:       "should return log levels",
+			method:     http.MethodGet,
+			path:       "/debug/log-level",
+			token:      "secret",
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should set module log level",
+			method:     http.MethodPut,
+			path:       "/debug/log-level",
+			token:      "secret",
+			body:       `{"module":"kubernetes","level":"debug"}`,
+			wantStatus: http.StatusOK,
+		},
+		{
+			name:       "should reject unknown log level",
+			method:     http.MethodPut,
+			path:       "/debug/log-level",
+			token:      "secret",
+			body:       `{"module":"kubernetes","level":"loud"}`,
+			wantStatus: http.StatusBadRequest,
+		},
+		{
+			name:       "should enable codefresh dump",
+			method:     http.MethodPut,
+			path:       "/debug/codefresh-dump",
+			token:      "secret",
+			body:       `{"duration":"5m"}`,
+			wantStatus: http.StatusOK,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+			if tt.token != "" {
+				req.Header.Set("Authorization", "Bearer "+tt.token)
+			}
+			rec := httptest.NewRecorder()
+			s.srv.Handler.ServeHTTP(rec, req)
+			assert.Equal(t, tt.wantStatus, rec.Code)
+		})
+	}
+	assert.Equal(t, "dbug", levels.Get()["kubernetes"])
+	assert.Equal(t, time.Minute*5, dumper.duration)
+}
+
+func Test_adminEndpointsDisabled(t *testing.T) {
+	s, err := New(&Options{
+		Logger: &mocks.Logger{},
+		Levels: logger.NewLevels(),
+	})
+	assert.NoError(t, err)
+	rec := httptest.NewRecorder()
+	s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/log-level", nil))
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+}
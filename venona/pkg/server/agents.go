@@ -0,0 +1,57 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+
+	"github.com/codefresh-io/go/venona/pkg/agent"
+	"github.com/gorilla/mux"
+)
+
+type (
+	// Agent is an agent served by the process
+	Agent interface {
+		ID() string
+		Status() agent.Status
+	}
+
+	agentStatus struct {
+		ID     string       `json:"id"`
+		Status agent.Status `json:"status"`
+	}
+)
+
+// registerAgents adds the status endpoints of the agents
+func registerAgents(r *mux.Router, agents []Agent) {
+	r.HandleFunc("/agents", func(w http.ResponseWriter, r *http.Request) {
+		res := make([]agentStatus, 0, len(agents))
+		for _, a := range agents {
+			res = append(res, agentStatus{ID: a.ID(), Status: a.Status()})
+		}
+		writeJSON(w, http.StatusOK, res)
+	}).Methods(http.MethodGet)
+
+	r.HandleFunc("/agents/{id}", func(w http.ResponseWriter, r *http.Request) {
+		id := mux.Vars(r)["id"]
+		for _, a := range agents {
+			if a.ID() == id {
+				writeJSON(w, http.StatusOK, agentStatus{ID: id, Status: a.Status()})
+				return
+			}
+		}
+		http.Error(w, http.StatusText(http.StatusNotFound), http.StatusNotFound)
+	}).Methods(http.MethodGet)
+}
@@ -0,0 +1,85 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/codefresh-io/go/venona/pkg/agent"
+	"github.com/codefresh-io/go/venona/pkg/mocks"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeAgent struct {
+	id     string
+	status agent.Status
+}
+
+func (a fakeAgent) ID() string {
+	return a.id
+}
+
+func (a fakeAgent) Status() agent.Status {
+	return a.status
+}
+
+func Test_agentsEndpoints(t *testing.T) {
+	s, err := New(&Options{
+		Logger: &mocks.Logger{},
+		Agents: []Agent{
+			fakeAgent{id: "a", status: agent.Status{Message: "All good"}},
+			fakeAgent{id: "b", status: agent.Status{Message: "All good", Error: "unauthorized"}},
+		},
+	})
+	assert.NoError(t, err)
+
+	tests := []struct {
+		name       string
+		path       string
+		wantStatus int
+		wantBody   string
+	}{
+		{
+			name:       "should list the agents",
+			path:       "/agents",
+			wantStatus: http.StatusOK,
+			wantBody: `[{"id":"a","status":{"message":"All good","time":"0001-01-01T00:00:00Z"}},` +
+				`{"id":"b","status":{"message":"All good","time":"0001-01-01T00:00:00Z","error":"unauthorized"}}]`,
+		},
+		{
+			name:       "should return a single agent",
+			path:       "/agents/b",
+			wantStatus: http.StatusOK,
+			wantBody:   `{"id":"b","status":{"message":"All good","time":"0001-01-01T00:00:00Z","error":"unauthorized"}}`,
+		},
+		{
+			name:       "should not find unknown agent",
+			path:       "/agents/c",
+			wantStatus: http.StatusNotFound,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			s.srv.Handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+			assert.Equal(t, tt.wantStatus, rec.Code)
+			if tt.wantBody != "" {
+				assert.JSONEq(t, tt.wantBody, rec.Body.String())
+			}
+		})
+	}
+}
@@ -37,6 +37,13 @@ type (
 		Port    string
 		Logger  logger.Logger
 		Monitor monitoring.Monitor
+		// AdminToken enables the debug endpoints, requests must be sent with
+		// "Authorization: Bearer <token>"
+		AdminToken string
+		Levels     *logger.Levels
+		Dumper     Dumper
+		// Agents served by the process, their status is exposed on /agents
+		Agents []Agent
 	}
 
 	// Server is an HTTP server that expose API
@@ -62,6 +69,12 @@ func New(opt *Options) (*Server, error) {
 		w.WriteHeader(http.StatusOK)
 		_, _ = w.Write([]byte("OK"))
 	})
+	if len(opt.Agents) != 0 {
+		registerAgents(r, opt.Agents)
+	}
+	if opt.AdminToken != "" {
+		registerAdmin(r, opt.AdminToken, opt.Levels, opt.Dumper, log)
+	}
 
 	srv := &http.Server{
 		Addr:    opt.Port,
@@ -82,7 +95,12 @@ func (s *Server) Start() error {
 	}
 	s.running = true
 	s.log.Info("Starting HTTP server", "addr", s.srv.Addr)
-	return s.srv.ListenAndServe()
+	err := s.srv.ListenAndServe()
+	// Stop closes the server on a graceful termination
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
 // Stop stops the HTTP server
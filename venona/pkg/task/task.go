@@ -55,6 +55,18 @@ type Metadata struct {
 	Account   string `json:"account"`
 	ReName    string `json:"reName"`
 	Workflow  string `json:"workflow"`
+	// Priority of the workflow, higher starts first when the runtime is saturated
+	Priority int `json:"priority,omitempty"`
+}
+
+// LogFields returns the correlation fields of the task to attach to log lines
+func (t *Task) LogFields() []interface{} {
+	return []interface{}{
+		"workflow", t.Metadata.Workflow,
+		"account", t.Metadata.Account,
+		"runtime", t.Metadata.ReName,
+		"task-type", t.Type,
+	}
 }
 
 // AgentTask describes a task of type "AgentTask"
@@ -62,3 +74,44 @@ type AgentTask struct {
 	Type   string                 `json:"type"`
 	Params map[string]interface{} `json:"params"`
 }
+
+// Priority returns the priority of the workflow the tasks belong to, the highest of the
+// metadata priority, the priority of the pod specs and the value of their priorityClassName in classes
+func Priority(tasks []Task, classes map[string]int) int {
+	priority := 0
+	found := false
+	set := func(p int) {
+		if !found || p > priority {
+			priority = p
+			found = true
+		}
+	}
+	for _, t := range tasks {
+		if t.Metadata.Priority != 0 {
+			set(t.Metadata.Priority)
+		}
+		if t.Type != TypeCreatePod {
+			continue
+		}
+		b, err := json.Marshal(t.Spec)
+		if err != nil {
+			continue
+		}
+		pod := struct {
+			Spec struct {
+				Priority          *int   `json:"priority"`
+				PriorityClassName string `json:"priorityClassName"`
+			} `json:"spec"`
+		}{}
+		if err := json.Unmarshal(b, &pod); err != nil {
+			continue
+		}
+		if pod.Spec.Priority != nil {
+			set(*pod.Spec.Priority)
+		}
+		if p, ok := classes[pod.Spec.PriorityClassName]; ok && pod.Spec.PriorityClassName != "" {
+			set(p)
+		}
+	}
+	return priority
+}
@@ -0,0 +1,80 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package task
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func podSpec(spec map[string]interface{}) Task {
+	return Task{
+		Type: TypeCreatePod,
+		Spec: map[string]interface{}{
+			"kind":       "Pod",
+			"apiVersion": "v1",
+			"spec":       spec,
+		},
+	}
+}
+
+func TestPriority(t *testing.T) {
+	classes := map[string]int{"high": 100, "low": -10}
+	tests := []struct {
+		name  string
+		tasks []Task
+		want  int
+	}{
+		{
+			name:  "should default to zero",
+			tasks: []Task{podSpec(map[string]interface{}{}), {Type: TypeCreatePVC}},
+			want:  0,
+		},
+		{
+			name:  "should use the metadata priority",
+			tasks: []Task{{Type: TypeCreatePVC, Metadata: Metadata{Priority: 5}}},
+			want:  5,
+		},
+		{
+			name:  "should use the pod priority",
+			tasks: []Task{podSpec(map[string]interface{}{"priority": 7})},
+			want:  7,
+		},
+		{
+			name:  "should use the priority class",
+			tasks: []Task{podSpec(map[string]interface{}{"priorityClassName": "low"})},
+			want:  -10,
+		},
+		{
+			name:  "should ignore unknown priority classes",
+			tasks: []Task{podSpec(map[string]interface{}{"priorityClassName": "other"})},
+			want:  0,
+		},
+		{
+			name: "should use the highest priority of the workflow",
+			tasks: []Task{
+				{Type: TypeCreatePVC, Metadata: Metadata{Priority: 5}},
+				podSpec(map[string]interface{}{"priorityClassName": "high"}),
+			},
+			want: 100,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.want, Priority(tt.tasks, classes))
+		})
+	}
+}
@@ -0,0 +1,124 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transport builds the HTTP transports of the outbound requests of the agent
+package transport
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+
+	"golang.org/x/net/http/httpproxy"
+)
+
+var (
+	errUnknownTLSVersion = errors.New("unknown TLS version")
+	errInvalidCA         = errors.New("no certificates found in CA file")
+	errCertWithoutKey    = errors.New("client certificate and key must be set together")
+	tlsVersions          = map[string]uint16{
+		"1.0": tls.VersionTLS10,
+		"1.1": tls.VersionTLS11,
+		"1.2": tls.VersionTLS12,
+		"1.3": tls.VersionTLS13,
+	}
+)
+
+// Options for building a transport, the zero value is the default transport
+// with the proxy taken from the environment
+type Options struct {
+	// Insecure disables certificate validation
+	Insecure bool
+	// CAFile is a PEM bundle trusted in addition to the system roots
+	CAFile string
+	// CertFile and KeyFile are the client certificate for mTLS
+	CertFile string
+	KeyFile  string
+	// MinTLSVersion is one of 1.0, 1.1, 1.2 or 1.3
+	MinTLSVersion string
+	// HTTPProxy, HTTPSProxy and NoProxy override HTTP_PROXY, HTTPS_PROXY and NO_PROXY
+	HTTPProxy  string
+	HTTPSProxy string
+	NoProxy    string
+}
+
+// New builds a transport from the options
+func New(opt Options) (*http.Transport, error) {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	tlsConfig, err := opt.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+	t.TLSClientConfig = tlsConfig
+	t.Proxy = opt.proxy()
+	return t, nil
+}
+
+func (opt Options) tlsConfig() (*tls.Config, error) {
+	// #nosec
+	config := &tls.Config{InsecureSkipVerify: opt.Insecure}
+	if opt.MinTLSVersion != "" {
+		v, ok := tlsVersions[opt.MinTLSVersion]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", errUnknownTLSVersion, opt.MinTLSVersion)
+		}
+		config.MinVersion = v
+	}
+	if opt.CAFile != "" {
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		pem, err := ioutil.ReadFile(opt.CAFile)
+		if err != nil {
+			return nil, err
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%w: %s", errInvalidCA, opt.CAFile)
+		}
+		config.RootCAs = pool
+	}
+	if (opt.CertFile == "") != (opt.KeyFile == "") {
+		return nil, errCertWithoutKey
+	}
+	if opt.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(opt.CertFile, opt.KeyFile)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = []tls.Certificate{cert}
+	}
+	return config, nil
+}
+
+func (opt Options) proxy() func(*http.Request) (*url.URL, error) {
+	config := httpproxy.FromEnvironment()
+	if opt.HTTPProxy != "" {
+		config.HTTPProxy = opt.HTTPProxy
+	}
+	if opt.HTTPSProxy != "" {
+		config.HTTPSProxy = opt.HTTPSProxy
+	}
+	if opt.NoProxy != "" {
+		config.NoProxy = opt.NoProxy
+	}
+	proxy := config.ProxyFunc()
+	return func(req *http.Request) (*url.URL, error) {
+		return proxy(req.URL)
+	}
+}
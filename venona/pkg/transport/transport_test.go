@@ -0,0 +1,109 @@
+// Copyright 2020 The Codefresh Authors.
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//     http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transport
+
+import (
+	"crypto/tls"
+	"encoding/pem"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		opt     Options
+		wantErr bool
+	}{
+		{
+			name: "should build default transport",
+			opt:  Options{},
+		},
+		{
+			name: "should accept min TLS version",
+			opt:  Options{MinTLSVersion: "1.3"},
+		},
+		{
+			name:    "should reject unknown TLS version",
+			opt:     Options{MinTLSVersion: "2.0"},
+			wantErr: true,
+		},
+		{
+			name:    "should reject certificate without key",
+			opt:     Options{CertFile: "client.crt"},
+			wantErr: true,
+		},
+		{
+			name:    "should fail on missing CA file",
+			opt:     Options{CAFile: "/does/not/exist"},
+			wantErr: true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := New(tt.opt)
+			if tt.wantErr {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+		})
+	}
+}
+
+func TestNew_CAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+	dir, err := ioutil.TempDir("", "transport")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	ca := filepath.Join(dir, "ca.pem")
+	assert.NoError(t, ioutil.WriteFile(ca, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw}), 0600))
+
+	_, err = (&http.Client{Transport: mustNew(t, Options{})}).Get(srv.URL)
+	assert.Error(t, err, "the server certificate must not be trusted by default")
+
+	res, err := (&http.Client{Transport: mustNew(t, Options{CAFile: ca, MinTLSVersion: "1.2"})}).Get(srv.URL)
+	assert.NoError(t, err)
+	defer res.Body.Close()
+	assert.Equal(t, http.StatusOK, res.StatusCode)
+	assert.GreaterOrEqual(t, res.TLS.Version, uint16(tls.VersionTLS12))
+}
+
+func TestOptions_proxy(t *testing.T) {
+	proxy := Options{HTTPSProxy: "http://proxy.local:3128", NoProxy: "internal.local"}.proxy()
+
+	req := httptest.NewRequest(http.MethodGet, "https://g.codefresh.io/api", nil)
+	u, err := proxy(req)
+	assert.NoError(t, err)
+	assert.Equal(t, "proxy.local:3128", u.Host)
+
+	req = httptest.NewRequest(http.MethodGet, "https://internal.local/api", nil)
+	u, err = proxy(req)
+	assert.NoError(t, err)
+	assert.Nil(t, u)
+}
+
+func mustNew(t *testing.T, opt Options) *http.Transport {
+	tr, err := New(opt)
+	assert.NoError(t, err)
+	return tr
+}
@@ -0,0 +1,720 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"os"
+
+	"github.com/codefresh-io/go-sdk/pkg/codefresh"
+	"github.com/codefresh-io/venona/venonactl/pkg/kube"
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
+	"github.com/codefresh-io/venona/venonactl/pkg/store"
+	"github.com/spf13/cobra"
+	"gopkg.in/yaml.v2"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/clientcmd"
+)
+
+const (
+	applyActionInstall   = "install"
+	applyActionUpgrade   = "upgrade"
+	applyActionUninstall = "uninstall"
+	applyActionAttach    = "attach"
+	applyActionDetach    = "detach"
+	applyActionRestart   = "restart"
+)
+
+var applyCmdOpt struct {
+	file         string
+	dryRun       bool
+	restartAgent bool
+	prune        bool
+}
+
+type (
+	// runnerSpec - the declarative description of the runner, see "Apply" in the README
+	runnerSpec struct {
+		Codefresh struct {
+			Host     string `yaml:"host"`
+			Token    string `yaml:"token"`
+			Insecure bool   `yaml:"insecure"`
+		} `yaml:"codefresh"`
+		Agent    agentSpec     `yaml:"agent"`
+		Runtimes []runtimeSpec `yaml:"runtimes"`
+		Monitor  *monitorSpec  `yaml:"monitor"`
+		AppProxy *appProxySpec `yaml:"appProxy"`
+	}
+
+	// kubeSpec - where a component is installed, empty fields are taken from the agent
+	kubeSpec struct {
+		Context    string `yaml:"context"`
+		Namespace  string `yaml:"namespace"`
+		ConfigPath string `yaml:"configPath"`
+	}
+
+	agentSpec struct {
+		ID     string                 `yaml:"id"`
+		Token  string                 `yaml:"token"`
+		Kube   kubeSpec               `yaml:"kube"`
+		Values map[string]interface{} `yaml:"values"`
+	}
+
+	runtimeSpec struct {
+		Name           string                 `yaml:"name"`
+		Kube           kubeSpec               `yaml:"kube"`
+		StorageClass   string                 `yaml:"storageClass"`
+		Storage        map[string]interface{} `yaml:"storage"`
+		Attach         *bool                  `yaml:"attach"`
+		Host           string                 `yaml:"host"`
+		ServiceAccount string                 `yaml:"serviceAccount"`
+		Values         map[string]interface{} `yaml:"values"`
+	}
+
+	monitorSpec struct {
+		Enabled   *bool                  `yaml:"enabled"`
+		ClusterID string                 `yaml:"clusterId"`
+		Helm3     bool                   `yaml:"helm3"`
+		Kube      kubeSpec               `yaml:"kube"`
+		Values    map[string]interface{} `yaml:"values"`
+	}
+
+	appProxySpec struct {
+		Enabled      *bool                  `yaml:"enabled"`
+		Host         string                 `yaml:"host"`
+		IngressClass string                 `yaml:"ingressClass"`
+		Kube         kubeSpec               `yaml:"kube"`
+		Values       map[string]interface{} `yaml:"values"`
+	}
+
+	// planStep - a single change of the runner, executed by run
+	planStep struct {
+		planStepDocument
+		run func(ctx context.Context) error
+	}
+)
+
+var applyCmd = &cobra.Command{
+	Use:   "apply",
+	Short: "Install, upgrade and attach the components of the runner declared in a spec file",
+	Long:  "Compares the agent, the runtimes, their attachment to the agent, the monitor and the app-proxy declared in the spec file with the clusters and executes the steps needed to reach it. Applying the same spec again only upgrades the installed components. Runtimes that are no longer declared are detached, with --prune they are also uninstalled",
+	Run: func(cmd *cobra.Command, args []string) {
+		lgr := createLogger("Apply", verbose, logFormatter)
+		buildBasicStore(lgr)
+		extendStoreWithKubeClient(lgr)
+		s := store.GetStore()
+
+		if applyCmdOpt.file == "" {
+			dieOnError(fmt.Errorf("Spec file is required, use --file"))
+		}
+		spec, err := loadRunnerSpec(applyCmdOpt.file)
+		dieOnError(err)
+		dieOnError(spec.complete(s.KubernetesAPI.ConfigPath))
+
+		steps, err := buildPlan(cmd.Context(), lgr, spec)
+		dieOnError(err)
+		if applyCmdOpt.dryRun {
+			printPlan(steps, true)
+			return
+		}
+		if !isStructuredOutput() {
+			printPlan(steps, false)
+			fmt.Println()
+		}
+		for _, step := range steps {
+			lgr.Info(fmt.Sprintf("Running %s of %s", step.Action, step.Component), "Name", step.Name, "Kube-Context-Name", step.Context, "Namespace", step.Namespace)
+			if err := step.run(cmd.Context()); err != nil {
+				dieOnError(fmt.Errorf("Failed to %s %s: %w", step.Action, step.Component, err))
+			}
+		}
+		lgr.Info("Runner spec applied Successfully")
+		if isStructuredOutput() {
+			printPlan(steps, false)
+		}
+	},
+}
+
+// loadRunnerSpec - reads the spec from the file or from stdin when the file is "-",
+// environment variables in the file are expanded
+func loadRunnerSpec(file string) (*runnerSpec, error) {
+	var data []byte
+	var err error
+	if file == "-" {
+		data, err = ioutil.ReadAll(os.Stdin)
+	} else {
+		data, err = ioutil.ReadFile(file)
+	}
+	if err != nil {
+		return nil, err
+	}
+	spec := &runnerSpec{}
+	if err := yaml.UnmarshalStrict([]byte(os.ExpandEnv(string(data))), spec); err != nil {
+		return nil, fmt.Errorf("Cannot parse spec %s: %v", file, err)
+	}
+	spec.Agent.Values = normalizeValues(spec.Agent.Values)
+	for i := range spec.Runtimes {
+		spec.Runtimes[i].Storage = normalizeValues(spec.Runtimes[i].Storage)
+		spec.Runtimes[i].Values = normalizeValues(spec.Runtimes[i].Values)
+	}
+	if spec.Monitor != nil {
+		spec.Monitor.Values = normalizeValues(spec.Monitor.Values)
+	}
+	if spec.AppProxy != nil {
+		spec.AppProxy.Values = normalizeValues(spec.AppProxy.Values)
+	}
+	return spec, nil
+}
+
+// normalizeValues - yaml decodes nested maps with interface{} keys, the templates expect string keys
+func normalizeValues(m map[string]interface{}) map[string]interface{} {
+	if m == nil {
+		return nil
+	}
+	out := make(map[string]interface{}, len(m))
+	for k, v := range m {
+		out[k] = normalizeValue(v)
+	}
+	return out
+}
+
+func normalizeValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		out := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			out[fmt.Sprint(k)] = normalizeValue(e)
+		}
+		return out
+	case map[string]interface{}:
+		return normalizeValues(v)
+	case []interface{}:
+		out := make([]interface{}, len(v))
+		for i, e := range v {
+			out[i] = normalizeValue(e)
+		}
+		return out
+	}
+	return v
+}
+
+// complete - validates the spec and fills the defaults
+func (spec *runnerSpec) complete(configPath string) error {
+	if spec.Codefresh.Host == "" {
+		spec.Codefresh.Host = cfAPIHost
+	}
+	if spec.Codefresh.Host == "" {
+		spec.Codefresh.Host = "https://g.codefresh.io"
+	}
+	if spec.Codefresh.Token == "" {
+		spec.Codefresh.Token = cfAPIToken
+	}
+	if spec.Agent.Token == "" {
+		spec.Agent.Token = spec.Codefresh.Token
+	}
+	if spec.Agent.ID == "" {
+		return fmt.Errorf("agent.id is required")
+	}
+	if spec.Agent.Token == "" {
+		return fmt.Errorf("agent.token is required")
+	}
+	if spec.Agent.Kube.Namespace == "" {
+		return fmt.Errorf("agent.kube.namespace is required")
+	}
+	if spec.Agent.Kube.ConfigPath == "" {
+		spec.Agent.Kube.ConfigPath = configPath
+	}
+	if spec.Agent.Kube.Context == "" {
+		config, err := clientcmd.LoadFromFile(spec.Agent.Kube.ConfigPath)
+		if err != nil {
+			return fmt.Errorf("Cannot read kubeconfig %s: %v", spec.Agent.Kube.ConfigPath, err)
+		}
+		spec.Agent.Kube.Context = config.CurrentContext
+	}
+
+	names := map[string]bool{}
+	namespaces := map[kubeSpec]string{}
+	for i := range spec.Runtimes {
+		rt := &spec.Runtimes[i]
+		if rt.Name == "" {
+			return fmt.Errorf("runtimes[%d].name is required", i)
+		}
+		if names[rt.Name] {
+			return fmt.Errorf("Runtime %s is declared more than once", rt.Name)
+		}
+		names[rt.Name] = true
+		rt.Kube = rt.Kube.withDefaults(spec.Agent.Kube)
+		place := kubeSpec{Context: rt.Kube.Context, Namespace: rt.Kube.Namespace}
+		if other, ok := namespaces[place]; ok {
+			return fmt.Errorf("Runtimes %s and %s are installed in the same namespace %s of %s", other, rt.Name, place.Namespace, place.Context)
+		}
+		namespaces[place] = rt.Name
+		if spec.Codefresh.Token == "" {
+			return fmt.Errorf("codefresh.token is required in order to install runtimes")
+		}
+	}
+
+	if m := spec.Monitor; m != nil {
+		m.Kube = m.Kube.withDefaults(spec.Agent.Kube)
+		if m.enabled() && m.ClusterID == "" {
+			return fmt.Errorf("monitor.clusterId is required in order to install monitor")
+		}
+		if m.enabled() && spec.Codefresh.Token == "" {
+			return fmt.Errorf("codefresh.token is required in order to install monitor")
+		}
+	}
+	if a := spec.AppProxy; a != nil {
+		a.Kube = a.Kube.withDefaults(spec.Agent.Kube)
+		if a.enabled() && a.Host == "" {
+			return fmt.Errorf("appProxy.host is required in order to install app-proxy")
+		}
+	}
+	return nil
+}
+
+func (k kubeSpec) withDefaults(d kubeSpec) kubeSpec {
+	if k.Context == "" {
+		k.Context = d.Context
+	}
+	if k.Namespace == "" {
+		k.Namespace = d.Namespace
+	}
+	if k.ConfigPath == "" {
+		k.ConfigPath = d.ConfigPath
+	}
+	return k
+}
+
+func (k kubeSpec) builder() kube.Kube {
+	return getKubeClientBuilder(k.Context, k.Namespace, k.ConfigPath, false, false)
+}
+
+// attach - runtimes are attached to the agent unless attach: false is set
+func (rt *runtimeSpec) attach() bool {
+	return rt.Attach == nil || *rt.Attach
+}
+
+// enabled - a declared monitor is installed unless enabled: false is set, then it is uninstalled
+func (m *monitorSpec) enabled() bool {
+	return m.Enabled == nil || *m.Enabled
+}
+
+// enabled - a declared app-proxy is installed unless enabled: false is set, then it is uninstalled
+func (a *appProxySpec) enabled() bool {
+	return a.Enabled == nil || *a.Enabled
+}
+
+func newPlanStep(action, component, name string, k kubeSpec, run func(ctx context.Context) error) *planStep {
+	return &planStep{
+		planStepDocument: planStepDocument{
+			Action:    action,
+			Component: component,
+			Name:      name,
+			Context:   k.Context,
+			Namespace: k.Namespace,
+		},
+		run: run,
+	}
+}
+
+func installOrUpgrade(installed bool) string {
+	if installed {
+		return applyActionUpgrade
+	}
+	return applyActionInstall
+}
+
+// buildPlan - compares the spec with the inventories of the components and the runtimes attached to the agent
+func buildPlan(ctx context.Context, lgr logger.Logger, spec *runnerSpec) ([]*planStep, error) {
+	var steps []*planStep
+	agentKube := spec.Agent.Kube
+
+	installed, err := plugins.IsInstalled(ctx, agentKube.builder(), agentKube.Namespace, plugins.VenonaPluginType)
+	if err != nil {
+		return nil, fmt.Errorf("agent: %w", err)
+	}
+	steps = append(steps, newPlanStep(installOrUpgrade(installed), "agent", spec.Agent.ID, agentKube, func(ctx context.Context) error {
+		return applyAgent(ctx, lgr, spec, installed)
+	}))
+
+	declared := map[string]bool{}
+	for i := range spec.Runtimes {
+		rt := &spec.Runtimes[i]
+		if rt.attach() {
+			declared[rt.Name] = true
+		}
+		installed, err := plugins.IsInstalled(ctx, rt.Kube.builder(), rt.Kube.Namespace, plugins.RuntimeEnvironmentPluginType)
+		if err != nil {
+			return nil, fmt.Errorf("runtime %s: %w", rt.Name, err)
+		}
+		steps = append(steps, newPlanStep(installOrUpgrade(installed), "runtime", rt.Name, rt.Kube, func(ctx context.Context) error {
+			return applyRuntime(ctx, lgr, spec, rt, installed)
+		}))
+	}
+
+	attached, err := plugins.AttachedRuntimes(ctx, agentKube.builder(), agentKube.Namespace)
+	if err != nil {
+		return nil, fmt.Errorf("attached runtimes: %w", err)
+	}
+	isAttached := map[string]bool{}
+	changed := false
+	for _, name := range attached {
+		isAttached[name] = true
+		if declared[name] {
+			continue
+		}
+		name := name
+		changed = true
+		steps = append(steps, newPlanStep(applyActionDetach, "runtime", name, agentKube, func(ctx context.Context) error {
+			return applyDetach(ctx, lgr, spec, name)
+		}))
+		if applyCmdOpt.prune {
+			step, err := pruneRuntimeStep(lgr, spec, name)
+			if err != nil {
+				return nil, fmt.Errorf("runtime %s: %w", name, err)
+			}
+			steps = append(steps, step)
+		}
+	}
+	for i := range spec.Runtimes {
+		rt := &spec.Runtimes[i]
+		if !rt.attach() || isAttached[rt.Name] {
+			continue
+		}
+		changed = true
+		steps = append(steps, newPlanStep(applyActionAttach, "runtime", rt.Name, agentKube, func(ctx context.Context) error {
+			return applyAttach(ctx, lgr, spec, rt)
+		}))
+	}
+	if changed && applyCmdOpt.restartAgent {
+		steps = append(steps, newPlanStep(applyActionRestart, "agent", spec.Agent.ID, agentKube, func(ctx context.Context) error {
+			return restartAgent(ctx, spec)
+		}))
+	}
+
+	if m := spec.Monitor; m != nil {
+		step, err := optionalPlanStep(ctx, "monitor", plugins.MonitorAgentPluginType, m.enabled(), m.Kube,
+			func(ctx context.Context, installed bool) error { return applyMonitor(ctx, lgr, spec, installed) },
+			func(ctx context.Context) error {
+				return deleteComponents(ctx, lgr, spec, m.Kube, nil, plugins.MonitorAgentPluginType)
+			})
+		if err != nil {
+			return nil, err
+		}
+		if step != nil {
+			steps = append(steps, step)
+		}
+	}
+	if a := spec.AppProxy; a != nil {
+		step, err := optionalPlanStep(ctx, "app-proxy", plugins.AppProxyPluginType, a.enabled(), a.Kube,
+			func(ctx context.Context, installed bool) error { return applyAppProxy(ctx, lgr, spec, installed) },
+			func(ctx context.Context) error {
+				return deleteComponents(ctx, lgr, spec, a.Kube, a.Values, plugins.AppProxyPluginType)
+			})
+		if err != nil {
+			return nil, err
+		}
+		if step != nil {
+			steps = append(steps, step)
+		}
+	}
+	return steps, nil
+}
+
+// pruneRuntimeStep - uninstalls a runtime that is no longer declared from the namespace saved in Codefresh for it
+func pruneRuntimeStep(lgr logger.Logger, spec *runnerSpec, name string) (*planStep, error) {
+	if spec.Codefresh.Token == "" {
+		return nil, fmt.Errorf("codefresh.token is required in order to prune runtimes")
+	}
+	client := codefresh.New(&codefresh.ClientOptions{
+		Auth: codefresh.AuthOptions{
+			Token: spec.Codefresh.Token,
+		},
+		Host: spec.Codefresh.Host,
+	})
+	re, err := client.RuntimeEnvironments().Get(name)
+	if err != nil {
+		return nil, err
+	}
+	if re == nil || re.RuntimeScheduler.Cluster.Namespace == "" {
+		return nil, fmt.Errorf("Runtime-Environment %s not found", name)
+	}
+	k := kubeSpec{
+		Context:    re.RuntimeScheduler.Cluster.ClusterProvider.Selector,
+		Namespace:  re.RuntimeScheduler.Cluster.Namespace,
+		ConfigPath: spec.Agent.Kube.ConfigPath,
+	}
+	pluginTypes := []string{plugins.RuntimeEnvironmentPluginType, plugins.EnginePluginType}
+	if isUsingDefaultStorageClass(re.RuntimeScheduler.Pvcs.Dind.StorageClassName) {
+		pluginTypes = append(pluginTypes, plugins.VolumeProvisionerPluginType)
+	}
+	return newPlanStep(applyActionUninstall, "runtime", name, k, func(ctx context.Context) error {
+		return deleteComponents(ctx, lgr, spec, k, nil, pluginTypes...)
+	}), nil
+}
+
+// optionalPlanStep - installs or upgrades an enabled component, uninstalls a disabled one that is installed
+func optionalPlanStep(ctx context.Context, component, pluginType string, enabled bool, k kubeSpec, install func(ctx context.Context, installed bool) error, uninstall func(ctx context.Context) error) (*planStep, error) {
+	installed, err := plugins.IsInstalled(ctx, k.builder(), k.Namespace, pluginType)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", component, err)
+	}
+	if enabled {
+		return newPlanStep(installOrUpgrade(installed), component, "", k, func(ctx context.Context) error {
+			return install(ctx, installed)
+		}), nil
+	}
+	if installed {
+		return newPlanStep(applyActionUninstall, component, "", k, uninstall), nil
+	}
+	return nil, nil
+}
+
+// useKube - points the store at the cluster of the component, the values of the templates are built from it
+func useKube(spec *runnerSpec, k kubeSpec) *store.Values {
+	s := store.GetStore()
+	s.KubernetesAPI.ContextName = k.Context
+	s.KubernetesAPI.Namespace = k.Namespace
+	s.KubernetesAPI.ConfigPath = k.ConfigPath
+	s.KubernetesAPI.InCluster = false
+	s.Insecure = spec.Codefresh.Insecure
+	s.CodefreshAPI = &store.CodefreshAPI{
+		Host: spec.Codefresh.Host,
+	}
+	s.AgentAPI = &store.AgentAPI{}
+	return s
+}
+
+// installComponents - installs the plugins, or upgrades them when upgrade is set. Upgrade keeps what
+// was generated on install, like tokens and certificates, and what is owned by other plugins
+func installComponents(ctx context.Context, lgr logger.Logger, opt *plugins.InstallOptions, upgrade *plugins.UpgradeOptions, overrides map[string]interface{}, pluginTypes ...string) error {
+	builder := plugins.NewBuilder(lgr)
+	for _, t := range pluginTypes {
+		builder.Add(t)
+	}
+	values := mergeMaps(store.GetStore().BuildValues(), overrides)
+	if upgrade == nil {
+		_, _, err := installPlugins(ctx, lgr, builder, opt, values)
+		return err
+	}
+	upgrade.Declared = overrides
+	for _, p := range builder.Get() {
+		res, err := p.Upgrade(ctx, upgrade, values)
+		if err != nil {
+			return err
+		}
+		if res != nil {
+			values = res
+		}
+	}
+	return nil
+}
+
+// upgradeOptions - returns the options to upgrade an installed component, nil when it is not installed
+func upgradeOptions(installed bool, name string, k kubeSpec, runtimeEnvironment string) *plugins.UpgradeOptions {
+	if !installed {
+		return nil
+	}
+	return &plugins.UpgradeOptions{
+		Name:               name,
+		ClusterName:        k.Context,
+		ClusterNamespace:   k.Namespace,
+		RuntimeEnvironment: runtimeEnvironment,
+		KubeBuilder:        k.builder(),
+	}
+}
+
+func deleteComponents(ctx context.Context, lgr logger.Logger, spec *runnerSpec, k kubeSpec, overrides map[string]interface{}, pluginTypes ...string) error {
+	s := useKube(spec, k)
+	builder := plugins.NewBuilder(lgr)
+	for _, t := range pluginTypes {
+		builder.Add(t)
+	}
+	opt := &plugins.DeleteOptions{
+		KubeBuilder:      k.builder(),
+		ClusterNamespace: k.Namespace,
+	}
+	values := mergeMaps(s.BuildValues(), overrides)
+	for _, p := range builder.Get() {
+		if err := p.Delete(ctx, opt, values); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyAgent(ctx context.Context, lgr logger.Logger, spec *runnerSpec, installed bool) error {
+	k := spec.Agent.Kube
+	s := useKube(spec, k)
+	s.AgentAPI = &store.AgentAPI{
+		Token: spec.Agent.Token,
+		Id:    spec.Agent.ID,
+	}
+	opt := &plugins.InstallOptions{
+		CodefreshHost:    spec.Codefresh.Host,
+		ClusterName:      k.Context,
+		ClusterNamespace: k.Namespace,
+		KubeBuilder:      k.builder(),
+	}
+	return installComponents(ctx, lgr, opt, upgradeOptions(installed, s.AppName, k, ""), spec.Agent.Values, plugins.VenonaPluginType)
+}
+
+func applyRuntime(ctx context.Context, lgr logger.Logger, spec *runnerSpec, rt *runtimeSpec, installed bool) error {
+	s := useKube(spec, rt.Kube)
+	s.AgentAPI = &store.AgentAPI{
+		Token: spec.Codefresh.Token,
+	}
+	s.VolumeProvisioner.Resources = defaultVolumeProvResources
+
+	isDefault := isUsingDefaultStorageClass(rt.StorageClass)
+	opt := &plugins.InstallOptions{
+		StorageClass:          rt.StorageClass,
+		IsDefaultStorageClass: isDefault,
+		CodefreshHost:         spec.Codefresh.Host,
+		CodefreshToken:        spec.Codefresh.Token,
+		RuntimeEnvironment:    rt.Name,
+		ClusterName:           rt.Kube.Context,
+		ClusterNamespace:      rt.Kube.Namespace,
+		Insecure:              spec.Codefresh.Insecure,
+		KubeBuilder:           rt.Kube.builder(),
+	}
+	pluginTypes := []string{plugins.RuntimeEnvironmentPluginType, plugins.EnginePluginType}
+	if isDefault {
+		opt.StorageClass = plugins.DefaultStorageClassNamePrefix
+		pluginTypes = append(pluginTypes, plugins.VolumeProvisionerPluginType)
+	}
+	overrides := rt.Values
+	if rt.Storage != nil {
+		overrides = mergeMaps(map[string]interface{}{"Storage": rt.Storage}, rt.Values)
+	}
+	return installComponents(ctx, lgr, opt, upgradeOptions(installed, s.AppName, rt.Kube, rt.Name), overrides, pluginTypes...)
+}
+
+func applyAttach(ctx context.Context, lgr logger.Logger, spec *runnerSpec, rt *runtimeSpec) error {
+	s := useKube(spec, spec.Agent.Kube)
+	serviceAccount := rt.ServiceAccount
+	if serviceAccount == "" {
+		serviceAccount = s.AppName
+	}
+	opt := &plugins.InstallOptions{
+		ClusterNamespace:      spec.Agent.Kube.Namespace,
+		AgentNamespace:        spec.Agent.Kube.Namespace,
+		ClusterHost:           rt.Host,
+		RuntimeEnvironment:    rt.Name,
+		RuntimeClusterName:    rt.Kube.Namespace,
+		RuntimeServiceAccount: serviceAccount,
+		KubeBuilder:           rt.Kube.builder(),
+		AgentKubeBuilder:      spec.Agent.Kube.builder(),
+	}
+	return installComponents(ctx, lgr, opt, nil, nil, plugins.RuntimeAttachType)
+}
+
+func applyDetach(ctx context.Context, lgr logger.Logger, spec *runnerSpec, name string) error {
+	s := useKube(spec, spec.Agent.Kube)
+	builder := plugins.NewBuilder(lgr)
+	builder.Add(plugins.RuntimeAttachType)
+	opt := &plugins.DeleteOptions{
+		AgentKubeBuilder:   spec.Agent.Kube.builder(),
+		AgentNamespace:     spec.Agent.Kube.Namespace,
+		RuntimeEnvironment: name,
+	}
+	for _, p := range builder.Get() {
+		if err := p.Delete(ctx, opt, s.BuildValues()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restartAgent - deletes the pods of the agent so the runtimes configuration is read again
+func restartAgent(ctx context.Context, spec *runnerSpec) error {
+	cs, err := spec.Agent.Kube.builder().BuildClient()
+	if err != nil {
+		return err
+	}
+	pods := cs.CoreV1().Pods(spec.Agent.Kube.Namespace)
+	list, err := pods.List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%s", store.GetStore().AppName)})
+	if err != nil {
+		return err
+	}
+	for _, pod := range list.Items {
+		if err := pods.Delete(ctx, pod.Name, metav1.DeleteOptions{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func applyMonitor(ctx context.Context, lgr logger.Logger, spec *runnerSpec, installed bool) error {
+	m := spec.Monitor
+	s := useKube(spec, m.Kube)
+	s.CodefreshAPI.Token = spec.Codefresh.Token
+	s.ClusterId = m.ClusterID
+	s.Helm3 = m.Helm3
+	opt := &plugins.InstallOptions{
+		ClusterNamespace: m.Kube.Namespace,
+		KubeBuilder:      m.Kube.builder(),
+	}
+	return installComponents(ctx, lgr, opt, upgradeOptions(installed, store.MonitorApplicationName, m.Kube, ""), m.Values, plugins.MonitorAgentPluginType)
+}
+
+func applyAppProxy(ctx context.Context, lgr logger.Logger, spec *runnerSpec, installed bool) error {
+	a := spec.AppProxy
+	useKube(spec, a.Kube)
+	opt := &plugins.InstallOptions{
+		CodefreshHost:    spec.Codefresh.Host,
+		ClusterName:      a.Kube.Context,
+		ClusterNamespace: a.Kube.Namespace,
+		KubeBuilder:      a.Kube.builder(),
+	}
+	ingress := map[string]interface{}{
+		"AppProxy": map[string]interface{}{
+			"Ingress": map[string]interface{}{
+				"Host":         a.Host,
+				"IngressClass": a.IngressClass,
+			},
+		},
+	}
+	return installComponents(ctx, lgr, opt, upgradeOptions(installed, store.AppProxyApplicationName, a.Kube, ""), mergeMaps(ingress, a.Values), plugins.AppProxyPluginType)
+}
+
+func printPlan(steps []*planStep, dryRun bool) {
+	if isStructuredOutput() {
+		doc := &applyDocument{DryRun: dryRun, Steps: []planStepDocument{}}
+		for _, step := range steps {
+			doc.Steps = append(doc.Steps, step.planStepDocument)
+		}
+		printDocument(doc)
+		return
+	}
+	table := createTable()
+	table.SetHeader([]string{"Action", "Component", "Name", "Context", "Namespace"})
+	for _, step := range steps {
+		table.Append([]string{step.Action, step.Component, step.Name, step.Context, step.Namespace})
+	}
+	table.Render()
+}
+
+func init() {
+	rootCmd.AddCommand(applyCmd)
+	applyCmd.Flags().StringVarP(&applyCmdOpt.file, "file", "f", "", "Path to the runner spec, use - to read it from stdin")
+	applyCmd.Flags().BoolVar(&applyCmdOpt.dryRun, "dry-run", false, "Print the plan without changing anything")
+	applyCmd.Flags().BoolVar(&applyCmdOpt.restartAgent, "restart-agent", true, "Restart the agent after runtimes are attached or detached")
+	applyCmd.Flags().BoolVar(&applyCmdOpt.prune, "prune", false, "Uninstall the runtimes that are detached because they are no longer declared")
+}
@@ -0,0 +1,486 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj/kubeobjtest"
+	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
+	"github.com/codefresh-io/venona/venonactl/pkg/store"
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+// kubeServer - an api server with the secrets the plan is built from
+func kubeServer(t *testing.T, objects ...interface{}) *kubeobjtest.Server {
+	srv := kubeobjtest.NewServer()
+	t.Cleanup(srv.Close)
+	for _, obj := range objects {
+		srv.Put(obj)
+	}
+	return srv
+}
+
+// kubeConfig - writes a kubeconfig with a context per name, all pointing at the server
+func kubeConfig(t *testing.T, srv *kubeobjtest.Server, contexts ...string) string {
+	path := filepath.Join(t.TempDir(), "config")
+	if err := ioutil.WriteFile(path, []byte(srv.Kubeconfig(contexts...)), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func installedSecret(namespace, component string) *v1.Secret {
+	return &v1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: "venonactl-inventory-" + component, Namespace: namespace},
+		Data:       map[string][]byte{"objects": []byte("[]")},
+	}
+}
+
+func runnerconfSecret(namespace string, runtimes ...string) *v1.Secret {
+	data := map[string][]byte{}
+	for _, name := range runtimes {
+		data[strings.ReplaceAll(name, "/", "_")+".runtime.yaml"] = []byte(fmt.Sprintf("name: %s\n", name))
+	}
+	return &v1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: "runnerconf", Namespace: namespace},
+		Data:       data,
+	}
+}
+
+func TestBuildPlan(t *testing.T) {
+	codefreshServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/runtime-environments/ctx/old" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprint(w, `{"metadata":{"name":"ctx/old"},"runtimeScheduler":{"cluster":{"clusterProvider":{"selector":"other"},"namespace":"old"},"pvcs":{"dind":{"storageClassName":"fast-ssd"}}}}`)
+	}))
+	defer codefreshServer.Close()
+
+	disabled := false
+	newSpec := func(configPath string) *runnerSpec {
+		spec := &runnerSpec{
+			Agent: agentSpec{ID: "agent", Token: "token", Kube: kubeSpec{Context: "ctx", Namespace: "agent", ConfigPath: configPath}},
+			Runtimes: []runtimeSpec{
+				{Name: "ctx/runtime", Kube: kubeSpec{Namespace: "runtime"}},
+				{Name: "ctx/detached", Kube: kubeSpec{Namespace: "detached"}, Attach: &disabled},
+			},
+			Monitor:  &monitorSpec{Enabled: &disabled},
+			AppProxy: &appProxySpec{Host: "app-proxy.example.com"},
+		}
+		spec.Codefresh.Host = codefreshServer.URL
+		spec.Codefresh.Token = "token"
+		return spec
+	}
+	tests := []struct {
+		name    string
+		secrets []interface{}
+		prune   bool
+		want    []planStepDocument
+		wantErr string
+	}{
+		{
+			name: "should install everything on a new cluster",
+			want: []planStepDocument{
+				{Action: applyActionInstall, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionInstall, Component: "runtime", Name: "ctx/runtime", Context: "ctx", Namespace: "runtime"},
+				{Action: applyActionInstall, Component: "runtime", Name: "ctx/detached", Context: "ctx", Namespace: "detached"},
+				{Action: applyActionAttach, Component: "runtime", Name: "ctx/runtime", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionRestart, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionInstall, Component: "app-proxy", Context: "ctx", Namespace: "agent"},
+			},
+		},
+		{
+			name: "should upgrade installed components and uninstall a disabled monitor",
+			secrets: []interface{}{
+				installedSecret("agent", plugins.VenonaPluginType),
+				installedSecret("runtime", plugins.RuntimeEnvironmentPluginType),
+				installedSecret("agent", plugins.MonitorAgentPluginType),
+				installedSecret("agent", plugins.AppProxyPluginType),
+				runnerconfSecret("agent", "ctx/runtime"),
+			},
+			want: []planStepDocument{
+				{Action: applyActionUpgrade, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionUpgrade, Component: "runtime", Name: "ctx/runtime", Context: "ctx", Namespace: "runtime"},
+				{Action: applyActionInstall, Component: "runtime", Name: "ctx/detached", Context: "ctx", Namespace: "detached"},
+				{Action: applyActionUninstall, Component: "monitor", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionUpgrade, Component: "app-proxy", Context: "ctx", Namespace: "agent"},
+			},
+		},
+		{
+			name:    "should detach runtimes that are not declared",
+			secrets: []interface{}{runnerconfSecret("agent", "ctx/runtime", "ctx/old")},
+			want: []planStepDocument{
+				{Action: applyActionInstall, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionInstall, Component: "runtime", Name: "ctx/runtime", Context: "ctx", Namespace: "runtime"},
+				{Action: applyActionInstall, Component: "runtime", Name: "ctx/detached", Context: "ctx", Namespace: "detached"},
+				{Action: applyActionDetach, Component: "runtime", Name: "ctx/old", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionRestart, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionInstall, Component: "app-proxy", Context: "ctx", Namespace: "agent"},
+			},
+		},
+		{
+			name:    "should uninstall detached runtimes with prune",
+			secrets: []interface{}{runnerconfSecret("agent", "ctx/runtime", "ctx/old")},
+			prune:   true,
+			want: []planStepDocument{
+				{Action: applyActionInstall, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionInstall, Component: "runtime", Name: "ctx/runtime", Context: "ctx", Namespace: "runtime"},
+				{Action: applyActionInstall, Component: "runtime", Name: "ctx/detached", Context: "ctx", Namespace: "detached"},
+				{Action: applyActionDetach, Component: "runtime", Name: "ctx/old", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionUninstall, Component: "runtime", Name: "ctx/old", Context: "other", Namespace: "old"},
+				{Action: applyActionRestart, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+				{Action: applyActionInstall, Component: "app-proxy", Context: "ctx", Namespace: "agent"},
+			},
+		},
+		{
+			name:    "should fail to prune a runtime that is not in Codefresh",
+			secrets: []interface{}{runnerconfSecret("agent", "ctx/runtime", "ctx/unknown")},
+			prune:   true,
+			wantErr: "runtime ctx/unknown",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := kubeServer(t, tt.secrets...)
+			spec := newSpec(kubeConfig(t, srv, "ctx", "other"))
+			if err := spec.complete(""); err != nil {
+				t.Fatalf("complete() error = %v", err)
+			}
+			applyCmdOpt.prune = tt.prune
+			defer func() { applyCmdOpt.prune = false }()
+
+			steps, err := buildPlan(context.Background(), logger.New(&logger.Options{}), spec)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("buildPlan() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("buildPlan() error = %v", err)
+			}
+			got := make([]planStepDocument, 0, len(steps))
+			for _, step := range steps {
+				got = append(got, step.planStepDocument)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("buildPlan() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestApply_reapply(t *testing.T) {
+	codefreshServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("unexpected request to Codefresh: %s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer codefreshServer.Close()
+
+	runnerconf := runnerconfSecret("agent", "ctx/runtime")
+	certs := &v1.Secret{
+		TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{Name: "codefresh-certs-server", Namespace: "runtime"},
+		Data: map[string][]byte{
+			"server-cert.pem": []byte("cert"),
+			"server-key.pem":  []byte("key"),
+			"ca.pem":          []byte("ca"),
+		},
+	}
+	srv := kubeServer(t,
+		installedSecret("agent", plugins.VenonaPluginType),
+		installedSecret("runtime", plugins.RuntimeEnvironmentPluginType),
+		runnerconf,
+		certs,
+		&v1.Secret{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "agent"},
+			Data:       map[string][]byte{"codefresh.token": []byte("rotated-token")},
+		},
+		&appsv1.Deployment{
+			TypeMeta:   metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"},
+			ObjectMeta: metav1.ObjectMeta{Name: "runner", Namespace: "agent"},
+			Spec: appsv1.DeploymentSpec{Template: v1.PodTemplateSpec{Spec: v1.PodSpec{
+				Containers: []v1.Container{{Name: "runner", Env: []v1.EnvVar{{Name: "AGENT_ID", Value: "agent"}}}},
+			}}},
+		},
+	)
+	lgr := logger.New(&logger.Options{})
+	buildBasicStore(lgr)
+	store.GetStore().KubernetesAPI = &store.KubernetesAPI{}
+	spec := &runnerSpec{
+		Agent:    agentSpec{ID: "agent", Token: "token", Kube: kubeSpec{Context: "ctx", Namespace: "agent", ConfigPath: kubeConfig(t, srv, "ctx")}},
+		Runtimes: []runtimeSpec{{Name: "ctx/runtime", Kube: kubeSpec{Namespace: "runtime"}}},
+	}
+	spec.Codefresh.Host = codefreshServer.URL
+	spec.Codefresh.Token = "token"
+	if err := spec.complete(""); err != nil {
+		t.Fatalf("complete() error = %v", err)
+	}
+
+	steps, err := buildPlan(context.Background(), lgr, spec)
+	if err != nil {
+		t.Fatalf("buildPlan() error = %v", err)
+	}
+	want := []planStepDocument{
+		{Action: applyActionUpgrade, Component: "agent", Name: "agent", Context: "ctx", Namespace: "agent"},
+		{Action: applyActionUpgrade, Component: "runtime", Name: "ctx/runtime", Context: "ctx", Namespace: "runtime"},
+	}
+	got := make([]planStepDocument, 0, len(steps))
+	for _, step := range steps {
+		got = append(got, step.planStepDocument)
+		if err := step.run(context.Background()); err != nil {
+			t.Fatalf("%s of %s failed: %v", step.Action, step.Component, err)
+		}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("buildPlan() = %+v, want %+v", got, want)
+	}
+
+	for _, tt := range []struct {
+		path string
+		want *v1.Secret
+	}{
+		{path: kubeobjtest.Path("v1", "secrets", "agent", "runnerconf"), want: runnerconf},
+		{path: kubeobjtest.Path("v1", "secrets", "runtime", "codefresh-certs-server"), want: certs},
+	} {
+		live := &v1.Secret{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(srv.Get(tt.path), live); err != nil {
+			t.Fatalf("%s: %v", tt.path, err)
+		}
+		if !reflect.DeepEqual(live.Data, tt.want.Data) {
+			t.Errorf("%s = %v, want it unchanged %v", tt.path, live.Data, tt.want.Data)
+		}
+	}
+	token := &v1.Secret{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(srv.Get(kubeobjtest.Path("v1", "secrets", "agent", "runner")), token); err != nil {
+		t.Fatal(err)
+	}
+	if string(token.Data["codefresh.token"]) != "rotated-token" {
+		t.Errorf("token of the agent = %s, want the installed one", token.Data["codefresh.token"])
+	}
+}
+
+func TestRunnerSpec_complete(t *testing.T) {
+	tests := []struct {
+		name    string
+		spec    string
+		check   func(t *testing.T, spec *runnerSpec)
+		wantErr string
+	}{
+		{
+			name: "should fill the defaults from the agent",
+			spec: `
+codefresh:
+  token: cf-token
+agent:
+  id: agent
+  kube:
+    context: ctx
+    namespace: agent
+    configPath: /kube/config
+runtimes:
+- name: ctx/runtime
+  kube:
+    namespace: runtime
+monitor:
+  clusterId: ctx
+appProxy:
+  host: app-proxy.example.com
+`,
+			check: func(t *testing.T, spec *runnerSpec) {
+				if spec.Codefresh.Host != "https://g.codefresh.io" {
+					t.Errorf("codefresh.host = %s", spec.Codefresh.Host)
+				}
+				if spec.Agent.Token != "cf-token" {
+					t.Errorf("agent.token = %s, want the codefresh token", spec.Agent.Token)
+				}
+				want := kubeSpec{Context: "ctx", Namespace: "runtime", ConfigPath: "/kube/config"}
+				if spec.Runtimes[0].Kube != want {
+					t.Errorf("runtimes[0].kube = %+v, want %+v", spec.Runtimes[0].Kube, want)
+				}
+				if !spec.Runtimes[0].attach() {
+					t.Errorf("runtimes[0] should be attached")
+				}
+				agent := kubeSpec{Context: "ctx", Namespace: "agent", ConfigPath: "/kube/config"}
+				if spec.Monitor.Kube != agent || spec.AppProxy.Kube != agent {
+					t.Errorf("monitor.kube = %+v, appProxy.kube = %+v, want %+v", spec.Monitor.Kube, spec.AppProxy.Kube, agent)
+				}
+			},
+		},
+		{
+			name:    "should require the agent id",
+			spec:    "agent: {token: t, kube: {context: ctx, namespace: agent}}",
+			wantErr: "agent.id is required",
+		},
+		{
+			name:    "should require the agent token",
+			spec:    "agent: {id: agent, kube: {context: ctx, namespace: agent}}",
+			wantErr: "agent.token is required",
+		},
+		{
+			name:    "should require the agent namespace",
+			spec:    "agent: {id: agent, token: t, kube: {context: ctx}}",
+			wantErr: "agent.kube.namespace is required",
+		},
+		{
+			name: "should reject a runtime declared twice",
+			spec: `
+codefresh: {token: t}
+agent: {id: agent, kube: {context: ctx, namespace: agent}}
+runtimes:
+- {name: ctx/runtime, kube: {namespace: a}}
+- {name: ctx/runtime, kube: {namespace: b}}
+`,
+			wantErr: "Runtime ctx/runtime is declared more than once",
+		},
+		{
+			name: "should reject runtimes in the same namespace",
+			spec: `
+codefresh: {token: t}
+agent: {id: agent, kube: {context: ctx, namespace: agent}}
+runtimes:
+- {name: ctx/a, kube: {namespace: runtime}}
+- {name: ctx/b, kube: {context: ctx, namespace: runtime}}
+`,
+			wantErr: "Runtimes ctx/a and ctx/b are installed in the same namespace runtime of ctx",
+		},
+		{
+			name: "should require the codefresh token for runtimes",
+			spec: `
+agent: {id: agent, token: t, kube: {context: ctx, namespace: agent}}
+runtimes:
+- {name: ctx/runtime}
+`,
+			wantErr: "codefresh.token is required in order to install runtimes",
+		},
+		{
+			name: "should require the cluster id of an enabled monitor",
+			spec: `
+codefresh: {token: t}
+agent: {id: agent, kube: {context: ctx, namespace: agent}}
+monitor: {}
+`,
+			wantErr: "monitor.clusterId is required",
+		},
+		{
+			name: "should require the host of the app-proxy",
+			spec: `
+agent: {id: agent, token: t, kube: {context: ctx, namespace: agent}}
+appProxy: {}
+`,
+			wantErr: "appProxy.host is required",
+		},
+		{
+			name: "should not require the host of a disabled app-proxy",
+			spec: `
+agent: {id: agent, token: t, kube: {context: ctx, namespace: agent}}
+appProxy: {enabled: false}
+`,
+			check: func(t *testing.T, spec *runnerSpec) {
+				if spec.AppProxy.enabled() {
+					t.Errorf("appProxy should be disabled")
+				}
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "runner.yaml")
+			if err := ioutil.WriteFile(file, []byte(tt.spec), 0600); err != nil {
+				t.Fatal(err)
+			}
+			spec, err := loadRunnerSpec(file)
+			if err != nil {
+				t.Fatalf("loadRunnerSpec() error = %v", err)
+			}
+			err = spec.complete("")
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Fatalf("complete() error = %v, want %q", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("complete() error = %v", err)
+			}
+			if tt.check != nil {
+				tt.check(t, spec)
+			}
+		})
+	}
+}
+
+func TestNormalizeValues(t *testing.T) {
+	tests := []struct {
+		name   string
+		values map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name: "should keep nil values",
+		},
+		{
+			name: "should convert the keys of nested maps to strings",
+			values: map[string]interface{}{
+				"Storage": map[interface{}]interface{}{
+					"Backend": "ebs",
+					"Size":    map[interface{}]interface{}{1: "one"},
+				},
+				"Tolerations": []interface{}{
+					map[interface{}]interface{}{"key": "dedicated"},
+					"plain",
+				},
+				"Replicas": 2,
+			},
+			want: map[string]interface{}{
+				"Storage": map[string]interface{}{
+					"Backend": "ebs",
+					"Size":    map[string]interface{}{"1": "one"},
+				},
+				"Tolerations": []interface{}{
+					map[string]interface{}{"key": "dedicated"},
+					"plain",
+				},
+				"Replicas": 2,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := normalizeValues(tt.values); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("normalizeValues() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
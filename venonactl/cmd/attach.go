@@ -103,6 +103,7 @@ var attachRuntimeCmd = &cobra.Command{
 
 		builderInstallOpt := &plugins.InstallOptions{
 			ClusterNamespace:      attachRuntimeCmdOptions.kubeVenona.namespace,
+			AgentNamespace:        attachRuntimeCmdOptions.kubeVenona.namespace,
 			ClusterHost:           attachRuntimeCmdOptions.kube.host,
 			RuntimeEnvironment:    attachRuntimeCmdOptions.runtimeEnvironmentName,
 			RuntimeClusterName:    attachRuntimeCmdOptions.kube.namespace,
@@ -1,6 +1,8 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -35,6 +37,7 @@ var (
 
 	verbose      bool
 	logFormatter string
+	outputFormat string
 
 	configPath string
 	cfAPIHost  string
@@ -148,6 +151,10 @@ func isUsingDefaultStorageClass(sc string) bool {
 
 func dieOnError(err error) {
 	if err != nil {
+		if isStructuredOutput() {
+			printDocument(&errorDocument{Error: err.Error()})
+			os.Exit(1)
+		}
 		fmt.Printf("Error: %s\n", err.Error())
 		os.Exit(1)
 	}
@@ -176,6 +183,15 @@ func getKubeClientBuilder(context string, namespace string, path string, inClust
 }
 
 func createLogger(command string, verbose bool, logFormatter string) logger.Logger {
+	return newLogger(command, verbose, logFormatter, isStructuredOutput())
+}
+
+// createStderrLogger - logs to stderr, for commands that print their result on stdout
+func createStderrLogger(command string, verbose bool, logFormatter string) logger.Logger {
+	return newLogger(command, verbose, logFormatter, true)
+}
+
+func newLogger(command string, verbose bool, logFormatter string, stderr bool) logger.Logger {
 	logFile := "venonalog.json"
 	os.Remove(logFile)
 	return logger.New(&logger.Options{
@@ -183,6 +199,7 @@ func createLogger(command string, verbose bool, logFormatter string) logger.Logg
 		Verbose:      verbose,
 		LogToFile:    logFile,
 		LogFormatter: logFormatter,
+		LogToStderr:  stderr,
 	})
 }
 
@@ -319,3 +336,53 @@ func mergeValueMSI(valuesMap map[string]interface{}, key string, param *map[stri
 	val := mapX.Get(key).MSI(defaultValue...)
 	*param = val
 }
+
+// installPlugins - installs the plugins in order. When one of them fails the plugins installed before it
+// are rolled back, plugins that were installed for the first time are deleted.
+// Returns the values and the names of the deleted plugins
+func installPlugins(ctx context.Context, lgr logger.Logger, builder plugins.PluginBuilder, opt *plugins.InstallOptions, values plugins.Values) (plugins.Values, []string, error) {
+	installed := []plugins.Plugin{}
+	for _, p := range builder.Get() {
+		res, err := p.Install(ctx, opt, values)
+		if err != nil {
+			if opt.DryRun {
+				return nil, nil, err
+			}
+			// the values of the installed plugins are kept, they tell what the rollback has to undo
+			return values, rollbackPlugins(ctx, lgr, installed, opt, values), err
+		}
+		values = res
+		installed = append(installed, p)
+	}
+	return values, nil, nil
+}
+
+func rollbackPlugins(ctx context.Context, lgr logger.Logger, installed []plugins.Plugin, opt *plugins.InstallOptions, values plugins.Values) []string {
+	deleted := []string{}
+	for i := len(installed) - 1; i >= 0; i-- {
+		p := installed[i]
+		lgr.Warn(fmt.Sprintf("Rolling back %s", p.Name()))
+		_, err := p.Rollback(ctx, &plugins.RollbackOptions{
+			KubeBuilder:      opt.KubeBuilder,
+			AgentKubeBuilder: opt.AgentKubeBuilder,
+			ClusterNamespace: opt.ClusterNamespace,
+			AgentNamespace:   opt.AgentNamespace,
+		}, values)
+		if errors.Is(err, plugins.ErrNoPreviousRevision) {
+			err = p.Delete(ctx, &plugins.DeleteOptions{
+				KubeBuilder:        opt.KubeBuilder,
+				AgentKubeBuilder:   opt.AgentKubeBuilder,
+				ClusterNamespace:   opt.ClusterNamespace,
+				AgentNamespace:     opt.AgentNamespace,
+				RuntimeEnvironment: opt.RuntimeEnvironment,
+			}, values)
+			if err == nil {
+				deleted = append(deleted, p.Name())
+			}
+		}
+		if err != nil {
+			lgr.Error(fmt.Sprintf("Failed to roll back %s: %v", p.Name(), err))
+		}
+	}
+	return deleted
+}
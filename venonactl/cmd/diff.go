@@ -0,0 +1,147 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
+	"github.com/codefresh-io/venona/venonactl/pkg/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var diffCmdOpt struct {
+	kube struct {
+		context   string
+		namespace string
+		inCluster bool
+	}
+	runtimeEnvironmentName string
+	agentToken             string
+	agentID                string
+	storageClass           string
+	components             []string
+	exitCode               bool
+	templateValues         []string
+	templateFileValues     []string
+	templateValueFiles     []string
+}
+
+var diffCmd = &cobra.Command{
+	Use:   "diff",
+	Short: "Show what install or upgrade would change in the cluster",
+	Long:  "Renders the templates with the same values as install and prints a unified diff against the live objects, values of secrets are shown hashed",
+	Run: func(cmd *cobra.Command, args []string) {
+		// get valuesMap from --values <values.yaml> --set-value k=v --set-file k=<context-of file>
+		templateValuesMap, err := templateValuesToMap(
+			diffCmdOpt.templateValueFiles,
+			diffCmdOpt.templateValues,
+			diffCmdOpt.templateFileValues)
+		if err != nil {
+			dieOnError(err)
+		}
+		// Merge cmd options with template
+		mergeValueStr(templateValuesMap, "ConfigPath", &kubeConfigPath)
+		mergeValueStr(templateValuesMap, "CodefreshHost", &cfAPIHost)
+		mergeValueStr(templateValuesMap, "Token", &cfAPIToken)
+		mergeValueStr(templateValuesMap, "Namespace", &diffCmdOpt.kube.namespace)
+		mergeValueStr(templateValuesMap, "Context", &diffCmdOpt.kube.context)
+		mergeValueStr(templateValuesMap, "RuntimeEnvironmentName", &diffCmdOpt.runtimeEnvironmentName)
+		mergeValueStr(templateValuesMap, "AgentToken", &diffCmdOpt.agentToken)
+		mergeValueStr(templateValuesMap, "AgentId", &diffCmdOpt.agentID)
+		mergeValueStr(templateValuesMap, "StorageClass", &diffCmdOpt.storageClass)
+
+		// the diff is printed on stdout, so it can be saved as a patch
+		lgr := createStderrLogger("Diff", verbose, logFormatter)
+		s := store.GetStore()
+		buildBasicStore(lgr)
+		extendStoreWithAgentAPI(lgr, diffCmdOpt.agentToken, diffCmdOpt.agentID)
+		extendStoreWithKubeClient(lgr)
+		if cfAPIHost == "" {
+			cfAPIHost = "https://g.codefresh.io"
+		}
+		s.CodefreshAPI = &store.CodefreshAPI{
+			Host:  cfAPIHost,
+			Token: cfAPIToken,
+		}
+		fillKubernetesAPI(lgr, diffCmdOpt.kube.context, diffCmdOpt.kube.namespace, diffCmdOpt.kube.inCluster)
+
+		builder := plugins.NewBuilder(lgr)
+		for _, c := range diffCmdOpt.components {
+			if c == plugins.VolumeProvisionerPluginType && !isUsingDefaultStorageClass(diffCmdOpt.storageClass) {
+				lgr.Info("Custom StorageClass is set, skipping diff of default volume provisioner")
+				continue
+			}
+			builder.Add(c)
+		}
+
+		values := s.BuildValues()
+		values = mergeMaps(values, templateValuesMap)
+		diffOpt := &plugins.DiffOptions{
+			KubeBuilder:        getKubeClientBuilder(s.KubernetesAPI.ContextName, s.KubernetesAPI.Namespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false),
+			ClusterNamespace:   s.KubernetesAPI.Namespace,
+			RuntimeEnvironment: diffCmdOpt.runtimeEnvironmentName,
+		}
+
+		changed := 0
+		for _, p := range builder.Get() {
+			if p == nil {
+				dieOnError(fmt.Errorf("Unknown component, supported components: %s, %s, %s, %s, %s, %s", plugins.VenonaPluginType, plugins.RuntimeEnvironmentPluginType,
+					plugins.EnginePluginType, plugins.VolumeProvisionerPluginType, plugins.MonitorAgentPluginType, plugins.AppProxyPluginType))
+			}
+			diffs, err := p.Diff(cmd.Context(), diffOpt, values)
+			dieOnError(err)
+			for _, d := range diffs {
+				if d.Diff == "" {
+					continue
+				}
+				changed++
+				fmt.Print(d.Diff)
+			}
+		}
+		if changed == 0 {
+			lgr.Info("No changes")
+			return
+		}
+		lgr.Info(fmt.Sprintf("%d objects would change", changed))
+		if diffCmdOpt.exitCode {
+			os.Exit(1)
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+	viper.BindEnv("kube-namespace", "KUBE_NAMESPACE")
+	viper.BindEnv("kube-context", "KUBE_CONTEXT")
+
+	diffCmd.Flags().StringVar(&diffCmdOpt.kube.namespace, "kube-namespace", viper.GetString("kube-namespace"), "Name of the namespace on which venona is installed [$KUBE_NAMESPACE]")
+	diffCmd.Flags().StringVar(&diffCmdOpt.kube.context, "kube-context-name", viper.GetString("kube-context"), "Name of the kubernetes context on which venona is installed (default is current-context) [$KUBE_CONTEXT]")
+	diffCmd.Flags().BoolVar(&diffCmdOpt.kube.inCluster, "in-cluster", false, "Set flag if venona is been installed from inside a cluster")
+	diffCmd.Flags().StringVar(&diffCmdOpt.runtimeEnvironmentName, "runtimeName", viper.GetString("runtimeName"), "Name of the runtime as in codefresh")
+	diffCmd.Flags().StringVar(&diffCmdOpt.agentToken, "agentToken", "", "Agent token created by codefresh")
+	diffCmd.Flags().StringVar(&diffCmdOpt.agentID, "agentId", "", "Agent id created by codefresh")
+	diffCmd.Flags().StringVar(&diffCmdOpt.storageClass, "storage-class", "", "Name of the custom storage class, the default volume provisioner is not compared when set")
+	diffCmd.Flags().StringSliceVar(&diffCmdOpt.components, "components", []string{plugins.VenonaPluginType, plugins.RuntimeEnvironmentPluginType, plugins.EnginePluginType, plugins.VolumeProvisionerPluginType}, "Components to compare")
+	diffCmd.Flags().BoolVar(&diffCmdOpt.exitCode, "exit-code", false, "Exit with code 1 when there are changes")
+
+	diffCmd.Flags().StringArrayVar(&diffCmdOpt.templateValues, "set-value", []string{}, "Set values for templates --set-value agentId=12345")
+	diffCmd.Flags().StringArrayVar(&diffCmdOpt.templateFileValues, "set-file", []string{}, "Set values for templates from file")
+	diffCmd.Flags().StringArrayVarP(&diffCmdOpt.templateValueFiles, "values", "f", []string{}, "specify values in a YAML file")
+}
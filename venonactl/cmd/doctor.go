@@ -0,0 +1,244 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/codefresh-io/go-sdk/pkg/codefresh"
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
+	"github.com/codefresh-io/venona/venonactl/pkg/store"
+	humanize "github.com/dustin/go-humanize"
+	"github.com/spf13/cobra"
+)
+
+var doctorCmdOpt struct {
+	kube struct {
+		context string
+	}
+	agentKube struct {
+		context   string
+		namespace string
+	}
+	maxReportAge    time.Duration
+	skipNetworkTest bool
+}
+
+type doctorDocument struct {
+	Runtime  string            `json:"runtime" yaml:"runtime"`
+	Healthy  bool              `json:"healthy" yaml:"healthy"`
+	Findings []plugins.Finding `json:"findings" yaml:"findings"`
+}
+
+var doctorCmd = &cobra.Command{
+	Use:   "doctor [name]",
+	Short: "Diagnose an installed runtime end to end",
+	Long:  "Checks the runtime-environment in Codefresh, the agent, the objects of the runtime, the attached runtimes and the network, and prints the findings ordered by severity. Exits with code 1 when a critical problem is found",
+	Args:  cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		lgr := createLogger("Doctor", verbose, logFormatter)
+		buildBasicStore(lgr)
+		dieOnError(extendStoreWithCodefershClient(lgr))
+		extendStoreWithKubeClient(lgr)
+		s := store.GetStore()
+		ctx := cmd.Context()
+		name := args[0]
+		findings := []plugins.Finding{}
+
+		lgr.Info("Checking runtime-environment in Codefresh")
+		res, err := s.CodefreshAPI.Client.RuntimeEnvironments().List()
+		dieOnError(err)
+		registered := []string{}
+		namespaces := map[string]string{}
+		var re *codefresh.RuntimeEnvironment
+		for _, r := range res {
+			if r.Metadata.Agent {
+				registered = append(registered, r.Metadata.Name)
+				namespaces[r.Metadata.Name] = r.RuntimeScheduler.Cluster.Namespace
+			}
+			if r.Metadata.Name == name {
+				re = r
+			}
+		}
+		if re == nil {
+			findings = append(findings, plugins.Finding{
+				Severity:    plugins.SeverityCritical,
+				Check:       "runtime-environment",
+				Message:     fmt.Sprintf("Runtime-Environment %s not found in Codefresh", name),
+				Remediation: "Install the runtime with `venonactl install runtime`",
+			})
+			printFindings(name, findings)
+			return
+		}
+		if !re.Metadata.Agent {
+			findings = append(findings, plugins.Finding{
+				Severity:    plugins.SeverityCritical,
+				Check:       "runtime-environment",
+				Message:     fmt.Sprintf("Runtime-Environment %s is not run by an agent", name),
+				Remediation: "Only runtimes installed by venonactl can be diagnosed",
+			})
+			printFindings(name, findings)
+			return
+		}
+		findings = append(findings, reportFindings(re, doctorCmdOpt.maxReportAge)...)
+
+		lgr.Info("Checking objects of the runtime")
+		rows, err := kubernetesRelatedResources(ctx, re, doctorCmdOpt.kube.context, registered, lgr)
+		if err != nil {
+			findings = append(findings, checkFailed("status", err))
+		}
+		findings = append(findings, plugins.StatusFindings(rows)...)
+
+		agentContext := doctorCmdOpt.agentKube.context
+		if agentContext == "" {
+			agentContext = s.KubernetesAPI.ContextName
+		}
+		agentNamespace := doctorCmdOpt.agentKube.namespace
+		if agentNamespace == "" {
+			agentNamespace = re.RuntimeScheduler.Cluster.Namespace
+		}
+		agentKubeBuilder := getKubeClientBuilder(agentContext, agentNamespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false)
+
+		lgr.Info("Checking logs of the agent")
+		logFindings, err := plugins.AgentLogFindings(ctx, agentKubeBuilder, agentNamespace, store.ApplicationName)
+		if err != nil {
+			findings = append(findings, checkFailed("agent logs", err))
+		}
+		findings = append(findings, logFindings...)
+
+		lgr.Info("Checking tokens of the attached runtimes")
+		tokenFindings, err := plugins.RuntimeTokenFindings(ctx, agentKubeBuilder, agentNamespace, namespaces)
+		if err != nil {
+			findings = append(findings, checkFailed("runtime token", err))
+		}
+		findings = append(findings, tokenFindings...)
+
+		lgr.Info("Running acceptance tests")
+		findings = append(findings, testFindings(cmd, re, lgr)...)
+
+		printFindings(name, findings)
+	},
+}
+
+// reportFindings - the agent reports its status to Codefresh periodically, an old report means it is not running
+func reportFindings(re *codefresh.RuntimeEnvironment, maxAge time.Duration) []plugins.Finding {
+	if re.Status.Message == "" {
+		return []plugins.Finding{{
+			Severity:    plugins.SeverityCritical,
+			Check:       "agent status",
+			Message:     "The agent has not reported any status yet",
+			Remediation: "Check that the agent is running and can reach Codefresh",
+		}}
+	}
+	if time.Since(re.Status.UpdatedAt) > maxAge {
+		return []plugins.Finding{{
+			Severity:    plugins.SeverityCritical,
+			Check:       "agent status",
+			Message:     fmt.Sprintf("The agent last reported %s: %s", humanize.Time(re.Status.UpdatedAt), re.Status.Message),
+			Remediation: "Check that the agent is running and can reach Codefresh",
+		}}
+	}
+	return nil
+}
+
+// testFindings - runs the acceptance tests of the components of the runtime
+func testFindings(cmd *cobra.Command, re *codefresh.RuntimeEnvironment, lgr logger.Logger) []plugins.Finding {
+	s := store.GetStore()
+	builder := plugins.NewBuilder(lgr).
+		Add(plugins.RuntimeEnvironmentPluginType).
+		Add(plugins.VenonaPluginType)
+	storageClass := re.RuntimeScheduler.Pvcs.Dind.StorageClassName
+	if storageClass == "" || strings.HasPrefix(storageClass, plugins.DefaultStorageClassNamePrefix) {
+		builder.Add(plugins.VolumeProvisionerPluginType)
+	}
+	if !doctorCmdOpt.skipNetworkTest {
+		builder.Add(plugins.NetworkTesterPluginType)
+	}
+	opt := &plugins.TestOptions{
+		KubeBuilder:      getKubeClientBuilder(s.KubernetesAPI.ContextName, s.KubernetesAPI.Namespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false),
+		ClusterNamespace: s.KubernetesAPI.Namespace,
+	}
+	var findings []plugins.Finding
+	for _, p := range builder.Get() {
+		err := p.Test(cmd.Context(), opt, s.BuildValues())
+		if err == nil {
+			continue
+		}
+		var validationErr *plugins.ValidationError
+		if !errors.As(err, &validationErr) {
+			findings = append(findings, checkFailed(fmt.Sprintf("%s test", p.Name()), err))
+			continue
+		}
+		for _, m := range validationErr.Messages {
+			findings = append(findings, plugins.Finding{
+				Severity:    plugins.SeverityCritical,
+				Check:       fmt.Sprintf("%s test", p.Name()),
+				Message:     m,
+				Remediation: "Grant the missing permissions or resources, see `venonactl test`",
+			})
+		}
+	}
+	return findings
+}
+
+func checkFailed(check string, err error) plugins.Finding {
+	return plugins.Finding{
+		Severity: plugins.SeverityCritical,
+		Check:    check,
+		Message:  err.Error(),
+	}
+}
+
+// printFindings - prints the findings ordered by severity, exits with code 1 when any is critical
+func printFindings(name string, findings []plugins.Finding) {
+	plugins.SortFindings(findings)
+	healthy := true
+	for _, f := range findings {
+		if f.Severity == plugins.SeverityCritical {
+			healthy = false
+		}
+	}
+	if isStructuredOutput() {
+		printDocument(&doctorDocument{Runtime: name, Healthy: healthy, Findings: findings})
+	} else if len(findings) == 0 {
+		fmt.Println("No problems found")
+	} else {
+		table := createTable()
+		table.SetHeader([]string{"Severity", "Check", "Finding", "Remediation"})
+		for _, f := range findings {
+			table.Append([]string{f.Severity, f.Check, f.Message, f.Remediation})
+		}
+		table.Render()
+	}
+	if !healthy {
+		os.Exit(1)
+	}
+}
+
+func init() {
+	rootCmd.AddCommand(doctorCmd)
+	doctorCmd.Flags().StringVar(&doctorCmdOpt.kube.context, "kube-context-name", "", "Set name to overwrite the context name saved in Codefresh")
+	doctorCmd.Flags().StringVar(&doctorCmdOpt.agentKube.context, "agent-kube-context-name", "", "Name of the kubernetes context of the agent (default is the context of the runtime)")
+	doctorCmd.Flags().StringVar(&doctorCmdOpt.agentKube.namespace, "agent-kube-namespace", "", "Name of the namespace of the agent (default is the namespace of the runtime)")
+	doctorCmd.Flags().DurationVar(&doctorCmdOpt.maxReportAge, "max-report-age", 5*time.Minute, "Age of the last status report of the agent after which it is considered down")
+	doctorCmd.Flags().BoolVar(&doctorCmdOpt.skipNetworkTest, "skip-network-test", false, "Skip the network test, it runs a pod in the namespace of the runtime")
+}
@@ -148,6 +148,7 @@ var installAgentCmd = &cobra.Command{
 		values := s.BuildValues()
 		values = mergeMaps(values, templateValuesMap)
 
+		dryRunDoc := dryRunObjects(builderInstallOpt)
 		for _, p := range builder.Get() {
 			values, err = p.Install(cmd.Context(), builderInstallOpt, values)
 			if err != nil {
@@ -155,6 +156,7 @@ var installAgentCmd = &cobra.Command{
 			}
 		}
 		lgr.Info("Agent installation completed Successfully")
+		printDryRunDocument(dryRunDoc)
 	},
 }
 
@@ -92,6 +92,7 @@ var installAppProxyCmd = &cobra.Command{
 
 		values := s.BuildValues()
 		values = mergeMaps(values, templateValuesMap)
+		dryRunDoc := dryRunObjects(builderInstallOpt)
 		for _, p := range builder.Get() {
 			values, err = p.Install(cmd.Context(), builderInstallOpt, values)
 			if err != nil {
@@ -100,6 +101,7 @@ var installAppProxyCmd = &cobra.Command{
 		}
 
 		lgr.Info("App proxy installation completed Successfully")
+		printDryRunDocument(dryRunDoc)
 	},
 }
 
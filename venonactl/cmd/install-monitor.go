@@ -119,11 +119,13 @@ var installMonitorAgentCmd = &cobra.Command{
 		values := s.BuildValues()
 		values = mergeMaps(values, templateValuesMap)
 
+		dryRunDoc := dryRunObjects(builderInstallOpt)
 		for _, p := range builder.Get() {
 			_, err := p.Install(cmd.Context(), builderInstallOpt, values)
 			dieOnError(err)
 		}
 		lgr.Info("Monitor agent installation completed Successfully")
+		printDryRunDocument(dryRunDoc)
 	},
 }
 
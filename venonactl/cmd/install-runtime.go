@@ -19,6 +19,7 @@ limitations under the License.
 import (
 	"fmt"
 
+	"github.com/codefresh-io/venona/venonactl/pkg/codefresh"
 	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
 	"github.com/codefresh-io/venona/venonactl/pkg/store"
 	"github.com/spf13/cobra"
@@ -46,6 +47,17 @@ var installRuntimeCmdOptions struct {
 	localVolumeMonitor     map[string]interface{}
 }
 
+var defaultVolumeProvResources = map[string]interface{}{
+	"limits": map[string]string{
+		"cpu":    "1000m",
+		"memory": "6000Mi",
+	},
+	"requests": map[string]string{
+		"cpu":    "200m",
+		"memory": "200Mi",
+	},
+}
+
 var installRuntimeCmd = &cobra.Command{
 	Use:   "runtime",
 	Short: "Install Codefresh's runtime",
@@ -77,16 +89,6 @@ var installRuntimeCmd = &cobra.Command{
 		mergeValueStr(templateValuesMap, "DockerRegistry", &installRuntimeCmdOptions.dockerRegistry)
 		mergeValueStr(templateValuesMap, "StorageClass", &installRuntimeCmdOptions.storageClass)
 
-		defaultVolumeProvResources := map[string]interface{}{
-			"limits": map[string]string{
-				"cpu":    "1000m",
-				"memory": "6000Mi",
-			},
-			"requests": map[string]string{
-				"cpu":    "200m",
-				"memory": "200Mi",
-			},
-		}
 		mergeValueMSI(templateValuesMap, "Storage.VolumeProvisioner.resources", &installRuntimeCmdOptions.volumeProvisioner, defaultVolumeProvResources)
 		mergeValueMSI(templateValuesMap, "Storage.LocalVolumeMonitor.resources", &installRuntimeCmdOptions.localVolumeMonitor)
 
@@ -159,13 +161,28 @@ var installRuntimeCmd = &cobra.Command{
 		values := s.BuildValues()
 		values = mergeMaps(values, templateValuesMap)
 
-		for _, p := range builder.Get() {
-			values, err = p.Install(cmd.Context(), builderInstallOpt, values)
-			if err != nil {
-				dieOnError(err)
+		dryRunDoc := dryRunObjects(builderInstallOpt)
+		values, deleted, err := installPlugins(cmd.Context(), lgr, builder, builderInstallOpt, values)
+		if err != nil {
+			for _, name := range deleted {
+				// only a runtime-environment registered by this installation is unregistered, others are left alone
+				if name == plugins.RuntimeEnvironmentPluginType && values[plugins.RuntimeEnvironmentRegisteredValue] == true {
+					lgr.Warn(fmt.Sprintf("Unregistering runtime-environment %s", builderInstallOpt.RuntimeEnvironment))
+					cf := codefresh.NewCodefreshAPI(&codefresh.APIOptions{
+						Logger:         lgr,
+						CodefreshHost:  cfAPIHost,
+						CodefreshToken: builderInstallOpt.CodefreshToken,
+						Insecure:       builderInstallOpt.Insecure,
+					})
+					if unregisterErr := cf.Unregister(builderInstallOpt.RuntimeEnvironment); unregisterErr != nil {
+						lgr.Error(fmt.Sprintf("Failed to unregister runtime-environment: %v", unregisterErr))
+					}
+				}
 			}
+			dieOnError(err)
 		}
 		lgr.Info("Runtime installation completed Successfully")
+		printDryRunDocument(dryRunDoc)
 
 	},
 }
@@ -0,0 +1,154 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	outputJSON = "json"
+	outputYAML = "yaml"
+)
+
+type (
+	// errorDocument - printed instead of the document of the command when it fails
+	errorDocument struct {
+		Error string `json:"error" yaml:"error"`
+	}
+
+	versionDocument struct {
+		Version string `json:"version" yaml:"version"`
+		Commit  string `json:"commit" yaml:"commit"`
+		Date    string `json:"date" yaml:"date"`
+	}
+
+	runtimeDocument struct {
+		Name     string `json:"name" yaml:"name"`
+		Message  string `json:"message" yaml:"message"`
+		Reported string `json:"reported,omitempty" yaml:"reported,omitempty"`
+	}
+
+	objectDocument struct {
+		Kind    string `json:"kind" yaml:"kind"`
+		Name    string `json:"name" yaml:"name"`
+		Status  string `json:"status" yaml:"status"`
+		Message string `json:"message,omitempty" yaml:"message,omitempty"`
+	}
+
+	statusDocument struct {
+		Runtimes []runtimeDocument `json:"runtimes" yaml:"runtimes"`
+		Objects  []objectDocument  `json:"objects,omitempty" yaml:"objects,omitempty"`
+		Healthy  *bool             `json:"healthy,omitempty" yaml:"healthy,omitempty"`
+	}
+
+	testResultDocument struct {
+		Component string   `json:"component" yaml:"component"`
+		Passed    bool     `json:"passed" yaml:"passed"`
+		Error     string   `json:"error,omitempty" yaml:"error,omitempty"`
+		Messages  []string `json:"messages,omitempty" yaml:"messages,omitempty"`
+	}
+
+	testDocument struct {
+		Passed  bool                 `json:"passed" yaml:"passed"`
+		Results []testResultDocument `json:"results" yaml:"results"`
+	}
+
+	dryRunDocument struct {
+		DryRun  bool                     `json:"dryRun" yaml:"dryRun"`
+		Objects []map[string]interface{} `json:"objects" yaml:"objects"`
+	}
+
+	planStepDocument struct {
+		Action    string `json:"action" yaml:"action"`
+		Component string `json:"component" yaml:"component"`
+		Name      string `json:"name,omitempty" yaml:"name,omitempty"`
+		Context   string `json:"context" yaml:"context"`
+		Namespace string `json:"namespace" yaml:"namespace"`
+	}
+
+	applyDocument struct {
+		DryRun bool               `json:"dryRun" yaml:"dryRun"`
+		Steps  []planStepDocument `json:"steps" yaml:"steps"`
+	}
+)
+
+func validateOutputFormat() error {
+	switch outputFormat {
+	case "", outputJSON, outputYAML:
+		return nil
+	}
+	return fmt.Errorf("Unknown output format %q, supported formats: %s, %s", outputFormat, outputJSON, outputYAML)
+}
+
+// isStructuredOutput - returns true when the output of the command is a json or yaml document,
+// logs are then written to stderr
+func isStructuredOutput() bool {
+	return outputFormat == outputJSON || outputFormat == outputYAML
+}
+
+// printDocument - prints the document in the requested format on stdout
+func printDocument(doc interface{}) {
+	var data []byte
+	var err error
+	if outputFormat == outputYAML {
+		data, err = yaml.Marshal(doc)
+	} else {
+		data, err = json.MarshalIndent(doc, "", "  ")
+		data = append(data, '\n')
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+	os.Stdout.Write(data)
+}
+
+// objectsToDocuments - converts the status rows of the plugins
+func objectsToDocuments(rows [][]string) []objectDocument {
+	objects := make([]objectDocument, 0, len(rows))
+	for _, row := range rows {
+		o := objectDocument{Kind: row[0], Name: row[1], Status: row[2]}
+		if len(row) > 3 {
+			o.Message = row[3]
+		}
+		objects = append(objects, o)
+	}
+	return objects
+}
+
+// dryRunObjects - when the dry run of install is printed as a document the rendered objects
+// are collected in it, otherwise nil is returned and the manifests are written to files
+func dryRunObjects(opt *plugins.InstallOptions) *dryRunDocument {
+	if !opt.DryRun || !isStructuredOutput() {
+		return nil
+	}
+	doc := &dryRunDocument{DryRun: true, Objects: []map[string]interface{}{}}
+	opt.DryRunObjects = &doc.Objects
+	return doc
+}
+
+func printDryRunDocument(doc *dryRunDocument) {
+	if doc != nil {
+		printDocument(doc)
+	}
+}
@@ -0,0 +1,150 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+
+	"gopkg.in/yaml.v2"
+)
+
+// captureStdout - returns what f writes on stdout
+func captureStdout(t *testing.T, f func()) []byte {
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	stdout := os.Stdout
+	os.Stdout = w
+	defer func() { os.Stdout = stdout }()
+	f()
+	w.Close()
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// unmarshalDocument - parses the output in the format, fails on anything that is not a single document
+func unmarshalDocument(t *testing.T, format string, data []byte, doc interface{}) {
+	var err error
+	if format == outputYAML {
+		err = yaml.UnmarshalStrict(data, doc)
+	} else {
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.DisallowUnknownFields()
+		err = dec.Decode(doc)
+		if err == nil && dec.More() {
+			err = errors.New("more than one document")
+		}
+	}
+	if err != nil {
+		t.Fatalf("output is not a %s document: %v\n%s", format, err, data)
+	}
+}
+
+func TestObjectsToDocuments(t *testing.T) {
+	tests := []struct {
+		name string
+		rows [][]string
+		want []objectDocument
+	}{
+		{
+			name: "no rows",
+			rows: nil,
+			want: []objectDocument{},
+		},
+		{
+			name: "rows with and without a message",
+			rows: [][]string{
+				{"Deployment", "runner", "Running"},
+				{"StorageClass", "dind-local-volumes-runner", "Not found", "not installed"},
+			},
+			want: []objectDocument{
+				{Kind: "Deployment", Name: "runner", Status: "Running"},
+				{Kind: "StorageClass", Name: "dind-local-volumes-runner", Status: "Not found", Message: "not installed"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := objectsToDocuments(tt.rows); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("objectsToDocuments() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPrintDocument(t *testing.T) {
+	healthy := false
+	status := &statusDocument{
+		Runtimes: []runtimeDocument{{Name: "ctx/runtime", Message: "attached", Reported: "ctx/runtime"}},
+		Objects: objectsToDocuments([][]string{
+			{"Deployment", "runner", "Running"},
+			{"Secret", "runnerconf", "Not found", "runtime: \"ctx/other\"\nline: 2"},
+		}),
+		Healthy: &healthy,
+	}
+	for _, format := range []string{outputJSON, outputYAML} {
+		t.Run(format, func(t *testing.T) {
+			defer func(prev string) { outputFormat = prev }(outputFormat)
+			outputFormat = format
+
+			out := captureStdout(t, func() { printDocument(status) })
+			got := &statusDocument{}
+			unmarshalDocument(t, format, out, got)
+			if !reflect.DeepEqual(got, status) {
+				t.Errorf("printDocument() = %+v, want %+v", got, status)
+			}
+		})
+	}
+}
+
+func TestDieOnError(t *testing.T) {
+	// dieOnError exits, it runs in a child process of the test
+	if format := os.Getenv("VENONACTL_TEST_DIE_ON_ERROR"); format != "" {
+		outputFormat = format
+		dieOnError(errors.New("cannot \"apply\": failed\n"))
+		return
+	}
+	for _, format := range []string{outputJSON, outputYAML} {
+		t.Run(format, func(t *testing.T) {
+			cmd := exec.Command(os.Args[0], "-test.run=^TestDieOnError$")
+			cmd.Env = append(os.Environ(), "VENONACTL_TEST_DIE_ON_ERROR="+format)
+			stdout := &bytes.Buffer{}
+			cmd.Stdout = stdout
+			err := cmd.Run()
+			exitErr := &exec.ExitError{}
+			if !errors.As(err, &exitErr) || exitErr.ExitCode() != 1 {
+				t.Fatalf("dieOnError() exited with %v, want exit status 1", err)
+			}
+			got := &errorDocument{}
+			unmarshalDocument(t, format, stdout.Bytes(), got)
+			if want := (&errorDocument{Error: "cannot \"apply\": failed\n"}); !reflect.DeepEqual(got, want) {
+				t.Errorf("dieOnError() printed %+v, want %+v", got, want)
+			}
+		})
+	}
+}
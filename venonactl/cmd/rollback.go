@@ -0,0 +1,89 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
+	"github.com/codefresh-io/venona/venonactl/pkg/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var rollbackCmdOpt struct {
+	kube struct {
+		context   string
+		namespace string
+		inCluster bool
+	}
+	revision   int
+	components []string
+}
+
+var rollbackCmd = &cobra.Command{
+	Use:   "rollback",
+	Short: "Restore the objects of a previous install or upgrade",
+	Long:  "Restores the objects and values of a previous revision of each component, the last revisions are kept in the cluster by install and upgrade",
+	Run: func(cmd *cobra.Command, args []string) {
+		lgr := createLogger("Rollback", verbose, logFormatter)
+		s := store.GetStore()
+		buildBasicStore(lgr)
+		extendStoreWithKubeClient(lgr)
+		fillKubernetesAPI(lgr, rollbackCmdOpt.kube.context, rollbackCmdOpt.kube.namespace, rollbackCmdOpt.kube.inCluster)
+
+		builder := plugins.NewBuilder(lgr)
+		for _, c := range rollbackCmdOpt.components {
+			builder.Add(c)
+		}
+
+		kubeBuilder := getKubeClientBuilder(s.KubernetesAPI.ContextName, s.KubernetesAPI.Namespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false)
+		rollbackOpt := &plugins.RollbackOptions{
+			KubeBuilder:      kubeBuilder,
+			AgentKubeBuilder: kubeBuilder,
+			ClusterNamespace: s.KubernetesAPI.Namespace,
+			AgentNamespace:   s.KubernetesAPI.Namespace,
+			Revision:         rollbackCmdOpt.revision,
+		}
+		for _, p := range builder.Get() {
+			if p == nil {
+				dieOnError(fmt.Errorf("Unknown component, supported components: %s, %s, %s, %s, %s, %s, %s", plugins.VenonaPluginType, plugins.RuntimeEnvironmentPluginType,
+					plugins.EnginePluginType, plugins.VolumeProvisionerPluginType, plugins.MonitorAgentPluginType, plugins.AppProxyPluginType, plugins.RuntimeAttachType))
+			}
+			_, err := p.Rollback(cmd.Context(), rollbackOpt, plugins.Values{})
+			if errors.Is(err, plugins.ErrNoPreviousRevision) {
+				lgr.Info(fmt.Sprintf("Nothing to roll back for %s", p.Name()))
+				continue
+			}
+			dieOnError(err)
+			lgr.Info(fmt.Sprintf("Rolled back %s", p.Name()))
+		}
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(rollbackCmd)
+	viper.BindEnv("kube-namespace", "KUBE_NAMESPACE")
+	viper.BindEnv("kube-context", "KUBE_CONTEXT")
+
+	rollbackCmd.Flags().StringVar(&rollbackCmdOpt.kube.namespace, "kube-namespace", viper.GetString("kube-namespace"), "Name of the namespace on which venona is installed [$KUBE_NAMESPACE]")
+	rollbackCmd.Flags().StringVar(&rollbackCmdOpt.kube.context, "kube-context-name", viper.GetString("kube-context"), "Name of the kubernetes context on which venona is installed (default is current-context) [$KUBE_CONTEXT]")
+	rollbackCmd.Flags().BoolVar(&rollbackCmdOpt.kube.inCluster, "in-cluster", false, "Set flag if venona is been installed from inside a cluster")
+	rollbackCmd.Flags().IntVar(&rollbackCmdOpt.revision, "revision", 0, "Revision to restore (default is the one before the last)")
+	rollbackCmd.Flags().StringSliceVar(&rollbackCmdOpt.components, "components", []string{plugins.VenonaPluginType, plugins.RuntimeEnvironmentPluginType, plugins.EnginePluginType, plugins.VolumeProvisionerPluginType}, "Components to roll back")
+}
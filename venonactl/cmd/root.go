@@ -27,6 +27,9 @@ import (
 var rootCmd = &cobra.Command{
 	Use:   "venona",
 	Short: "A command line application for Codefresh",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return validateOutputFormat()
+	},
 }
 
 // Execute - execute the root command
@@ -52,5 +55,6 @@ func init() {
 
 	rootCmd.PersistentFlags().BoolVar(&verbose, "verbose", false, "Print logs")
 	rootCmd.PersistentFlags().StringVar(&logFormatter, "log-formtter", "Plain", "Print logs in custom format")
+	rootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "", "Print the result of status, test, install --dry-run and version as json or yaml, logs are written to stderr")
 
 }
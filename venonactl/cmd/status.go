@@ -19,6 +19,9 @@ limitations under the License.
 import (
 	"context"
 	"fmt"
+	"os"
+	"strings"
+	"time"
 
 	"github.com/codefresh-io/go-sdk/pkg/codefresh"
 	"github.com/codefresh-io/venona/venonactl/pkg/logger"
@@ -40,7 +43,7 @@ var statusCmdOpt struct {
 var statusCmd = &cobra.Command{
 	Use:   "status [name]",
 	Short: "Get status of Codefresh's runtime-environment",
-	Long:  "Pass the name of the runtime environment to see more details information about the underlying resources",
+	Long:  "Pass the name of the runtime environment to see the health of the underlying resources, exits with code 1 when the runtime is unhealthy",
 	Run: func(cmd *cobra.Command, args []string) {
 		lgr := createLogger("Status", verbose, logFormatter)
 
@@ -48,7 +51,6 @@ var statusCmd = &cobra.Command{
 		extendStoreWithCodefershClient(lgr)
 		extendStoreWithKubeClient(lgr)
 		s := store.GetStore()
-		table := createTable()
 		// When requested status for specific runtime
 		if len(args) > 0 {
 			name := args[0]
@@ -58,17 +60,30 @@ var statusCmd = &cobra.Command{
 				dieOnError(fmt.Errorf("Runtime-Environment %s not found", name))
 			}
 			if re.Metadata.Agent == true {
-				table.SetHeader([]string{"Runtime Name", "Last Message", "Reported"})
-				message := "Not reported any message yet"
-				time := ""
-				if re.Status.Message != "" {
-					message = re.Status.Message
-					time = humanize.Time(re.Status.UpdatedAt)
+				registered, err := agentRuntimes()
+				dieOnError(err)
+				rows, err := kubernetesRelatedResources(cmd.Context(), re, statusCmdOpt.kube.context, registered, lgr)
+				dieOnError(err)
+				healthy := true
+				for _, row := range rows {
+					if !plugins.IsHealthy(row[2]) {
+						healthy = false
+					}
+				}
+				if isStructuredOutput() {
+					printDocument(&statusDocument{
+						Runtimes: []runtimeDocument{runtimeToDocument(re)},
+						Objects:  objectsToDocuments(rows),
+						Healthy:  &healthy,
+					})
+				} else {
+					printRuntimesTable([]*codefresh.RuntimeEnvironment{re})
+					fmt.Println()
+					printTableWithKubernetesRelatedResources(rows, healthy)
+				}
+				if !healthy {
+					os.Exit(1)
 				}
-				table.Append([]string{re.Metadata.Name, message, time})
-				table.Render()
-				fmt.Println()
-				printTableWithKubernetesRelatedResources(cmd.Context(), re, statusCmdOpt.kube.context, lgr)
 			} else {
 				lgr.Debug("Runtime-Environment has not Venona's agent", "Name", name)
 			}
@@ -78,31 +93,73 @@ var statusCmd = &cobra.Command{
 		// When requested status for all runtimes
 		res, err := s.CodefreshAPI.Client.RuntimeEnvironments().List()
 		dieOnError(err)
-		table.SetHeader([]string{"Runtime Name", "Last Message", "Reported"})
+		var agentREs []*codefresh.RuntimeEnvironment
 		for _, re := range res {
 			if re.Metadata.Agent == true {
-				message := "Not reported any message yet"
-				time := ""
-				if re.Status.Message != "" {
-					message = re.Status.Message
-					time = humanize.Time(re.Status.UpdatedAt)
-				}
-				table.Append([]string{re.Metadata.Name, message, time})
+				agentREs = append(agentREs, re)
 			}
 		}
-		table.Render()
+		if isStructuredOutput() {
+			doc := &statusDocument{Runtimes: []runtimeDocument{}}
+			for _, re := range agentREs {
+				doc.Runtimes = append(doc.Runtimes, runtimeToDocument(re))
+			}
+			printDocument(doc)
+			return
+		}
+		printRuntimesTable(agentREs)
 
 		return
 
 	},
 }
 
-func printTableWithKubernetesRelatedResources(ctx context.Context, re *codefresh.RuntimeEnvironment, context string, logger logger.Logger) {
-	builder := plugins.NewBuilder(logger)
+func runtimeToDocument(re *codefresh.RuntimeEnvironment) runtimeDocument {
+	doc := runtimeDocument{
+		Name:    re.Metadata.Name,
+		Message: "Not reported any message yet",
+	}
+	if re.Status.Message != "" {
+		doc.Message = re.Status.Message
+		doc.Reported = re.Status.UpdatedAt.Format(time.RFC3339)
+	}
+	return doc
+}
+
+func printRuntimesTable(res []*codefresh.RuntimeEnvironment) {
+	table := createTable()
+	table.SetHeader([]string{"Runtime Name", "Last Message", "Reported"})
+	for _, re := range res {
+		message := "Not reported any message yet"
+		time := ""
+		if re.Status.Message != "" {
+			message = re.Status.Message
+			time = humanize.Time(re.Status.UpdatedAt)
+		}
+		table.Append([]string{re.Metadata.Name, message, time})
+	}
+	table.Render()
+}
 
+func printTableWithKubernetesRelatedResources(rows [][]string, healthy bool) {
 	table := createTable()
-	table.SetHeader([]string{"Kind", "Name", "Status"})
+	table.SetHeader([]string{"Kind", "Name", "Status", "Message"})
+	table.AppendBulk(rows)
+	table.Render()
+	fmt.Println()
+	if healthy {
+		fmt.Println("Status: Healthy")
+	} else {
+		fmt.Println("Status: Unhealthy")
+	}
+}
+
+// kubernetesRelatedResources - returns the health of the objects of the runtime
+func kubernetesRelatedResources(ctx context.Context, re *codefresh.RuntimeEnvironment, context string, registered []string, logger logger.Logger) ([][]string, error) {
+	builder := plugins.NewBuilder(logger)
+
 	s := store.GetStore()
+	var rows [][]string
 	if re.RuntimeScheduler.Cluster.Namespace != "" {
 		if context == "" {
 			context = re.RuntimeScheduler.Cluster.ClusterProvider.Selector
@@ -111,19 +168,49 @@ func printTableWithKubernetesRelatedResources(ctx context.Context, re *codefresh
 		s.KubernetesAPI.Namespace = re.RuntimeScheduler.Cluster.Namespace
 		builder.
 			Add(plugins.RuntimeEnvironmentPluginType).
-			Add(plugins.VenonaPluginType).
-			Add(plugins.VolumeProvisionerPluginType)
+			Add(plugins.VenonaPluginType)
+		storageClass := re.RuntimeScheduler.Pvcs.Dind.StorageClassName
+		useDefaultStorageClass := storageClass == "" || strings.HasPrefix(storageClass, plugins.DefaultStorageClassNamePrefix)
+		if useDefaultStorageClass {
+			builder.Add(plugins.VolumeProvisionerPluginType)
+		}
 		statusOpt := &plugins.StatusOptions{
-			KubeBuilder:      getKubeClientBuilder(context, re.RuntimeScheduler.Cluster.Namespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false),
-			ClusterNamespace: s.KubernetesAPI.Namespace,
+			KubeBuilder:        getKubeClientBuilder(context, re.RuntimeScheduler.Cluster.Namespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false),
+			ClusterNamespace:   s.KubernetesAPI.Namespace,
+			RuntimeEnvironment: re.Metadata.Name,
+			RegisteredRuntimes: registered,
 		}
 		for _, p := range builder.Get() {
-			rows, err := p.Status(ctx, statusOpt, s.BuildValues())
-			dieOnError(err)
-			table.AppendBulk(rows)
+			pluginRows, err := p.Status(ctx, statusOpt, s.BuildValues())
+			if err != nil {
+				return nil, fmt.Errorf("%s: %w", p.Name(), err)
+			}
+			rows = append(rows, pluginRows...)
+		}
+		if !useDefaultStorageClass {
+			row, err := plugins.StorageClassStatus(ctx, statusOpt.KubeBuilder, storageClass)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, row)
 		}
 	}
-	table.Render()
+	return rows, nil
+}
+
+// agentRuntimes - names of the runtimes with an agent registered in Codefresh
+func agentRuntimes() ([]string, error) {
+	res, err := store.GetStore().CodefreshAPI.Client.RuntimeEnvironments().List()
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, re := range res {
+		if re.Metadata.Agent {
+			names = append(names, re.Metadata.Name)
+		}
+	}
+	return names, nil
 }
 
 func init() {
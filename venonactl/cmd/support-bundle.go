@@ -0,0 +1,186 @@
+package cmd
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
+	"github.com/codefresh-io/venona/venonactl/pkg/store"
+	"github.com/spf13/cobra"
+	"github.com/spf13/viper"
+)
+
+var supportBundleCmdOpt struct {
+	kube struct {
+		context   string
+		namespace string
+		inCluster bool
+	}
+	file string
+}
+
+// runtimePathReplacer - runtime names may hold characters that are not valid in paths
+var runtimePathReplacer = strings.NewReplacer("/", ".", "@", ".", ":", ".")
+
+var supportBundleCmd = &cobra.Command{
+	Use:   "support-bundle",
+	Short: "Collect diagnostics of the agent and its runtimes into a tar.gz file",
+	Long:  "Collects the values, objects, pods and logs of the components, the events of the namespaces, a summary of the nodes and the runtime-environments in Codefresh, from the cluster of the agent and of every attached runtime. Values, secrets and sensitive environment variables are redacted",
+	Run: func(cmd *cobra.Command, args []string) {
+		lgr := createLogger("SupportBundle", verbose, logFormatter)
+		buildBasicStore(lgr)
+		extendStoreWithKubeClient(lgr)
+		fillKubernetesAPI(lgr, supportBundleCmdOpt.kube.context, supportBundleCmdOpt.kube.namespace, supportBundleCmdOpt.kube.inCluster)
+		s := store.GetStore()
+		ctx := cmd.Context()
+		cfErr := extendStoreWithCodefershClient(lgr)
+		if cfErr != nil {
+			lgr.Warn(fmt.Sprintf("Runtime-environments are not collected: %v", cfErr))
+		}
+
+		files := plugins.SupportBundle{}
+		add := func(prefix string, bundle plugins.SupportBundle) {
+			for path, data := range bundle {
+				files[prefix+path] = data
+			}
+		}
+
+		lgr.Info("Collecting agent", "Kube-Context-Name", s.KubernetesAPI.ContextName, "Namespace", s.KubernetesAPI.Namespace)
+		agentKubeBuilder := getKubeClientBuilder(s.KubernetesAPI.ContextName, s.KubernetesAPI.Namespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false)
+		bundle, err := plugins.CollectSupportBundle(ctx, agentKubeBuilder, s.KubernetesAPI.Namespace)
+		dieOnError(err)
+		add("agent/", bundle)
+
+		runtimes, err := plugins.AttachedRuntimes(ctx, agentKubeBuilder, s.KubernetesAPI.Namespace)
+		if err != nil {
+			lgr.Warn(fmt.Sprintf("Cannot read the attached runtimes: %v", err))
+		}
+		for _, name := range runtimes {
+			prefix := fmt.Sprintf("runtimes/%s/", runtimePathReplacer.Replace(name))
+			if cfErr != nil {
+				files[prefix+"errors.txt"] = []byte(fmt.Sprintf("runtime-environment: %v\n", cfErr))
+				continue
+			}
+			re, err := s.CodefreshAPI.Client.RuntimeEnvironments().Get(name)
+			if err == nil && re == nil {
+				err = fmt.Errorf("Runtime-Environment %s not found", name)
+			}
+			if err != nil {
+				files[prefix+"errors.txt"] = []byte(fmt.Sprintf("runtime-environment: %v\n", err))
+				continue
+			}
+			data, err := redactedJSON(re)
+			dieOnError(err)
+			files[prefix+"runtime-environment.json"] = data
+
+			context := re.RuntimeScheduler.Cluster.ClusterProvider.Selector
+			namespace := re.RuntimeScheduler.Cluster.Namespace
+			if context == s.KubernetesAPI.ContextName && namespace == s.KubernetesAPI.Namespace {
+				// installed together with the agent, already collected
+				continue
+			}
+			lgr.Info("Collecting runtime", "Name", name, "Kube-Context-Name", context, "Namespace", namespace)
+			kubeBuilder := getKubeClientBuilder(context, namespace, s.KubernetesAPI.ConfigPath, s.KubernetesAPI.InCluster, false)
+			bundle, err := plugins.CollectSupportBundle(ctx, kubeBuilder, namespace)
+			if err != nil {
+				files[prefix+"errors.txt"] = []byte(fmt.Sprintf("%v\n", err))
+				continue
+			}
+			add(prefix, bundle)
+		}
+
+		file := supportBundleCmdOpt.file
+		if file == "" {
+			file = fmt.Sprintf("venonactl-support-%s.tar.gz", time.Now().Format("20060102-150405"))
+		}
+		dieOnError(writeTarGz(file, files))
+		lgr.Info(fmt.Sprintf("Support bundle written to %s", file))
+	},
+}
+
+// redactedJSON - returns the object as indented json in which sensitive values are replaced
+func redactedJSON(obj interface{}) ([]byte, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	values := map[string]interface{}{}
+	if err := json.Unmarshal(data, &values); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(plugins.RedactValues(values), "", "  ")
+}
+
+// writeTarGz - writes the files under a directory named after the archive
+func writeTarGz(file string, files plugins.SupportBundle) error {
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+	root := strings.TrimSuffix(filepath.Base(file), ".tar.gz")
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	now := time.Now()
+	for _, p := range paths {
+		data := files[p]
+		hdr := &tar.Header{
+			Name:    filepath.ToSlash(filepath.Join(root, p)),
+			Mode:    0644,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return err
+		}
+		if _, err := tw.Write(data); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	if err := gz.Close(); err != nil {
+		return err
+	}
+	return f.Close()
+}
+
+func init() {
+	rootCmd.AddCommand(supportBundleCmd)
+	viper.BindEnv("kube-namespace", "KUBE_NAMESPACE")
+	viper.BindEnv("kube-context", "KUBE_CONTEXT")
+
+	supportBundleCmd.Flags().StringVar(&supportBundleCmdOpt.kube.namespace, "kube-namespace", viper.GetString("kube-namespace"), "Name of the namespace of the agent [$KUBE_NAMESPACE]")
+	supportBundleCmd.Flags().StringVar(&supportBundleCmdOpt.kube.context, "kube-context-name", viper.GetString("kube-context"), "Name of the kubernetes context of the agent (default is current-context) [$KUBE_CONTEXT]")
+	supportBundleCmd.Flags().BoolVar(&supportBundleCmdOpt.kube.inCluster, "in-cluster", false, "Set flag if the command is running inside a cluster")
+	supportBundleCmd.Flags().StringVar(&supportBundleCmdOpt.file, "file", "", "Path of the tar.gz file (default is venonactl-support-<time>.tar.gz)")
+}
@@ -16,6 +16,9 @@ limitations under the License.
 */
 
 import (
+	"errors"
+	"os"
+
 	"github.com/codefresh-io/venona/venonactl/pkg/plugins"
 	"github.com/codefresh-io/venona/venonactl/pkg/store"
 	"github.com/spf13/cobra"
@@ -114,11 +117,29 @@ var testCommand = &cobra.Command{
 		var finalerr error
 		lgr.Info("Testing requirements")
 
+		doc := &testDocument{Passed: true, Results: []testResultDocument{}}
 		for _, p := range builder.Get() {
 			err := p.Test(cmd.Context(), options, values)
 			if err != nil && finalerr == nil {
 				finalerr = err
 			}
+			result := testResultDocument{Component: p.Name(), Passed: err == nil}
+			if err != nil {
+				doc.Passed = false
+				result.Error = err.Error()
+				var validationErr *plugins.ValidationError
+				if errors.As(err, &validationErr) {
+					result.Messages = validationErr.Messages
+				}
+			}
+			doc.Results = append(doc.Results, result)
+		}
+		if isStructuredOutput() {
+			printDocument(doc)
+			if !doc.Passed {
+				os.Exit(1)
+			}
+			return
 		}
 		dieOnError(finalerr)
 
@@ -31,6 +31,14 @@ var versionCmd = &cobra.Command{
 		s := store.GetStore()
 		lgr := createLogger("Version", verbose, logFormatter)
 		buildBasicStore(lgr)
+		if isStructuredOutput() {
+			printDocument(&versionDocument{
+				Version: s.Version.Current.Version,
+				Commit:  s.Version.Current.Commit,
+				Date:    s.Version.Current.Date,
+			})
+			return
+		}
 		fmt.Printf("Date: %s\n", s.Version.Current.Date)
 		fmt.Printf("Commit: %s\n", s.Version.Current.Commit)
 		fmt.Printf("Local Version: %s\n", s.Version.Current.Version)
@@ -52,6 +52,7 @@ type (
 		Validate() error
 		Sign() (*certs.ServerCert, error)
 		Register() (*codefresh.RuntimeEnvironment, error)
+		Unregister(name string) error
 	}
 
 	api struct {
@@ -208,3 +209,9 @@ func (a *api) Register() (*codefresh.RuntimeEnvironment, error) {
 
 	return re, nil
 }
+
+func (a *api) Unregister(name string) error {
+	a.logger.Debug("Unregistering runtime-environment", "name", name)
+	_, err := a.codefresh.RuntimeEnvironments().Delete(name)
+	return err
+}
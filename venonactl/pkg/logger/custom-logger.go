@@ -21,8 +21,12 @@ func PlainTextFormatter() log.Format {
 }
 
 func getStdoutHanlder(o *Options) log.Handler {
+	out := os.Stdout
+	if o.LogToStderr {
+		out = os.Stderr
+	}
 	if o.LogFormatter == Plain {
-		return log.StreamHandler(os.Stdout, PlainTextFormatter())
+		return log.StreamHandler(out, PlainTextFormatter())
 	}
-	return log.StdoutHandler
+	return log.StreamHandler(out, log.LogfmtFormat())
 }
@@ -14,6 +14,8 @@ type (
 		Verbose      bool
 		LogToFile    string
 		LogFormatter string
+		// LogToStderr - keeps stdout for the output of the command
+		LogToStderr bool
 	}
 )
 
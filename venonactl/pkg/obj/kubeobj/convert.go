@@ -0,0 +1,125 @@
+package kubeobj
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+var (
+	ingressV1 = schema.GroupVersion{Group: "networking.k8s.io", Version: "v1"}
+	appsV1    = schema.GroupVersion{Group: "apps", Version: "v1"}
+)
+
+// convert - moves the object to another version of its kind.
+// The versions of most kinds share the same schema, Ingress v1 changed the backends
+// and the workloads of apps v1 require the selector that older versions defaulted
+func convert(u *unstructured.Unstructured, to schema.GroupVersionKind) error {
+	from := u.GroupVersionKind()
+	u.SetGroupVersionKind(to)
+	if from.GroupVersion() == to.GroupVersion() {
+		return nil
+	}
+	switch {
+	case to.Kind == "Ingress" && to.GroupVersion() == ingressV1:
+		return convertIngressV1(u)
+	case to.GroupVersion() == appsV1 && (to.Kind == "Deployment" || to.Kind == "DaemonSet" || to.Kind == "ReplicaSet"):
+		return defaultSelector(u)
+	}
+	return nil
+}
+
+// defaultSelector - selects the pods by the labels of the template when the workload has no selector,
+// as extensions/v1beta1 and apps/v1beta1 did
+func defaultSelector(u *unstructured.Unstructured) error {
+	if _, ok, _ := unstructured.NestedFieldNoCopy(u.Object, "spec", "selector"); ok {
+		return nil
+	}
+	labels, ok, _ := unstructured.NestedMap(u.Object, "spec", "template", "metadata", "labels")
+	if !ok || len(labels) == 0 {
+		return nil
+	}
+	return unstructured.SetNestedMap(u.Object, labels, "spec", "selector", "matchLabels")
+}
+
+// convertIngressV1 - converts the backends of a beta Ingress
+func convertIngressV1(u *unstructured.Unstructured) error {
+	if backend, ok, _ := unstructured.NestedMap(u.Object, "spec", "backend"); ok {
+		unstructured.RemoveNestedField(u.Object, "spec", "backend")
+		if err := unstructured.SetNestedMap(u.Object, ingressBackendV1(backend), "spec", "defaultBackend"); err != nil {
+			return err
+		}
+	}
+	rules, ok, _ := unstructured.NestedSlice(u.Object, "spec", "rules")
+	if !ok {
+		return nil
+	}
+	for _, r := range rules {
+		rule, ok := r.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		paths, ok, _ := unstructured.NestedSlice(rule, "http", "paths")
+		if !ok {
+			continue
+		}
+		for _, p := range paths {
+			path, ok := p.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			if _, ok := path["pathType"]; !ok {
+				path["pathType"] = "ImplementationSpecific"
+			}
+			if backend, ok := path["backend"].(map[string]interface{}); ok {
+				path["backend"] = ingressBackendV1(backend)
+			}
+		}
+		if err := unstructured.SetNestedSlice(rule, paths, "http", "paths"); err != nil {
+			return err
+		}
+	}
+	return unstructured.SetNestedSlice(u.Object, rules, "spec", "rules")
+}
+
+// ingressBackendV1 - converts serviceName and servicePort of a beta backend to the v1 service backend
+func ingressBackendV1(backend map[string]interface{}) map[string]interface{} {
+	name, ok := backend["serviceName"]
+	if !ok {
+		return backend
+	}
+	port := map[string]interface{}{}
+	switch p := backend["servicePort"].(type) {
+	case string:
+		port["name"] = p
+	case int64:
+		port["number"] = p
+	case float64:
+		port["number"] = int64(p)
+	}
+	res := map[string]interface{}{
+		"service": map[string]interface{}{
+			"name": name,
+			"port": port,
+		},
+	}
+	if resource, ok := backend["resource"]; ok {
+		res["resource"] = resource
+	}
+	return res
+}
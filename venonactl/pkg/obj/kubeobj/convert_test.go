@@ -0,0 +1,209 @@
+package kubeobj
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func ingressBeta(spec map[string]interface{}) *unstructured.Unstructured {
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "extensions/v1beta1",
+		"kind":       "Ingress",
+		"metadata":   map[string]interface{}{"name": "app-proxy"},
+		"spec":       spec,
+	}}
+}
+
+func TestConvert(t *testing.T) {
+	ingress := ingressV1.WithKind("Ingress")
+	templateWithLabels := map[string]interface{}{
+		"metadata": map[string]interface{}{"labels": map[string]interface{}{"app": "dind-lv-monitor"}},
+	}
+	tests := []struct {
+		name     string
+		obj      *unstructured.Unstructured
+		to       schema.GroupVersionKind
+		wantSpec map[string]interface{}
+	}{
+		{
+			name: "should only change the version of other kinds",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "extensions/v1beta1",
+				"kind":       "Deployment",
+				"spec":       map[string]interface{}{"replicas": int64(1)},
+			}},
+			to:       schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"},
+			wantSpec: map[string]interface{}{"replicas": int64(1)},
+		},
+		{
+			name: "should select the pods of a moved workload by the labels of its template",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "extensions/v1beta1",
+				"kind":       "DaemonSet",
+				"spec":       map[string]interface{}{"template": templateWithLabels},
+			}},
+			to: appsV1.WithKind("DaemonSet"),
+			wantSpec: map[string]interface{}{
+				"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "dind-lv-monitor"}},
+				"template": templateWithLabels,
+			},
+		},
+		{
+			name: "should keep the selector of a moved workload",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "apps/v1beta2",
+				"kind":       "Deployment",
+				"spec": map[string]interface{}{
+					"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "runner"}},
+					"template": templateWithLabels,
+				},
+			}},
+			to: appsV1.WithKind("Deployment"),
+			wantSpec: map[string]interface{}{
+				"selector": map[string]interface{}{"matchLabels": map[string]interface{}{"app": "runner"}},
+				"template": templateWithLabels,
+			},
+		},
+		{
+			name:     "should move the default backend of an Ingress",
+			obj:      ingressBeta(map[string]interface{}{"backend": map[string]interface{}{"serviceName": "app-proxy", "servicePort": int64(80)}}),
+			to:       ingress,
+			wantSpec: map[string]interface{}{"defaultBackend": map[string]interface{}{"service": map[string]interface{}{"name": "app-proxy", "port": map[string]interface{}{"number": int64(80)}}}},
+		},
+		{
+			name: "should convert the path backends of an Ingress and default the path type",
+			obj: ingressBeta(map[string]interface{}{"rules": []interface{}{
+				map[string]interface{}{"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{"path": "/", "backend": map[string]interface{}{"serviceName": "app-proxy", "servicePort": "http"}},
+					map[string]interface{}{"path": "/api", "pathType": "Prefix", "backend": map[string]interface{}{"serviceName": "api", "servicePort": float64(8080)}},
+				}}},
+			}}),
+			to: ingress,
+			wantSpec: map[string]interface{}{"rules": []interface{}{
+				map[string]interface{}{"http": map[string]interface{}{"paths": []interface{}{
+					map[string]interface{}{"path": "/", "pathType": "ImplementationSpecific", "backend": map[string]interface{}{"service": map[string]interface{}{"name": "app-proxy", "port": map[string]interface{}{"name": "http"}}}},
+					map[string]interface{}{"path": "/api", "pathType": "Prefix", "backend": map[string]interface{}{"service": map[string]interface{}{"name": "api", "port": map[string]interface{}{"number": int64(8080)}}}},
+				}}},
+			}},
+		},
+		{
+			name: "should keep a resource backend",
+			obj: ingressBeta(map[string]interface{}{"backend": map[string]interface{}{
+				"resource": map[string]interface{}{"kind": "StorageBucket", "name": "static"},
+			}}),
+			to: ingress,
+			wantSpec: map[string]interface{}{"defaultBackend": map[string]interface{}{
+				"resource": map[string]interface{}{"kind": "StorageBucket", "name": "static"},
+			}},
+		},
+		{
+			name: "should not change an Ingress that is already v1",
+			obj: &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": "networking.k8s.io/v1",
+				"kind":       "Ingress",
+				"spec":       map[string]interface{}{"backend": map[string]interface{}{"serviceName": "app-proxy"}},
+			}},
+			to:       ingress,
+			wantSpec: map[string]interface{}{"backend": map[string]interface{}{"serviceName": "app-proxy"}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := convert(tt.obj, tt.to); err != nil {
+				t.Fatalf("convert() error = %v", err)
+			}
+			if gvk := tt.obj.GroupVersionKind(); gvk != tt.to {
+				t.Errorf("convert() kind = %v, want %v", gvk, tt.to)
+			}
+			if spec := tt.obj.Object["spec"]; !reflect.DeepEqual(spec, tt.wantSpec) {
+				t.Errorf("convert() spec = %v, want %v", spec, tt.wantSpec)
+			}
+		})
+	}
+}
+
+func TestDynamic_mapping(t *testing.T) {
+	// the preferred versions, as discovery reports them
+	mapper := meta.NewDefaultRESTMapper([]schema.GroupVersion{{Group: "apps", Version: "v1"}, ingressV1})
+	mapper.Add(schema.GroupVersionKind{Group: "apps", Version: "v1", Kind: "Deployment"}, meta.RESTScopeNamespace)
+	mapper.Add(ingressV1.WithKind("Ingress"), meta.RESTScopeNamespace)
+	d := &Dynamic{mapper: mapper}
+
+	tests := []struct {
+		name       string
+		apiVersion string
+		kind       string
+		want       string
+		wantErr    bool
+	}{
+		{
+			name:       "should use the served version",
+			apiVersion: "apps/v1",
+			kind:       "Deployment",
+			want:       "apps/v1",
+		},
+		{
+			name:       "should move a kind to its new group",
+			apiVersion: "extensions/v1beta1",
+			kind:       "Deployment",
+			want:       "apps/v1",
+		},
+		{
+			name:       "should move an Ingress to networking v1",
+			apiVersion: "extensions/v1beta1",
+			kind:       "Ingress",
+			want:       "networking.k8s.io/v1",
+		},
+		{
+			name:       "should fail on a kind that is not served",
+			apiVersion: "extensions/v1beta1",
+			kind:       "PodSecurityPolicy",
+			wantErr:    true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{Object: map[string]interface{}{
+				"apiVersion": tt.apiVersion,
+				"kind":       tt.kind,
+				"metadata":   map[string]interface{}{"name": "venona"},
+			}}
+			mapping, err := d.mapping(u)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("mapping() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("mapping() error = %v", err)
+			}
+			if got := mapping.GroupVersionKind.GroupVersion().String(); got != tt.want {
+				t.Errorf("mapping() = %s, want %s", got, tt.want)
+			}
+			if got := u.GetAPIVersion(); got != tt.want {
+				t.Errorf("mapping() converted the object to %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,246 @@
+package kubeobj
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/discovery"
+	"k8s.io/client-go/discovery/cached/memory"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/restmapper"
+)
+
+// FieldManager - name of the field manager of server-side apply requests
+const FieldManager = "venonactl"
+
+// movedKinds - kinds that moved to another group, tried when the cluster no longer serves the group of the object
+var movedKinds = map[schema.GroupKind]string{
+	{Group: "extensions", Kind: "Ingress"}:           "networking.k8s.io",
+	{Group: "extensions", Kind: "NetworkPolicy"}:     "networking.k8s.io",
+	{Group: "extensions", Kind: "PodSecurityPolicy"}: "policy",
+	{Group: "extensions", Kind: "Deployment"}:        "apps",
+	{Group: "extensions", Kind: "DaemonSet"}:         "apps",
+	{Group: "extensions", Kind: "ReplicaSet"}:        "apps",
+}
+
+// ObjectRef - identifies an object in the cluster
+type ObjectRef struct {
+	APIVersion string `json:"apiVersion"`
+	Kind       string `json:"kind"`
+	Namespace  string `json:"namespace,omitempty"`
+	Name       string `json:"name"`
+}
+
+// GroupKind - returns the group and kind of the object, the version is not part of the identity
+func (r ObjectRef) GroupKind() schema.GroupKind {
+	return schema.FromAPIVersionAndKind(r.APIVersion, r.Kind).GroupKind()
+}
+
+// Same - returns true when both refs point to the same object
+func (r ObjectRef) Same(o ObjectRef) bool {
+	return r.GroupKind() == o.GroupKind() && r.Namespace == o.Namespace && r.Name == o.Name
+}
+
+// RefOf - returns the reference of the object
+func RefOf(u *unstructured.Unstructured) ObjectRef {
+	return ObjectRef{
+		APIVersion: u.GetAPIVersion(),
+		Kind:       u.GetKind(),
+		Namespace:  u.GetNamespace(),
+		Name:       u.GetName(),
+	}
+}
+
+// Dynamic - reads and writes objects of any kind, including custom resources.
+// The resource of an object is found by discovery
+type Dynamic struct {
+	client dynamic.Interface
+	mapper meta.RESTMapper
+}
+
+// NewDynamic - creates Dynamic from the kubernetes config
+func NewDynamic(config *rest.Config) (*Dynamic, error) {
+	dc, err := discovery.NewDiscoveryClientForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	client, err := dynamic.NewForConfig(config)
+	if err != nil {
+		return nil, err
+	}
+	return &Dynamic{
+		client: client,
+		mapper: restmapper.NewDeferredDiscoveryRESTMapper(memory.NewMemCacheClient(dc)),
+	}, nil
+}
+
+// Get - returns the live object, nil when it does not exist
+func (d *Dynamic) Get(ctx context.Context, obj runtime.Object, namespace string) (*unstructured.Unstructured, error) {
+	ri, u, err := d.resource(obj, namespace)
+	if err != nil {
+		return nil, err
+	}
+	live, err := ri.Get(ctx, u.GetName(), metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return live, err
+}
+
+// Apply - server-side applies the object, conflicts with other managers are forced.
+// When dryRun is set nothing is persisted and the returned object is what would have been stored
+func (d *Dynamic) Apply(ctx context.Context, obj runtime.Object, namespace string, dryRun bool) (*unstructured.Unstructured, error) {
+	ri, u, err := d.resource(obj, namespace)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(u)
+	if err != nil {
+		return nil, err
+	}
+	force := true
+	opt := metav1.PatchOptions{FieldManager: FieldManager, Force: &force}
+	if dryRun {
+		opt.DryRun = []string{metav1.DryRunAll}
+	}
+	return ri.Patch(ctx, u.GetName(), types.ApplyPatchType, data, opt)
+}
+
+// Ref - returns the reference of the object as it is stored in the cluster
+func (d *Dynamic) Ref(obj runtime.Object, namespace string) (ObjectRef, error) {
+	_, u, err := d.resource(obj, namespace)
+	if err != nil {
+		return ObjectRef{}, err
+	}
+	return RefOf(u), nil
+}
+
+// GetRef - returns the referenced object, nil when it does not exist
+func (d *Dynamic) GetRef(ctx context.Context, ref ObjectRef) (*unstructured.Unstructured, error) {
+	ri, err := d.refResource(ref)
+	if err != nil {
+		return nil, err
+	}
+	live, err := ri.Get(ctx, ref.Name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	return live, err
+}
+
+// DeleteRef - deletes the referenced object, dependents are deleted in the background
+func (d *Dynamic) DeleteRef(ctx context.Context, ref ObjectRef) error {
+	var propagationPolicy metav1.DeletionPropagation = "Background"
+	ri, err := d.refResource(ref)
+	if err != nil {
+		return err
+	}
+	return ri.Delete(ctx, ref.Name, metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+}
+
+func (d *Dynamic) refResource(ref ObjectRef) (dynamic.ResourceInterface, error) {
+	mapping, err := d.mapper.RESTMapping(ref.GroupKind())
+	if err != nil {
+		return nil, err
+	}
+	if ref.Namespace == "" {
+		return d.client.Resource(mapping.Resource), nil
+	}
+	return d.client.Resource(mapping.Resource).Namespace(ref.Namespace), nil
+}
+
+// resource - returns the client of the object resource and the object as unstructured,
+// converted to the version served by the cluster
+func (d *Dynamic) resource(obj runtime.Object, namespace string) (dynamic.ResourceInterface, *unstructured.Unstructured, error) {
+	u, err := toUnstructured(obj)
+	if err != nil {
+		return nil, nil, err
+	}
+	mapping, err := d.mapping(u)
+	if err != nil {
+		return nil, u, err
+	}
+	if mapping.Scope.Name() != meta.RESTScopeNameNamespace {
+		return d.client.Resource(mapping.Resource), u, nil
+	}
+	u.SetNamespace(namespace)
+	return d.client.Resource(mapping.Resource).Namespace(namespace), u, nil
+}
+
+// mapping - finds the resource of the object. When the version of the object is not served
+// the preferred version of the kind is used, and the object is converted to it
+func (d *Dynamic) mapping(u *unstructured.Unstructured) (*meta.RESTMapping, error) {
+	gvk := u.GroupVersionKind()
+	mapping, err := d.mapper.RESTMapping(gvk.GroupKind(), gvk.Version)
+	if err == nil {
+		return mapping, nil
+	}
+	if !meta.IsNoMatchError(err) {
+		return nil, err
+	}
+
+	candidates := []schema.GroupKind{gvk.GroupKind()}
+	if group, ok := movedKinds[gvk.GroupKind()]; ok {
+		candidates = append(candidates, schema.GroupKind{Group: group, Kind: gvk.Kind})
+	}
+	for _, gk := range candidates {
+		mapping, mappingErr := d.mapper.RESTMapping(gk)
+		if mappingErr != nil {
+			continue
+		}
+		if err := convert(u, mapping.GroupVersionKind); err != nil {
+			return nil, err
+		}
+		return mapping, nil
+	}
+	return nil, fmt.Errorf("%s is not supported by the cluster: %w", gvk.String(), err)
+}
+
+// toUnstructured - converts the object without the fields owned by the server
+func toUnstructured(obj runtime.Object) (*unstructured.Unstructured, error) {
+	gvk := obj.GetObjectKind().GroupVersionKind()
+	if gvk.Empty() {
+		kinds, _, err := scheme.Scheme.ObjectKinds(obj)
+		if err != nil {
+			return nil, err
+		}
+		gvk = kinds[0]
+	}
+	content, err := runtime.DefaultUnstructuredConverter.ToUnstructured(obj)
+	if err != nil {
+		return nil, err
+	}
+	u := &unstructured.Unstructured{Object: content}
+	u.SetGroupVersionKind(gvk)
+	unstructured.RemoveNestedField(u.Object, "status")
+	unstructured.RemoveNestedField(u.Object, "metadata", "creationTimestamp")
+	return u, nil
+}
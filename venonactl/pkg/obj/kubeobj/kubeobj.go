@@ -1,581 +1,98 @@
-// Code generated by go generate; DO NOT EDIT.
-
 package kubeobj
 
-import (
-	"context"
-	"fmt"
-	"k8s.io/apimachinery/pkg/runtime"
-	"k8s.io/client-go/kubernetes"
+/*
+Copyright 2019 The Codefresh Authors.
 
-	appsv1 "k8s.io/api/apps/v1"
-	"k8s.io/api/core/v1"
-	v1beta1 "k8s.io/api/extensions/v1beta1"
-	netV1beta1 "k8s.io/api/networking/v1beta1"
-	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
 
-	rbacv1 "k8s.io/api/rbac/v1"
-	rbacv1beta1 "k8s.io/api/rbac/v1beta1"
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
 
-	storagev1 "k8s.io/api/storage/v1"
+import (
+	"context"
+	"errors"
 
-	batchv1 "k8s.io/api/batch/v1"
-	batchv1beta1 "k8s.io/api/batch/v1beta1"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 )
 
 // CreateObject - creates kubernetes object from *runtime.Object. Returns object name, kind and creation error
-func CreateObject(ctx context.Context, clientset *kubernetes.Clientset, obj runtime.Object, namespace string) (string, string, error) {
-
-	var name, kind string
-	var err error
-	switch objT := obj.(type) {
-
-	case *appsv1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.AppsV1().DaemonSets(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *appsv1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.AppsV1().Deployments(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *batchv1.Job:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.BatchV1().Jobs(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *batchv1beta1.CronJob:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.BatchV1beta1().CronJobs(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *netV1beta1.Ingress:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.NetworkingV1beta1().Ingresses(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().ClusterRoles().Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().ClusterRoleBindings().Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().Roles(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().RoleBindings(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1beta1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().ClusterRoles().Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1beta1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().ClusterRoleBindings().Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1beta1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().Roles(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *rbacv1beta1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().RoleBindings(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *storagev1.StorageClass:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.StorageV1().StorageClasses().Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1.ConfigMap:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().ConfigMaps(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1.PersistentVolume:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().PersistentVolumes().Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1.PersistentVolumeClaim:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1.Pod:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Pods(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1.Secret:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Secrets(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1.Service:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Services(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1.ServiceAccount:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().ServiceAccounts(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1beta1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.ExtensionsV1beta1().DaemonSets(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	case *v1beta1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.ExtensionsV1beta1().Deployments(namespace).Create(ctx, objT, metav1.CreateOptions{})
-
-	default:
-		return "", "", fmt.Errorf("Unknown object type %T\n ", objT)
+func CreateObject(ctx context.Context, d *Dynamic, obj runtime.Object, namespace string) (string, string, error) {
+	ri, u, err := d.resource(obj, namespace)
+	if err != nil {
+		return nameAndKind(u, err)
 	}
-	return name, kind, err
+	_, err = ri.Create(ctx, u, metav1.CreateOptions{FieldManager: FieldManager})
+	return u.GetName(), u.GetKind(), err
 }
 
-// CheckObject - checks kubernetes object from *runtime.Object. Returns object name, kind and creation error
-func CheckObject(ctx context.Context, clientset *kubernetes.Clientset, obj runtime.Object, namespace string) (string, string, error) {
-
-	var name, kind string
-	var err error
-	switch objT := obj.(type) {
-
-	case *appsv1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.AppsV1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *appsv1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.AppsV1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *batchv1.Job:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.BatchV1().Jobs(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *batchv1beta1.CronJob:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.BatchV1beta1().CronJobs(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *netV1beta1.Ingress:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.NetworkingV1beta1().Ingresses(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().ClusterRoles().Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().ClusterRoleBindings().Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().Roles(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().RoleBindings(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1beta1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().ClusterRoles().Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1beta1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().ClusterRoleBindings().Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1beta1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().Roles(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *rbacv1beta1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().RoleBindings(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *storagev1.StorageClass:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
-
-	case *v1.ConfigMap:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().ConfigMaps(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *v1.PersistentVolume:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().PersistentVolumes().Get(ctx, name, metav1.GetOptions{})
-
-	case *v1.PersistentVolumeClaim:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *v1.Pod:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Pods(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *v1.Secret:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Secrets(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *v1.Service:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Services(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *v1.ServiceAccount:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().ServiceAccounts(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *v1beta1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.ExtensionsV1beta1().DaemonSets(namespace).Get(ctx, name, metav1.GetOptions{})
-
-	case *v1beta1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.ExtensionsV1beta1().Deployments(namespace).Get(ctx, name, metav1.GetOptions{})
+// CheckObject - checks kubernetes object from *runtime.Object. Returns object name, kind and get error
+func CheckObject(ctx context.Context, d *Dynamic, obj runtime.Object, namespace string) (string, string, error) {
+	_, name, kind, err := GetObject(ctx, d, obj, namespace)
+	return name, kind, err
+}
 
-	default:
-		return "", "", fmt.Errorf("Unknown object type %T\n ", objT)
+// GetObject - gets kubernetes object from *runtime.Object. Returns the live object, object name, kind and get error
+func GetObject(ctx context.Context, d *Dynamic, obj runtime.Object, namespace string) (*unstructured.Unstructured, string, string, error) {
+	ri, u, err := d.resource(obj, namespace)
+	if err != nil {
+		name, kind, err := nameAndKind(u, err)
+		return nil, name, kind, err
 	}
-	return name, kind, err
+	live, err := ri.Get(ctx, u.GetName(), metav1.GetOptions{})
+	return live, u.GetName(), u.GetKind(), err
 }
 
-// DeleteObject - checks kubernetes object from *runtime.Object. Returns object name, kind and creation error
-func DeleteObject(ctx context.Context, clientset *kubernetes.Clientset, obj runtime.Object, namespace string) (string, string, error) {
+// DeleteObject - deletes kubernetes object from *runtime.Object, dependents are deleted in the background.
+// Returns object name, kind and deletion error
+func DeleteObject(ctx context.Context, d *Dynamic, obj runtime.Object, namespace string) (string, string, error) {
 	var propagationPolicy metav1.DeletionPropagation = "Background"
-	var name, kind string
-	var err error
-	switch objT := obj.(type) {
-
-	case *appsv1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.AppsV1().DaemonSets(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *appsv1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.AppsV1().Deployments(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *batchv1.Job:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.BatchV1().Jobs(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *batchv1beta1.CronJob:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.BatchV1beta1().CronJobs(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *netV1beta1.Ingress:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.NetworkingV1beta1().Ingresses(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1().ClusterRoles().Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1().ClusterRoleBindings().Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1().Roles(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1().RoleBindings(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1beta1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1beta1().ClusterRoles().Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1beta1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1beta1().ClusterRoleBindings().Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1beta1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1beta1().Roles(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *rbacv1beta1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.RbacV1beta1().RoleBindings(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *storagev1.StorageClass:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.StorageV1().StorageClasses().Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1.ConfigMap:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.CoreV1().ConfigMaps(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1.PersistentVolume:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.CoreV1().PersistentVolumes().Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1.PersistentVolumeClaim:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.CoreV1().PersistentVolumeClaims(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1.Pod:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.CoreV1().Pods(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1.Secret:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.CoreV1().Secrets(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1.Service:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.CoreV1().Services(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1.ServiceAccount:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.CoreV1().ServiceAccounts(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1beta1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.ExtensionsV1beta1().DaemonSets(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	case *v1beta1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		err = clientset.ExtensionsV1beta1().Deployments(namespace).Delete(ctx, name, metav1.DeleteOptions{
-			PropagationPolicy: &propagationPolicy,
-		})
-
-	default:
-		return "", "", fmt.Errorf("Unknown object type %T\n ", objT)
+	ri, u, err := d.resource(obj, namespace)
+	if err != nil {
+		return nameAndKind(u, err)
 	}
-	return name, kind, err
+	err = ri.Delete(ctx, u.GetName(), metav1.DeleteOptions{
+		PropagationPolicy: &propagationPolicy,
+	})
+	return u.GetName(), u.GetKind(), err
 }
 
-// ReplaceObject - replaces kubernetes object from *runtime.Object. Returns object name, kind and creation error
-func ReplaceObject(ctx context.Context, clientset *kubernetes.Clientset, obj runtime.Object, namespace string) (string, string, error) {
-	var name, kind string
-	var err error
-	switch objT := obj.(type) {
-
-	case *appsv1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.AppsV1().DaemonSets(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *appsv1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.AppsV1().Deployments(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *batchv1.Job:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.BatchV1().Jobs(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *batchv1beta1.CronJob:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.BatchV1beta1().CronJobs(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *netV1beta1.Ingress:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.NetworkingV1beta1().Ingresses(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().ClusterRoles().Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().ClusterRoleBindings().Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().Roles(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1().RoleBindings(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1beta1.ClusterRole:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().ClusterRoles().Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1beta1.ClusterRoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().ClusterRoleBindings().Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1beta1.Role:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().Roles(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *rbacv1beta1.RoleBinding:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.RbacV1beta1().RoleBindings(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *storagev1.StorageClass:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.StorageV1().StorageClasses().Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1.ConfigMap:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().ConfigMaps(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1.PersistentVolume:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().PersistentVolumes().Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1.PersistentVolumeClaim:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().PersistentVolumeClaims(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1.Pod:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Pods(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1.Secret:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Secrets(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1.Service:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().Services(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1.ServiceAccount:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.CoreV1().ServiceAccounts(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1beta1.DaemonSet:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.ExtensionsV1beta1().DaemonSets(namespace).Update(ctx, objT, metav1.UpdateOptions{})
-
-	case *v1beta1.Deployment:
-		name = objT.ObjectMeta.Name
-		kind = objT.TypeMeta.Kind
-		_, err = clientset.ExtensionsV1beta1().Deployments(namespace).Update(ctx, objT, metav1.UpdateOptions{})
+// ReplaceObject - replaces kubernetes object from *runtime.Object. Returns object name, kind and update error
+func ReplaceObject(ctx context.Context, d *Dynamic, obj runtime.Object, namespace string) (string, string, error) {
+	ri, u, err := d.resource(obj, namespace)
+	if err != nil {
+		return nameAndKind(u, err)
+	}
+	live, err := ri.Get(ctx, u.GetName(), metav1.GetOptions{})
+	if err != nil {
+		return u.GetName(), u.GetKind(), err
+	}
+	u.SetResourceVersion(live.GetResourceVersion())
+	_, err = ri.Update(ctx, u, metav1.UpdateOptions{FieldManager: FieldManager})
+	return u.GetName(), u.GetKind(), err
+}
 
-	default:
-		return "", "", fmt.Errorf("Unknown object type %T\n ", objT)
+// nameAndKind - returns the name and kind of an object that could not be mapped to a resource
+func nameAndKind(u *unstructured.Unstructured, err error) (string, string, error) {
+	if u == nil {
+		return "", "", err
 	}
-	return name, kind, err
+	return u.GetName(), u.GetKind(), err
+}
+
+// IsNotServed - returns true when the error is caused by a kind the cluster does not serve in any version
+func IsNotServed(err error) bool {
+	var kindErr *meta.NoKindMatchError
+	var resourceErr *meta.NoResourceMatchError
+	return errors.As(err, &kindErr) || errors.As(err, &resourceErr)
 }
@@ -0,0 +1,400 @@
+package kubeobjtest
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Resource - a resource served by the server
+type Resource struct {
+	GroupVersion string
+	Name         string
+	Kind         string
+	Namespaced   bool
+}
+
+// DefaultResources - the resources of the objects venonactl installs
+var DefaultResources = []Resource{
+	{GroupVersion: "v1", Name: "namespaces", Kind: "Namespace"},
+	{GroupVersion: "v1", Name: "secrets", Kind: "Secret", Namespaced: true},
+	{GroupVersion: "v1", Name: "configmaps", Kind: "ConfigMap", Namespaced: true},
+	{GroupVersion: "v1", Name: "serviceaccounts", Kind: "ServiceAccount", Namespaced: true},
+	{GroupVersion: "v1", Name: "services", Kind: "Service", Namespaced: true},
+	{GroupVersion: "v1", Name: "pods", Kind: "Pod", Namespaced: true},
+	{GroupVersion: "apps/v1", Name: "deployments", Kind: "Deployment", Namespaced: true},
+	{GroupVersion: "apps/v1", Name: "daemonsets", Kind: "DaemonSet", Namespaced: true},
+	{GroupVersion: "batch/v1beta1", Name: "cronjobs", Kind: "CronJob", Namespaced: true},
+	{GroupVersion: "rbac.authorization.k8s.io/v1", Name: "roles", Kind: "Role", Namespaced: true},
+	{GroupVersion: "rbac.authorization.k8s.io/v1", Name: "rolebindings", Kind: "RoleBinding", Namespaced: true},
+	{GroupVersion: "rbac.authorization.k8s.io/v1", Name: "clusterroles", Kind: "ClusterRole"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1", Name: "clusterrolebindings", Kind: "ClusterRoleBinding"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Name: "roles", Kind: "Role", Namespaced: true},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Name: "rolebindings", Kind: "RoleBinding", Namespaced: true},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Name: "clusterroles", Kind: "ClusterRole"},
+	{GroupVersion: "rbac.authorization.k8s.io/v1beta1", Name: "clusterrolebindings", Kind: "ClusterRoleBinding"},
+	{GroupVersion: "storage.k8s.io/v1", Name: "storageclasses", Kind: "StorageClass"},
+	{GroupVersion: "networking.k8s.io/v1beta1", Name: "ingresses", Kind: "Ingress", Namespaced: true},
+}
+
+// Server - an in-memory kubernetes api server for tests. Objects are stored by their path,
+// server-side apply replaces the stored object with the applied one
+type Server struct {
+	*httptest.Server
+	// Fail - when set and returns true for a request, the request fails with an internal error
+	Fail func(r *http.Request) bool
+
+	mutex     sync.Mutex
+	resources []Resource
+	objects   map[string]map[string]interface{}
+}
+
+// NewServer - starts a server that serves the resources, DefaultResources when none are given
+func NewServer(resources ...Resource) *Server {
+	if len(resources) == 0 {
+		resources = DefaultResources
+	}
+	s := &Server{
+		resources: resources,
+		objects:   map[string]map[string]interface{}{},
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	return s
+}
+
+// Path - returns the path of the object of the resource
+func Path(groupVersion, resource, namespace, name string) string {
+	prefix := "/apis/" + groupVersion
+	if groupVersion == "v1" {
+		prefix = "/api/v1"
+	}
+	if namespace != "" {
+		prefix = fmt.Sprintf("%s/namespaces/%s", prefix, namespace)
+	}
+	return fmt.Sprintf("%s/%s/%s", prefix, resource, name)
+}
+
+// Put - stores the object, the path is taken from its apiVersion, kind, namespace and name
+func (s *Server) Put(obj interface{}) {
+	u := toMap(obj)
+	path, err := s.objectPath(u)
+	if err != nil {
+		panic(err)
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.objects[path] = u
+}
+
+// Get - returns the object stored in the path, nil when it does not exist
+func (s *Server) Get(path string) map[string]interface{} {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.objects[path]
+}
+
+// Paths - the paths of the stored objects, sorted
+func (s *Server) Paths() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	paths := make([]string, 0, len(s.objects))
+	for p := range s.objects {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// Kubeconfig - returns a kubeconfig with the contexts, all pointing at the server
+func (s *Server) Kubeconfig(contexts ...string) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "apiVersion: v1\nkind: Config\nclusters:\n- name: test\n  cluster:\n    server: %s\nusers:\n- name: test\n  user: {}\ncontexts:\n", s.URL)
+	for _, c := range contexts {
+		fmt.Fprintf(&b, "- name: %s\n  context:\n    cluster: test\n    user: test\n", c)
+	}
+	if len(contexts) > 0 {
+		fmt.Fprintf(&b, "current-context: %s\n", contexts[0])
+	}
+	return b.String()
+}
+
+func toMap(obj interface{}) map[string]interface{} {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		panic(err)
+	}
+	u := map[string]interface{}{}
+	if err := json.Unmarshal(data, &u); err != nil {
+		panic(err)
+	}
+	return u
+}
+
+func (s *Server) objectPath(u map[string]interface{}) (string, error) {
+	apiVersion, _ := u["apiVersion"].(string)
+	kind, _ := u["kind"].(string)
+	metadata, _ := u["metadata"].(map[string]interface{})
+	name, _ := metadata["name"].(string)
+	namespace, _ := metadata["namespace"].(string)
+	for _, r := range s.resources {
+		if r.GroupVersion == apiVersion && r.Kind == kind {
+			if !r.Namespaced {
+				namespace = ""
+			}
+			return Path(apiVersion, r.Name, namespace, name), nil
+		}
+	}
+	return "", fmt.Errorf("%s %s is not served", apiVersion, kind)
+}
+
+// route - splits the path to the resource and the name, the name is empty for collections
+func (s *Server) route(path string) (*Resource, string, bool) {
+	var groupVersion string
+	var rest []string
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	switch {
+	case len(parts) >= 2 && parts[0] == "api":
+		groupVersion, rest = parts[1], parts[2:]
+	case len(parts) >= 3 && parts[0] == "apis":
+		groupVersion, rest = parts[1]+"/"+parts[2], parts[3:]
+	default:
+		return nil, "", false
+	}
+	if len(rest) > 2 && rest[0] == "namespaces" {
+		rest = rest[2:]
+	}
+	if len(rest) == 0 || len(rest) > 2 {
+		return nil, "", false
+	}
+	for i := range s.resources {
+		r := &s.resources[i]
+		if r.GroupVersion == groupVersion && r.Name == rest[0] {
+			if len(rest) == 2 {
+				return r, rest[1], true
+			}
+			return r, "", true
+		}
+	}
+	return nil, "", false
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	if s.Fail != nil && s.Fail(r) {
+		writeStatus(w, http.StatusInternalServerError, metav1.StatusReasonInternalError, "injected failure")
+		return
+	}
+	if body, ok := s.discovery(r.URL.Path); ok {
+		writeJSON(w, http.StatusOK, body)
+		return
+	}
+	resource, name, ok := s.route(r.URL.Path)
+	if !ok {
+		writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, r.URL.Path+" not found")
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if name == "" {
+		s.serveCollection(w, r, resource)
+		return
+	}
+	obj, exists := s.objects[r.URL.Path]
+	switch r.Method {
+	case http.MethodGet:
+		if !exists {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, name+" not found")
+			return
+		}
+		writeJSON(w, http.StatusOK, obj)
+	case http.MethodPatch, http.MethodPut:
+		u, err := readObject(r)
+		if err != nil {
+			writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+			return
+		}
+		if r.Method == http.MethodPut && !exists {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, name+" not found")
+			return
+		}
+		if !isDryRun(r) {
+			s.objects[r.URL.Path] = u
+		}
+		writeJSON(w, http.StatusOK, u)
+	case http.MethodDelete:
+		if !exists {
+			writeStatus(w, http.StatusNotFound, metav1.StatusReasonNotFound, name+" not found")
+			return
+		}
+		delete(s.objects, r.URL.Path)
+		writeStatus(w, http.StatusOK, "", "deleted")
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, r.Method)
+	}
+}
+
+func (s *Server) serveCollection(w http.ResponseWriter, r *http.Request, resource *Resource) {
+	switch r.Method {
+	case http.MethodGet:
+		items := []interface{}{}
+		for _, p := range sortedKeys(s.objects) {
+			if strings.HasPrefix(p, r.URL.Path+"/") && !strings.Contains(strings.TrimPrefix(p, r.URL.Path+"/"), "/") {
+				items = append(items, s.objects[p])
+			}
+		}
+		writeJSON(w, http.StatusOK, map[string]interface{}{
+			"apiVersion": resource.GroupVersion,
+			"kind":       resource.Kind + "List",
+			"metadata":   map[string]interface{}{},
+			"items":      items,
+		})
+	case http.MethodPost:
+		u, err := readObject(r)
+		if err != nil {
+			writeStatus(w, http.StatusBadRequest, metav1.StatusReasonBadRequest, err.Error())
+			return
+		}
+		metadata, _ := u["metadata"].(map[string]interface{})
+		name, _ := metadata["name"].(string)
+		path := r.URL.Path + "/" + name
+		if _, exists := s.objects[path]; exists {
+			writeStatus(w, http.StatusConflict, metav1.StatusReasonAlreadyExists, name+" already exists")
+			return
+		}
+		if !isDryRun(r) {
+			s.objects[path] = u
+		}
+		writeJSON(w, http.StatusCreated, u)
+	default:
+		writeStatus(w, http.StatusMethodNotAllowed, metav1.StatusReasonMethodNotAllowed, r.Method)
+	}
+}
+
+func (s *Server) discovery(path string) (interface{}, bool) {
+	switch path {
+	case "/api":
+		return &metav1.APIVersions{TypeMeta: metav1.TypeMeta{Kind: "APIVersions"}, Versions: []string{"v1"}}, true
+	case "/apis":
+		groups := map[string]*metav1.APIGroup{}
+		var names []string
+		for _, r := range s.resources {
+			if r.GroupVersion == "v1" {
+				continue
+			}
+			parts := strings.SplitN(r.GroupVersion, "/", 2)
+			g, ok := groups[parts[0]]
+			if !ok {
+				g = &metav1.APIGroup{Name: parts[0]}
+				groups[parts[0]] = g
+				names = append(names, parts[0])
+			}
+			gv := metav1.GroupVersionForDiscovery{GroupVersion: r.GroupVersion, Version: parts[1]}
+			if !containsVersion(g.Versions, gv) {
+				g.Versions = append(g.Versions, gv)
+				if len(g.Versions) == 1 {
+					g.PreferredVersion = gv
+				}
+			}
+		}
+		list := &metav1.APIGroupList{TypeMeta: metav1.TypeMeta{Kind: "APIGroupList", APIVersion: "v1"}}
+		for _, n := range names {
+			list.Groups = append(list.Groups, *groups[n])
+		}
+		return list, true
+	}
+	groupVersion := strings.TrimPrefix(strings.TrimPrefix(path, "/apis/"), "/api/")
+	if groupVersion == path {
+		return nil, false
+	}
+	list := &metav1.APIResourceList{TypeMeta: metav1.TypeMeta{Kind: "APIResourceList", APIVersion: "v1"}, GroupVersion: groupVersion}
+	for _, r := range s.resources {
+		if r.GroupVersion == groupVersion {
+			list.APIResources = append(list.APIResources, metav1.APIResource{
+				Name:       r.Name,
+				Namespaced: r.Namespaced,
+				Kind:       r.Kind,
+				Verbs:      metav1.Verbs{"get", "list", "create", "update", "patch", "delete"},
+			})
+		}
+	}
+	if len(list.APIResources) == 0 {
+		return nil, false
+	}
+	return list, true
+}
+
+func containsVersion(versions []metav1.GroupVersionForDiscovery, gv metav1.GroupVersionForDiscovery) bool {
+	for _, v := range versions {
+		if v == gv {
+			return true
+		}
+	}
+	return false
+}
+
+func sortedKeys(m map[string]map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func isDryRun(r *http.Request) bool {
+	return r.URL.Query().Get("dryRun") != ""
+}
+
+func readObject(r *http.Request) (map[string]interface{}, error) {
+	data, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		return nil, err
+	}
+	u := map[string]interface{}{}
+	if err := json.Unmarshal(data, &u); err != nil {
+		return nil, err
+	}
+	return u, nil
+}
+
+func writeJSON(w http.ResponseWriter, code int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
+
+func writeStatus(w http.ResponseWriter, code int, reason metav1.StatusReason, message string) {
+	status := metav1.StatusSuccess
+	if code >= 300 {
+		status = metav1.StatusFailure
+	}
+	writeJSON(w, code, &metav1.Status{
+		TypeMeta: metav1.TypeMeta{Kind: "Status", APIVersion: "v1"},
+		Status:   status,
+		Reason:   reason,
+		Message:  message,
+		Code:     int32(code),
+	})
+}
@@ -46,14 +46,15 @@ func (u *appProxyPlugin) Install(ctx context.Context, opt *InstallOptions, v Val
 		u.logger.Error(fmt.Sprintf("Cannot ensure namespace exists: %v", err))
 		return nil, err
 	}
-	err = install(ctx, &installOptions{
+	err = apply(ctx, &applyOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.KubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   appProxyFilesPattern,
 		dryRun:         opt.DryRun,
+		dryRunObjects:  opt.DryRunObjects,
 		operatorType:   AppProxyPluginType,
 	})
 	if err != nil {
@@ -73,15 +74,10 @@ func (u *appProxyPlugin) Status(ctx context.Context, statusOpt *StatusOptions, v
 }
 
 func (u *appProxyPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v Values) error {
-	cs, err := deleteOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return err
-	}
 	opt := &deleteOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    deleteOpt.KubeBuilder,
 		namespace:      deleteOpt.ClusterNamespace,
 		matchPattern:   appProxyFilesPattern,
 		operatorType:   AppProxyPluginType,
@@ -97,6 +93,22 @@ func (u *appProxyPlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Val
 		return nil, err
 	}
 
+	err = apply(ctx, &applyOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   appProxyFilesPattern,
+		operatorType:   AppProxyPluginType,
+	})
+	if err != nil {
+		u.logger.Error(fmt.Sprintf("AppProxy upgrade failed: %v", err))
+		return nil, err
+	}
+
+	// the image tag does not change between releases (latest),
+	// restart the pods so the image is pulled again
 	list, err := kubeClientset.CoreV1().Pods(opt.ClusterNamespace).List(ctx, metav1.ListOptions{LabelSelector: fmt.Sprintf("app=%v", opt.Name)})
 	if err != nil {
 		u.logger.Error(fmt.Sprintf("Failed to list app-proxy pods: %v ", err))
@@ -127,6 +139,28 @@ func (u *appProxyPlugin) Test(ctx context.Context, opt *TestOptions, v Values) e
 	return nil
 }
 
+func (u *appProxyPlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	return diff(ctx, &diffOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   appProxyFilesPattern,
+		operatorType:   AppProxyPluginType,
+	})
+}
+
+func (u *appProxyPlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return rollback(ctx, &rollbackOptions{
+		logger:       u.logger,
+		kubeBuilder:  opt.KubeBuilder,
+		namespace:    opt.ClusterNamespace,
+		revision:     opt.Revision,
+		operatorType: AppProxyPluginType,
+	})
+}
+
 func (u *appProxyPlugin) Name() string {
 	return AppProxyPluginType
 }
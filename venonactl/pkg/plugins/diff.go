@@ -0,0 +1,144 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"crypto/sha256"
+	"fmt"
+	"sort"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
+	"github.com/pmezard/go-difflib/difflib"
+	"gopkg.in/yaml.v2"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+type (
+	// ObjectDiff - difference between the live object and the rendered template
+	ObjectDiff struct {
+		Kind string
+		Name string
+		// Diff is a unified diff of the objects as YAML, empty when nothing would change
+		Diff string
+	}
+)
+
+// server populated fields, never part of the diff
+var ignoredFields = [][]string{
+	{"status"},
+	{"metadata", "managedFields"},
+	{"metadata", "resourceVersion"},
+	{"metadata", "uid"},
+	{"metadata", "generation"},
+	{"metadata", "creationTimestamp"},
+	{"metadata", "selfLink"},
+	{"metadata", "annotations", "deployment.kubernetes.io/revision"},
+	{"metadata", "annotations", "kubectl.kubernetes.io/last-applied-configuration"},
+}
+
+func diff(ctx context.Context, opt *diffOptions) ([]ObjectDiff, error) {
+	kubeObjects, err := KubeObjectsFromTemplates(opt.templates, opt.templateValues, opt.matchPattern, opt.logger)
+	if err != nil {
+		return nil, err
+	}
+	config, err := opt.kubeBuilder.BuildConfig()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create kubernetes config: %v", err)
+	}
+	d, err := kubeobj.NewDynamic(config)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(kubeObjects))
+	for n := range kubeObjects {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	res := []ObjectDiff{}
+	for _, n := range names {
+		obj := kubeObjects[n]
+		// install stamps the ownership labels, without them they would show as removed
+		if err := stampLabels(obj, opt.namespace, opt.operatorType); err != nil {
+			return nil, err
+		}
+		live, err := d.Get(ctx, obj, opt.namespace)
+		if err != nil {
+			return nil, err
+		}
+		// the server merges the template into the live object exactly as install would
+		rendered, err := d.Apply(ctx, obj, opt.namespace, true)
+		if err != nil {
+			return nil, err
+		}
+		od, err := diffObjects(live, rendered)
+		if err != nil {
+			return nil, err
+		}
+		opt.logger.Debug(fmt.Sprintf("%s \"%s\" compared", od.Kind, od.Name), "changed", od.Diff != "")
+		res = append(res, od)
+	}
+	return res, nil
+}
+
+func diffObjects(live *unstructured.Unstructured, rendered *unstructured.Unstructured) (ObjectDiff, error) {
+	od := ObjectDiff{
+		Kind: rendered.GetKind(),
+		Name: rendered.GetName(),
+	}
+	from, err := diffYAML(live)
+	if err != nil {
+		return od, err
+	}
+	to, err := diffYAML(rendered)
+	if err != nil {
+		return od, err
+	}
+	od.Diff, err = difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(from),
+		B:        difflib.SplitLines(to),
+		FromFile: fmt.Sprintf("live/%s/%s", od.Kind, od.Name),
+		ToFile:   fmt.Sprintf("rendered/%s/%s", od.Kind, od.Name),
+		Context:  3,
+	})
+	return od, err
+}
+
+// diffYAML - the object as YAML without server populated fields and with secret values hashed
+func diffYAML(obj *unstructured.Unstructured) (string, error) {
+	if obj == nil {
+		return "", nil
+	}
+	obj = obj.DeepCopy()
+	for _, f := range ignoredFields {
+		unstructured.RemoveNestedField(obj.Object, f...)
+	}
+	if obj.GetKind() == "Secret" {
+		for _, field := range []string{"data", "stringData"} {
+			data, ok := obj.Object[field].(map[string]interface{})
+			if !ok {
+				continue
+			}
+			for k, v := range data {
+				data[k] = fmt.Sprintf("(sha256:%x)", sha256.Sum256([]byte(fmt.Sprint(v))))
+			}
+		}
+	}
+	b, err := yaml.Marshal(obj.Object)
+	return string(b), err
+}
@@ -0,0 +1,96 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"strings"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func secret(data map[string]interface{}, meta map[string]interface{}) *unstructured.Unstructured {
+	metadata := map[string]interface{}{"name": "venona", "namespace": "codefresh"}
+	for k, v := range meta {
+		metadata[k] = v
+	}
+	return &unstructured.Unstructured{Object: map[string]interface{}{
+		"apiVersion": "v1",
+		"kind":       "Secret",
+		"metadata":   metadata,
+		"data":       data,
+	}}
+}
+
+func TestDiffObjects(t *testing.T) {
+	tests := []struct {
+		name     string
+		live     *unstructured.Unstructured
+		rendered *unstructured.Unstructured
+		want     []string
+		notWant  []string
+	}{
+		{
+			name: "should ignore server populated fields",
+			live: secret(map[string]interface{}{"token": "c2VjcmV0"}, map[string]interface{}{
+				"resourceVersion":   "42",
+				"uid":               "0b0e1c5e",
+				"creationTimestamp": "2020-01-01T00:00:00Z",
+				"annotations": map[string]interface{}{
+					"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				},
+			}),
+			rendered: secret(map[string]interface{}{"token": "c2VjcmV0"}, map[string]interface{}{
+				"annotations": map[string]interface{}{},
+			}),
+		},
+		{
+			name:     "should show a changed secret value hashed",
+			live:     secret(map[string]interface{}{"token": "b2xk"}, nil),
+			rendered: secret(map[string]interface{}{"token": "bmV3"}, nil),
+			want:     []string{"-  token: (sha256:", "+  token: (sha256:"},
+			notWant:  []string{"b2xk", "bmV3"},
+		},
+		{
+			name:     "should show a new object as added",
+			rendered: secret(map[string]interface{}{"token": "bmV3"}, nil),
+			want:     []string{"+kind: Secret"},
+			notWant:  []string{"bmV3"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := diffObjects(tt.live, tt.rendered)
+			if err != nil {
+				t.Fatalf("diffObjects() error = %v", err)
+			}
+			if len(tt.want) == 0 && got.Diff != "" {
+				t.Errorf("diffObjects() = %q, want no diff", got.Diff)
+			}
+			for _, w := range tt.want {
+				if !strings.Contains(got.Diff, w) {
+					t.Errorf("diffObjects() = %q, want it to contain %q", got.Diff, w)
+				}
+			}
+			for _, w := range tt.notWant {
+				if strings.Contains(got.Diff, w) {
+					t.Errorf("diffObjects() = %q, must not contain %q", got.Diff, w)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,292 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	authv1 "k8s.io/api/authorization/v1"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// SeverityCritical - the runner cannot run builds
+	SeverityCritical = "critical"
+	// SeverityWarning - builds may fail or the runner will stop working
+	SeverityWarning = "warning"
+	// SeverityInfo - nothing is broken
+	SeverityInfo = "info"
+
+	// agentLogLines - lines of the log of every agent pod that are searched for known errors
+	agentLogLines = int64(1000)
+)
+
+var severityOrder = map[string]int{
+	SeverityCritical: 0,
+	SeverityWarning:  1,
+	SeverityInfo:     2,
+}
+
+// Finding - a problem found by the diagnostics of an installed runner
+type Finding struct {
+	Severity    string `json:"severity" yaml:"severity"`
+	Check       string `json:"check" yaml:"check"`
+	Message     string `json:"message" yaml:"message"`
+	Remediation string `json:"remediation,omitempty" yaml:"remediation,omitempty"`
+}
+
+// SortFindings - orders the findings by severity, keeping the order of the checks within a severity
+func SortFindings(findings []Finding) {
+	sort.SliceStable(findings, func(i, j int) bool {
+		return severityOrder[findings[i].Severity] < severityOrder[findings[j].Severity]
+	})
+}
+
+// logPattern - an error in the log of the agent and how to fix it
+type logPattern struct {
+	re          *regexp.Regexp
+	severity    string
+	remediation string
+}
+
+var agentLogPatterns = []logPattern{
+	{
+		re:          regexp.MustCompile(`Runtime environment not found|Runtime not found`),
+		severity:    SeverityCritical,
+		remediation: "Attach the runtime to the agent with `venonactl attach`",
+	},
+	{
+		re:          regexp.MustCompile(`No healthy cluster in runtime pool`),
+		severity:    SeverityCritical,
+		remediation: "Check that the runtime clusters are reachable from the agent and their service account tokens are valid",
+	},
+	{
+		re:          regexp.MustCompile(`(?i)\b401\b|unauthorized`),
+		severity:    SeverityCritical,
+		remediation: "The agent token was revoked, reinstall the agent with a new token",
+	},
+	{
+		re:          regexp.MustCompile(`x509:`),
+		severity:    SeverityCritical,
+		remediation: "The certificate of Codefresh or of a runtime cluster is not trusted, check the CA of the agent",
+	},
+	{
+		re:          regexp.MustCompile(`(?i)forbidden`),
+		severity:    SeverityCritical,
+		remediation: "The service account of the runtime is missing permissions, run `venonactl upgrade` to restore the roles",
+	},
+	{
+		re:          regexp.MustCompile(`connection refused|no such host|i/o timeout|TLS handshake timeout`),
+		severity:    SeverityWarning,
+		remediation: "Check the network policies, proxy and DNS of the agent namespace",
+	},
+	{
+		re:          regexp.MustCompile(`Failed to rotate token|token rotated but not persisted`),
+		severity:    SeverityWarning,
+		remediation: "The agent cannot update its token secret, check the role of the agent",
+	},
+	{
+		re:          regexp.MustCompile(`Workflow rejected, quota exceeded`),
+		severity:    SeverityWarning,
+		remediation: "Raise the quota of the runtime or lower the concurrency of the builds",
+	},
+	{
+		re:          regexp.MustCompile(`Workflow queued, runtime is out of capacity`),
+		severity:    SeverityInfo,
+		remediation: "Builds are waiting for capacity, add nodes to the runtime cluster",
+	},
+}
+
+// AgentLogFindings - searches the recent log of every agent pod for known errors,
+// every pattern is reported once per pod with the number of matching lines
+func AgentLogFindings(ctx context.Context, kubeBuilder interface {
+	BuildConfig() (*rest.Config, error)
+}, namespace string, appName string) ([]Finding, error) {
+	cs, err := newClientset(kubeBuilder)
+	if err != nil {
+		return nil, err
+	}
+	pods, err := cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", appName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var findings []Finding
+	for _, pod := range pods.Items {
+		tail := agentLogLines
+		data, err := cs.CoreV1().Pods(namespace).GetLogs(pod.Name, &v1.PodLogOptions{TailLines: &tail}).DoRaw(ctx)
+		if err != nil {
+			findings = append(findings, Finding{
+				Severity: SeverityWarning,
+				Check:    "agent logs",
+				Message:  fmt.Sprintf("Cannot read the log of pod %s: %v", pod.Name, err),
+			})
+			continue
+		}
+		findings = append(findings, logFindings(pod.Name, data)...)
+	}
+	return findings, nil
+}
+
+func logFindings(pod string, data []byte) []Finding {
+	matches := make([]int, len(agentLogPatterns))
+	last := make([]string, len(agentLogPatterns))
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		line := scanner.Text()
+		for i, p := range agentLogPatterns {
+			if p.re.MatchString(line) {
+				matches[i]++
+				last[i] = line
+				break
+			}
+		}
+	}
+	var findings []Finding
+	for i, p := range agentLogPatterns {
+		if matches[i] == 0 {
+			continue
+		}
+		findings = append(findings, Finding{
+			Severity:    p.severity,
+			Check:       "agent logs",
+			Message:     fmt.Sprintf("%d lines of pod %s match %q, last: %s", matches[i], pod, p.re.String(), strings.TrimSpace(last[i])),
+			Remediation: p.remediation,
+		})
+	}
+	return findings
+}
+
+// RuntimeTokenFindings - verifies that the service account token of every runtime in the runnerconf
+// secret of the agent is accepted by the runtime cluster and allows to create pods in the namespace
+// of the runtime. namespaces maps the runtime names to their namespace
+func RuntimeTokenFindings(ctx context.Context, agentKubeBuilder KubeClientBuilder, agentNamespace string, namespaces map[string]string) ([]Finding, error) {
+	conf, err := readCurrentVenonaConf(ctx, agentKubeBuilder, agentNamespace)
+	if err != nil {
+		return nil, err
+	}
+	keys := make([]string, 0, len(conf.Runtimes))
+	for k := range conf.Runtimes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var findings []Finding
+	for _, k := range keys {
+		rc := conf.Runtimes[k]
+		namespace, ok := namespaces[rc.Name]
+		if !ok {
+			continue
+		}
+		if err := checkRuntimeToken(ctx, rc, namespace); err != nil {
+			findings = append(findings, Finding{
+				Severity:    SeverityCritical,
+				Check:       "runtime token",
+				Message:     fmt.Sprintf("The token of runtime %s in %s is not accepted by %s: %v", rc.Name, runtimeSecretName, rc.Host, err),
+				Remediation: fmt.Sprintf("Attach the runtime again with `venonactl attach --runtimeName %s` to refresh the token", rc.Name),
+			})
+		}
+	}
+	return findings, nil
+}
+
+func checkRuntimeToken(ctx context.Context, rc RuntimeConfiguration, namespace string) error {
+	cs, err := kubernetes.NewForConfig(&rest.Config{
+		Host:        rc.Host,
+		BearerToken: rc.Token,
+		TLSClientConfig: rest.TLSClientConfig{
+			CAData: []byte(rc.Crt),
+		},
+	})
+	if err != nil {
+		return err
+	}
+	review, err := cs.AuthorizationV1().SelfSubjectAccessReviews().Create(ctx, &authv1.SelfSubjectAccessReview{
+		Spec: authv1.SelfSubjectAccessReviewSpec{
+			ResourceAttributes: &authv1.ResourceAttributes{
+				Namespace: namespace,
+				Verb:      "create",
+				Resource:  "pods",
+			},
+		},
+	}, metav1.CreateOptions{})
+	if err != nil {
+		return err
+	}
+	if !review.Status.Allowed {
+		return fmt.Errorf("not allowed to create pods in namespace %s", namespace)
+	}
+	return nil
+}
+
+// statusRemediations - how to fix objects that are not healthy, by kind
+var statusRemediations = map[string]string{
+	"Deployment":   "Check the events and logs of the pods of the deployment",
+	"DaemonSet":    "Check the events of the pods on the listed nodes",
+	"Secret":       "Run `venonactl upgrade` to renew the certificates",
+	"StorageClass": "Create the storage class or install the runtime with the default volume provisioner",
+	"Pod":          "Check the logs of the pod, the agent is not serving /health",
+	"Runtime":      "Attach the runtime with `venonactl attach` or detach runtimes that were deleted in Codefresh",
+}
+
+// StatusFindings - converts the status rows of the plugins to findings, objects that are not installed
+// or unhealthy are critical, objects that are progressing or stale are warnings
+func StatusFindings(rows [][]string) []Finding {
+	var findings []Finding
+	for _, row := range rows {
+		kind, name, status := row[0], row[1], row[2]
+		message := fmt.Sprintf("%s %s is %s", kind, name, status)
+		if len(row) > 3 && row[3] != "" {
+			message = fmt.Sprintf("%s: %s", message, row[3])
+		}
+		f := Finding{
+			Check:       "status",
+			Message:     message,
+			Remediation: statusRemediations[kind],
+		}
+		switch status {
+		case StatusNotInstalled:
+			f.Severity = SeverityCritical
+			f.Remediation = "Run `venonactl upgrade` or reinstall the component"
+		case StatusUnhealthy:
+			f.Severity = SeverityCritical
+		case StatusProgressing:
+			f.Severity = SeverityWarning
+			f.Remediation = "Wait for the rollout to finish, check the events if it does not"
+		case StatusStale:
+			f.Severity = SeverityWarning
+			f.Remediation = "Run `venonactl upgrade` to prune the object"
+		default:
+			if len(row) > 3 && (strings.Contains(row[3], "expires in") || strings.Contains(row[3], "restarted")) {
+				f.Severity = SeverityWarning
+				break
+			}
+			continue
+		}
+		findings = append(findings, f)
+	}
+	return findings
+}
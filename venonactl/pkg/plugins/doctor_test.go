@@ -0,0 +1,210 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestLogFindings(t *testing.T) {
+	tests := []struct {
+		name string
+		log  string
+		want []Finding
+	}{
+		{
+			name: "no errors",
+			log:  "Starting agent\nWorkflow started\n",
+			want: nil,
+		},
+		{
+			name: "matching lines are counted, the last one is reported",
+			log:  "Failed: 401 Unauthorized\nWorkflow started\nrequest failed: unauthorized\n",
+			want: []Finding{{
+				Severity:    SeverityCritical,
+				Check:       "agent logs",
+				Message:     `2 lines of pod agent-1 match "(?i)\\b401\\b|unauthorized", last: request failed: unauthorized`,
+				Remediation: "The agent token was revoked, reinstall the agent with a new token",
+			}},
+		},
+		{
+			name: "every pattern is reported in order",
+			log:  "  Workflow queued, runtime is out of capacity  \ndial tcp: lookup cluster: no such host\nRuntime not found\n",
+			want: []Finding{
+				{
+					Severity:    SeverityCritical,
+					Check:       "agent logs",
+					Message:     `1 lines of pod agent-1 match "Runtime environment not found|Runtime not found", last: Runtime not found`,
+					Remediation: "Attach the runtime to the agent with `venonactl attach`",
+				},
+				{
+					Severity:    SeverityWarning,
+					Check:       "agent logs",
+					Message:     `1 lines of pod agent-1 match "connection refused|no such host|i/o timeout|TLS handshake timeout", last: dial tcp: lookup cluster: no such host`,
+					Remediation: "Check the network policies, proxy and DNS of the agent namespace",
+				},
+				{
+					Severity:    SeverityInfo,
+					Check:       "agent logs",
+					Message:     `1 lines of pod agent-1 match "Workflow queued, runtime is out of capacity", last: Workflow queued, runtime is out of capacity`,
+					Remediation: "Builds are waiting for capacity, add nodes to the runtime cluster",
+				},
+			},
+		},
+		{
+			name: "a line is counted for the first pattern it matches",
+			log:  "x509: certificate signed by unknown authority, unauthorized\n",
+			want: []Finding{{
+				Severity:    SeverityCritical,
+				Check:       "agent logs",
+				Message:     `1 lines of pod agent-1 match "(?i)\\b401\\b|unauthorized", last: x509: certificate signed by unknown authority, unauthorized`,
+				Remediation: "The agent token was revoked, reinstall the agent with a new token",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := logFindings("agent-1", []byte(tt.log)); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("logFindings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestStatusFindings(t *testing.T) {
+	tests := []struct {
+		name string
+		row  []string
+		want []Finding
+	}{
+		{
+			name: "healthy object",
+			row:  []string{"Deployment", "runner", StatusHealthy},
+			want: nil,
+		},
+		{
+			name: "installed object",
+			row:  []string{"Role", "runner", StatusInstalled, ""},
+			want: nil,
+		},
+		{
+			name: "not installed object is critical",
+			row:  []string{"Deployment", "runner", StatusNotInstalled},
+			want: []Finding{{
+				Severity:    SeverityCritical,
+				Check:       "status",
+				Message:     "Deployment runner is Not Installed",
+				Remediation: "Run `venonactl upgrade` or reinstall the component",
+			}},
+		},
+		{
+			name: "unhealthy object is critical",
+			row:  []string{"Deployment", "runner", StatusUnhealthy, "0/1 replicas available"},
+			want: []Finding{{
+				Severity:    SeverityCritical,
+				Check:       "status",
+				Message:     "Deployment runner is Unhealthy: 0/1 replicas available",
+				Remediation: "Check the events and logs of the pods of the deployment",
+			}},
+		},
+		{
+			name: "progressing object is a warning",
+			row:  []string{"DaemonSet", "dind-lv-monitor", StatusProgressing},
+			want: []Finding{{
+				Severity:    SeverityWarning,
+				Check:       "status",
+				Message:     "DaemonSet dind-lv-monitor is Progressing",
+				Remediation: "Wait for the rollout to finish, check the events if it does not",
+			}},
+		},
+		{
+			name: "stale object is a warning",
+			row:  []string{"ConfigMap", "old", StatusStale},
+			want: []Finding{{
+				Severity:    SeverityWarning,
+				Check:       "status",
+				Message:     "ConfigMap old is Stale",
+				Remediation: "Run `venonactl upgrade` to prune the object",
+			}},
+		},
+		{
+			name: "healthy certificate that expires soon is a warning",
+			row:  []string{"Secret", "codefresh-certs-server", StatusHealthy, "expires in 10 days"},
+			want: []Finding{{
+				Severity:    SeverityWarning,
+				Check:       "status",
+				Message:     "Secret codefresh-certs-server is Healthy: expires in 10 days",
+				Remediation: "Run `venonactl upgrade` to renew the certificates",
+			}},
+		},
+		{
+			name: "healthy pod that restarted is a warning",
+			row:  []string{"Pod", "runner-1", StatusHealthy, "restarted 3 times"},
+			want: []Finding{{
+				Severity:    SeverityWarning,
+				Check:       "status",
+				Message:     "Pod runner-1 is Healthy: restarted 3 times",
+				Remediation: "Check the logs of the pod, the agent is not serving /health",
+			}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := StatusFindings([][]string{tt.row}); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("StatusFindings() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortFindings(t *testing.T) {
+	tests := []struct {
+		name     string
+		findings []Finding
+		want     []string
+	}{
+		{
+			name: "empty",
+			want: []string{},
+		},
+		{
+			name: "by severity, keeping the order of the checks",
+			findings: []Finding{
+				{Severity: SeverityInfo, Check: "info 1"},
+				{Severity: SeverityWarning, Check: "warning 1"},
+				{Severity: SeverityCritical, Check: "critical 1"},
+				{Severity: SeverityInfo, Check: "info 2"},
+				{Severity: SeverityCritical, Check: "critical 2"},
+				{Severity: SeverityWarning, Check: "warning 2"},
+			},
+			want: []string{"critical 1", "critical 2", "warning 1", "warning 2", "info 1", "info 2"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SortFindings(tt.findings)
+			got := []string{}
+			for _, f := range tt.findings {
+				got = append(got, f.Check)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("SortFindings() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
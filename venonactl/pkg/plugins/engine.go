@@ -45,30 +45,26 @@ func (u *enginePlugin) Install(ctx context.Context, opt *InstallOptions, v Value
 		u.logger.Error(fmt.Sprintf("Cannot ensure namespace exists: %v", err))
 		return nil, err
 	}
-	return v, install(ctx, &installOptions{
+	return v, apply(ctx, &applyOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.KubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   engineFilesPattern,
 		dryRun:         opt.DryRun,
+		dryRunObjects:  opt.DryRunObjects,
 		operatorType:   EnginePluginType,
 	})
 }
 
 // Status of runtimectl environment
 func (u *enginePlugin) Status(ctx context.Context, statusOpt *StatusOptions, v Values) ([][]string, error) {
-	cs, err := statusOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil, err
-	}
 	opt := &statusOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    statusOpt.KubeBuilder,
 		namespace:      statusOpt.ClusterNamespace,
 		matchPattern:   engineFilesPattern,
 		operatorType:   EnginePluginType,
@@ -77,16 +73,11 @@ func (u *enginePlugin) Status(ctx context.Context, statusOpt *StatusOptions, v V
 }
 
 func (u *enginePlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v Values) error {
-	cs, err := deleteOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil
-	}
 	opt := &deleteOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    deleteOpt.KubeBuilder,
 		namespace:      deleteOpt.ClusterNamespace,
 		matchPattern:   engineFilesPattern,
 		operatorType:   EnginePluginType,
@@ -95,7 +86,15 @@ func (u *enginePlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v V
 }
 
 func (u *enginePlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Values) (Values, error) {
-	return v, nil
+	return v, apply(ctx, &applyOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   engineFilesPattern,
+		operatorType:   EnginePluginType,
+	})
 }
 
 func (u *enginePlugin) Migrate(context.Context, *MigrateOptions, Values) error {
@@ -106,6 +105,28 @@ func (u *enginePlugin) Test(ctx context.Context, opt *TestOptions, v Values) err
 	return nil
 }
 
+func (u *enginePlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	return diff(ctx, &diffOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   engineFilesPattern,
+		operatorType:   EnginePluginType,
+	})
+}
+
+func (u *enginePlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return rollback(ctx, &rollbackOptions{
+		logger:       u.logger,
+		kubeBuilder:  opt.KubeBuilder,
+		namespace:    opt.ClusterNamespace,
+		revision:     opt.Revision,
+		operatorType: EnginePluginType,
+	})
+}
+
 func (u *enginePlugin) Name() string {
 	return EnginePluginType
 }
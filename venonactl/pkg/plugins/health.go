@@ -0,0 +1,312 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// restartsThreshold - restarts of a container that are reported even when the pod is running
+	restartsThreshold = 5
+	// certificateExpiryWarning - certificates that expire sooner are reported
+	certificateExpiryWarning = 30 * 24 * time.Hour
+	// venonaHealthPort - port of the /health endpoint of venona
+	venonaHealthPort = "8080"
+)
+
+// failingReasons - waiting reasons of a container that will not recover by themselves
+var failingReasons = map[string]bool{
+	"CrashLoopBackOff":           true,
+	"ImagePullBackOff":           true,
+	"ErrImagePull":               true,
+	"InvalidImageName":           true,
+	"CreateContainerConfigError": true,
+	"CreateContainerError":       true,
+}
+
+// IsHealthy - returns false for the statuses that make the installation unhealthy
+func IsHealthy(status string) bool {
+	return status != StatusNotInstalled && status != StatusUnhealthy
+}
+
+// health - the status of a live object and the reasons for it
+type health struct {
+	status   string
+	messages []string
+}
+
+func (h *health) degrade(status string, message string) {
+	if statusSeverity[status] > statusSeverity[h.status] {
+		h.status = status
+	}
+	h.messages = append(h.messages, message)
+}
+
+func (h *health) row(kind string, name string) []string {
+	return []string{kind, name, h.status, strings.Join(h.messages, "; ")}
+}
+
+var statusSeverity = map[string]int{
+	StatusInstalled:   0,
+	StatusHealthy:     0,
+	StatusProgressing: 1,
+	StatusUnhealthy:   2,
+}
+
+// objectHealth - evaluates the live object, kinds without a notion of health are reported as installed
+func objectHealth(ctx context.Context, cs kubernetes.Interface, live *unstructured.Unstructured) (*health, error) {
+	switch live.GetKind() {
+	case "Deployment":
+		deployment := &appsv1.Deployment{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, deployment); err != nil {
+			return nil, err
+		}
+		return deploymentHealth(ctx, cs, deployment)
+	case "DaemonSet":
+		daemonSet := &appsv1.DaemonSet{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, daemonSet); err != nil {
+			return nil, err
+		}
+		return daemonSetHealth(ctx, cs, daemonSet)
+	case "Secret":
+		secret := &v1.Secret{}
+		if err := runtime.DefaultUnstructuredConverter.FromUnstructured(live.Object, secret); err != nil {
+			return nil, err
+		}
+		return certificateHealth(secret, time.Now()), nil
+	}
+	return &health{status: StatusInstalled}, nil
+}
+
+func deploymentHealth(ctx context.Context, cs kubernetes.Interface, d *appsv1.Deployment) (*health, error) {
+	h := &health{status: StatusHealthy}
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	if d.Status.ObservedGeneration < d.Generation || d.Status.UpdatedReplicas < replicas {
+		h.degrade(StatusProgressing, fmt.Sprintf("%d of %d replicas updated", d.Status.UpdatedReplicas, replicas))
+	}
+	if d.Status.AvailableReplicas < replicas {
+		h.degrade(StatusUnhealthy, fmt.Sprintf("%d of %d replicas available", d.Status.AvailableReplicas, replicas))
+	}
+	pods, err := selectedPods(ctx, cs, d.Namespace, d.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	podsHealth(h, pods)
+	return h, nil
+}
+
+func daemonSetHealth(ctx context.Context, cs kubernetes.Interface, ds *appsv1.DaemonSet) (*health, error) {
+	h := &health{status: StatusHealthy}
+	desired := ds.Status.DesiredNumberScheduled
+	if ds.Status.ObservedGeneration < ds.Generation || ds.Status.UpdatedNumberScheduled < desired {
+		h.degrade(StatusProgressing, fmt.Sprintf("updated on %d of %d nodes", ds.Status.UpdatedNumberScheduled, desired))
+	}
+	pods, err := selectedPods(ctx, cs, ds.Namespace, ds.Spec.Selector)
+	if err != nil {
+		return nil, err
+	}
+	if ds.Status.NumberReady < desired {
+		h.degrade(StatusUnhealthy, fmt.Sprintf("ready on %d of %d nodes, not ready on: %s", ds.Status.NumberReady, desired, strings.Join(notReadyNodes(pods), ", ")))
+	}
+	podsHealth(h, pods)
+	return h, nil
+}
+
+func selectedPods(ctx context.Context, cs kubernetes.Interface, namespace string, selector *metav1.LabelSelector) ([]v1.Pod, error) {
+	if selector == nil {
+		return nil, nil
+	}
+	list, err := cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: metav1.FormatLabelSelector(selector),
+	})
+	if err != nil {
+		return nil, err
+	}
+	return list.Items, nil
+}
+
+// podsHealth - containers that cannot start make the workload unhealthy, frequent restarts are reported
+func podsHealth(h *health, pods []v1.Pod) {
+	for _, pod := range pods {
+		for _, c := range pod.Status.ContainerStatuses {
+			if c.State.Waiting != nil && failingReasons[c.State.Waiting.Reason] {
+				h.degrade(StatusUnhealthy, fmt.Sprintf("pod %s container %s: %s", pod.Name, c.Name, c.State.Waiting.Reason))
+			} else if c.RestartCount >= restartsThreshold {
+				h.messages = append(h.messages, fmt.Sprintf("pod %s container %s restarted %d times", pod.Name, c.Name, c.RestartCount))
+			}
+		}
+	}
+}
+
+func notReadyNodes(pods []v1.Pod) []string {
+	var nodes []string
+	for _, pod := range pods {
+		if !podReady(pod) {
+			nodes = append(nodes, pod.Spec.NodeName)
+		}
+	}
+	sort.Strings(nodes)
+	return nodes
+}
+
+func podReady(pod v1.Pod) bool {
+	for _, c := range pod.Status.Conditions {
+		if c.Type == v1.PodReady {
+			return c.Status == v1.ConditionTrue
+		}
+	}
+	return false
+}
+
+// certificateHealth - reports the earliest expiry of the PEM certificates of the secret
+func certificateHealth(secret *v1.Secret, now time.Time) *health {
+	h := &health{status: StatusInstalled}
+	var expiry *x509.Certificate
+	for _, data := range secret.Data {
+		for rest := data; ; {
+			var block *pem.Block
+			block, rest = pem.Decode(rest)
+			if block == nil {
+				break
+			}
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				continue
+			}
+			if expiry == nil || cert.NotAfter.Before(expiry.NotAfter) {
+				expiry = cert
+			}
+		}
+	}
+	if expiry == nil {
+		return h
+	}
+	h.status = StatusHealthy
+	left := expiry.NotAfter.Sub(now)
+	switch {
+	case left <= 0:
+		h.degrade(StatusUnhealthy, fmt.Sprintf("certificate %s expired at %s", expiry.Subject.CommonName, expiry.NotAfter.Format(time.RFC3339)))
+	case left < certificateExpiryWarning:
+		h.messages = append(h.messages, fmt.Sprintf("certificate %s expires in %d days", expiry.Subject.CommonName, int(left.Hours()/24)))
+	default:
+		h.messages = append(h.messages, fmt.Sprintf("certificate valid until %s", expiry.NotAfter.Format("2006-01-02")))
+	}
+	return h
+}
+
+// venonaHealth - calls the /health endpoint of every venona pod through the pods proxy of the api server
+func venonaHealth(ctx context.Context, cs kubernetes.Interface, namespace string, appName string) ([][]string, error) {
+	pods, err := cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app=%s", appName),
+	})
+	if err != nil {
+		return nil, err
+	}
+	var rows [][]string
+	for _, pod := range pods.Items {
+		if pod.Status.Phase != v1.PodRunning {
+			rows = append(rows, []string{"Pod", pod.Name, StatusUnhealthy, fmt.Sprintf("pod is %s", pod.Status.Phase)})
+			continue
+		}
+		_, err := cs.CoreV1().Pods(namespace).ProxyGet("http", pod.Name, venonaHealthPort, "/health", nil).DoRaw(ctx)
+		if err != nil {
+			rows = append(rows, []string{"Pod", pod.Name, StatusUnhealthy, fmt.Sprintf("/health failed: %v", err)})
+			continue
+		}
+		rows = append(rows, []string{"Pod", pod.Name, StatusHealthy, "/health ok"})
+	}
+	return rows, nil
+}
+
+// runtimesHealth - compares the runtimes venona is configured with in the runnerconf secret
+// with the runtime that is expected to be attached and the runtimes registered in Codefresh
+func runtimesHealth(conf venonaConf, expected string, registered []string) [][]string {
+	var rows [][]string
+	configured := map[string]bool{}
+	keys := make([]string, 0, len(conf.Runtimes))
+	for k := range conf.Runtimes {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	isRegistered := map[string]bool{}
+	for _, name := range registered {
+		isRegistered[name] = true
+	}
+	for _, k := range keys {
+		rc := conf.Runtimes[k]
+		configured[rc.Name] = true
+		if registered != nil && !isRegistered[rc.Name] {
+			rows = append(rows, []string{"Runtime", rc.Name, StatusUnhealthy, fmt.Sprintf("%s in %s is not registered in Codefresh", k, runtimeSecretName)})
+			continue
+		}
+		rows = append(rows, []string{"Runtime", rc.Name, StatusHealthy, fmt.Sprintf("attached in %s", runtimeSecretName)})
+	}
+	if expected != "" && !configured[expected] {
+		rows = append(rows, []string{"Runtime", expected, StatusUnhealthy, fmt.Sprintf("not attached in %s", runtimeSecretName)})
+	}
+	return rows
+}
+
+// StorageClassStatus - returns the status row of a storage class that is not installed by venonactl
+func StorageClassStatus(ctx context.Context, kubeBuilder interface {
+	BuildConfig() (*rest.Config, error)
+}, name string) ([]string, error) {
+	cs, err := newClientset(kubeBuilder)
+	if err != nil {
+		return nil, err
+	}
+	_, err = cs.StorageV1().StorageClasses().Get(ctx, name, metav1.GetOptions{})
+	if errors.IsNotFound(err) {
+		return []string{"StorageClass", name, StatusNotInstalled, "storage class of the dind volumes does not exist"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return []string{"StorageClass", name, StatusInstalled, ""}, nil
+}
+
+func newClientset(kubeBuilder interface {
+	BuildConfig() (*rest.Config, error)
+}) (kubernetes.Interface, error) {
+	config, err := kubeBuilder.BuildConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubernetes.NewForConfig(config)
+}
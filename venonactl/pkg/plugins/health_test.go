@@ -0,0 +1,154 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	v1 "k8s.io/api/core/v1"
+)
+
+func certificate(t *testing.T, name string, notAfter time.Time) []byte {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    notAfter.Add(-365 * 24 * time.Hour),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+}
+
+func TestCertificateHealth(t *testing.T) {
+	now := time.Date(2021, 3, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		data       map[string][]byte
+		wantStatus string
+		wantMsg    string
+	}{
+		{
+			name:       "should report an expired certificate as unhealthy",
+			data:       map[string][]byte{"tls.crt": certificate(t, "venona", now.Add(-time.Hour))},
+			wantStatus: StatusUnhealthy,
+			wantMsg:    "certificate venona expired at 2021-02-28T23:00:00Z",
+		},
+		{
+			name:       "should warn about a certificate that expires soon",
+			data:       map[string][]byte{"tls.crt": certificate(t, "venona", now.Add(10*24*time.Hour))},
+			wantStatus: StatusHealthy,
+			wantMsg:    "certificate venona expires in 10 days",
+		},
+		{
+			name:       "should report the expiry of a valid certificate",
+			data:       map[string][]byte{"tls.crt": certificate(t, "venona", now.Add(90*24*time.Hour))},
+			wantStatus: StatusHealthy,
+			wantMsg:    "certificate valid until 2021-05-30",
+		},
+		{
+			name: "should report the earliest expiry of the certificates",
+			data: map[string][]byte{
+				"ca.pem":  certificate(t, "ca", now.Add(90*24*time.Hour)),
+				"tls.crt": certificate(t, "venona", now.Add(-time.Hour)),
+			},
+			wantStatus: StatusUnhealthy,
+			wantMsg:    "certificate venona expired",
+		},
+		{
+			name:       "should report a secret without certificates as installed",
+			data:       map[string][]byte{"token": []byte("secret")},
+			wantStatus: StatusInstalled,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := certificateHealth(&v1.Secret{Data: tt.data}, now)
+			if h.status != tt.wantStatus {
+				t.Errorf("certificateHealth() status = %s, want %s", h.status, tt.wantStatus)
+			}
+			msg := strings.Join(h.messages, "; ")
+			if tt.wantMsg == "" && msg != "" || !strings.Contains(msg, tt.wantMsg) {
+				t.Errorf("certificateHealth() messages = %q, want %q", msg, tt.wantMsg)
+			}
+		})
+	}
+}
+
+func TestRuntimesHealth(t *testing.T) {
+	conf := venonaConf{Runtimes: map[string]RuntimeConfiguration{
+		"b.runtime.yaml": {Name: "account/b"},
+		"a.runtime.yaml": {Name: "account/a"},
+	}}
+	tests := []struct {
+		name       string
+		expected   string
+		registered []string
+		want       [][]string
+	}{
+		{
+			name:       "should report attached runtimes as healthy",
+			expected:   "account/a",
+			registered: []string{"account/a", "account/b"},
+			want: [][]string{
+				{"Runtime", "account/a", StatusHealthy, "attached in runnerconf"},
+				{"Runtime", "account/b", StatusHealthy, "attached in runnerconf"},
+			},
+		},
+		{
+			name:       "should report a runtime that is not registered in Codefresh",
+			expected:   "account/a",
+			registered: []string{"account/a"},
+			want: [][]string{
+				{"Runtime", "account/a", StatusHealthy, "attached in runnerconf"},
+				{"Runtime", "account/b", StatusUnhealthy, "b.runtime.yaml in runnerconf is not registered in Codefresh"},
+			},
+		},
+		{
+			name:     "should report a missing runtime",
+			expected: "account/c",
+			want: [][]string{
+				{"Runtime", "account/a", StatusHealthy, "attached in runnerconf"},
+				{"Runtime", "account/b", StatusHealthy, "attached in runnerconf"},
+				{"Runtime", "account/c", StatusUnhealthy, "not attached in runnerconf"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := runtimesHealth(conf, tt.expected, tt.registered); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("runtimesHealth() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
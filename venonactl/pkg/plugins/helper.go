@@ -39,7 +39,10 @@ import (
 	"github.com/Masterminds/semver"
 	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	utilyaml "k8s.io/apimachinery/pkg/util/yaml"
 	"k8s.io/apimachinery/pkg/version"
 	"k8s.io/client-go/kubernetes/scheme"
 )
@@ -195,6 +198,10 @@ func KubeObjectsFromTemplates(templatesMap map[string]string, data interface{},
 	for n, objStr := range parsedTemplates {
 		logger.Debug(fmt.Sprintf("Deserializing template %s %s", n, objStr))
 		obj, groupVersionKind, err := kubeDecode([]byte(objStr), nil, nil)
+		if runtime.IsNotRegisteredError(err) {
+			// kinds unknown to the scheme, as custom resources, are kept unstructured
+			obj, groupVersionKind, err = decodeUnstructured(objStr)
+		}
 		if err != nil {
 			logger.Error(fmt.Sprintf("Cannot deserialize kuberentes object %s: %v", n, err))
 			return nil, err
@@ -205,6 +212,14 @@ func KubeObjectsFromTemplates(templatesMap map[string]string, data interface{},
 	return kubeObjects, nil
 }
 
+func decodeUnstructured(objStr string) (runtime.Object, *schema.GroupVersionKind, error) {
+	data, err := utilyaml.ToJSON([]byte(objStr))
+	if err != nil {
+		return nil, nil, err
+	}
+	return unstructured.UnstructuredJSONScheme.Decode(data, nil, nil)
+}
+
 func getKubeObjectsFromTempalte(values map[string]interface{}, pattern string, logger logger.Logger) (map[string]runtime.Object, error) {
 	templatesMap := templates.TemplatesMap()
 	return KubeObjectsFromTemplates(templatesMap, values, pattern, logger)
@@ -281,12 +296,21 @@ func ensureClusterRequirements(ctx context.Context, client *kubernetes.Clientset
 	return result, nil
 }
 
+// ValidationError - returned by Test when the cluster does not meet the requirements of the plugin
+type ValidationError struct {
+	Messages []string
+}
+
+func (e *ValidationError) Error() string {
+	return "Failed to run acceptance test on cluster"
+}
+
 func handleValidationResult(res validationResult, logger logger.Logger) error {
 	if !res.isValid {
 		for _, m := range res.message {
 			logger.Error(m)
 		}
-		return errors.New("Failed to run acceptance test on cluster")
+		return &ValidationError{Messages: res.message}
 	}
 
 	for _, m := range res.message {
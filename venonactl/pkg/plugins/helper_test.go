@@ -0,0 +1,78 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"testing"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+func TestKubeObjectsFromTemplates(t *testing.T) {
+	tests := []struct {
+		name     string
+		template string
+		want     schema.GroupVersionKind
+		typed    bool
+		wantErr  bool
+	}{
+		{
+			name:     "should decode known kinds typed",
+			template: "apiVersion: v1\nkind: ConfigMap\nmetadata:\n  name: {{ .Name }}\n",
+			want:     schema.GroupVersionKind{Version: "v1", Kind: "ConfigMap"},
+			typed:    true,
+		},
+		{
+			name:     "should decode custom resources unstructured",
+			template: "apiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: {{ .Name }}\nspec:\n  endpoints:\n  - port: metrics\n",
+			want:     schema.GroupVersionKind{Group: "monitoring.coreos.com", Version: "v1", Kind: "ServiceMonitor"},
+		},
+		{
+			name:     "should fail on an object without kind",
+			template: "apiVersion: v1\nmetadata:\n  name: {{ .Name }}\n",
+			wantErr:  true,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lgr := logger.New(&logger.Options{Command: "test"})
+			got, err := KubeObjectsFromTemplates(map[string]string{"obj.yaml": tt.template}, map[string]interface{}{"Name": "venona"}, ".*", lgr)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("KubeObjectsFromTemplates() error = nil, want an error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("KubeObjectsFromTemplates() error = %v", err)
+			}
+			obj := got["obj.yaml"]
+			if gvk := obj.GetObjectKind().GroupVersionKind(); gvk != tt.want {
+				t.Errorf("KubeObjectsFromTemplates() kind = %v, want %v", gvk, tt.want)
+			}
+			u, unstructuredObj := obj.(*unstructured.Unstructured)
+			if unstructuredObj == tt.typed {
+				t.Errorf("KubeObjectsFromTemplates() = %T, want typed %v", obj, tt.typed)
+			}
+			if unstructuredObj && u.GetName() != "venona" {
+				t.Errorf("KubeObjectsFromTemplates() name = %s, want venona", u.GetName())
+			}
+		})
+	}
+}
@@ -0,0 +1,230 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
+	v1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	// LabelManagedBy - set on every object venonactl applies
+	LabelManagedBy = "app.kubernetes.io/managed-by"
+	// LabelInstance - namespace of the installation the object belongs to
+	LabelInstance = "app.kubernetes.io/instance"
+	// LabelComponent - plugin that installed the object
+	LabelComponent = "app.kubernetes.io/component"
+
+	managedBy        = "venonactl"
+	inventoryPrefix  = "venonactl-inventory-"
+	inventoryObjects = "objects"
+	inventoryValues  = "values"
+)
+
+// inventory - the objects and values of the last apply of a plugin, kept in a secret
+// in the namespace of the installation as the values may hold tokens
+type inventory struct {
+	Objects []kubeobj.ObjectRef
+	Values  map[string]interface{}
+}
+
+// contains - returns true when the ref is one of the objects of the inventory
+func (i *inventory) contains(ref kubeobj.ObjectRef) bool {
+	for _, o := range i.Objects {
+		if o.Same(ref) {
+			return true
+		}
+	}
+	return false
+}
+
+func inventoryName(component string) string {
+	return inventoryPrefix + component
+}
+
+func managedLabels(namespace, component string) map[string]string {
+	return map[string]string{
+		LabelManagedBy: managedBy,
+		LabelInstance:  namespace,
+		LabelComponent: component,
+	}
+}
+
+// stampLabels - adds the ownership labels to the object
+func stampLabels(obj runtime.Object, namespace, component string) error {
+	accessor, err := meta.Accessor(obj)
+	if err != nil {
+		return err
+	}
+	labels := accessor.GetLabels()
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	for k, v := range managedLabels(namespace, component) {
+		labels[k] = v
+	}
+	accessor.SetLabels(labels)
+	return nil
+}
+
+// readInventory - returns the inventory of the component, nil when it was never recorded
+func readInventory(ctx context.Context, d *kubeobj.Dynamic, namespace, component string) (*inventory, error) {
+	u, err := d.Get(ctx, inventorySecret(namespace, component, nil), namespace)
+	if err != nil || u == nil {
+		return nil, err
+	}
+	secret := &v1.Secret{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, secret); err != nil {
+		return nil, err
+	}
+	inv := &inventory{}
+	if err := json.Unmarshal(secret.Data[inventoryObjects], &inv.Objects); err != nil {
+		return nil, fmt.Errorf("Cannot read inventory %s: %v", secret.Name, err)
+	}
+	if data, ok := secret.Data[inventoryValues]; ok {
+		if err := json.Unmarshal(data, &inv.Values); err != nil {
+			return nil, fmt.Errorf("Cannot read inventory %s: %v", secret.Name, err)
+		}
+	}
+	return inv, nil
+}
+
+// IsInstalled - returns true when the component recorded an inventory in the namespace
+func IsInstalled(ctx context.Context, kubeBuilder interface {
+	BuildConfig() (*rest.Config, error)
+}, namespace, component string) (bool, error) {
+	d, err := newDynamic(kubeBuilder)
+	if err != nil {
+		return false, err
+	}
+	inv, err := readInventory(ctx, d, namespace, component)
+	if err != nil {
+		return false, err
+	}
+	return inv != nil, nil
+}
+
+// writeInventory - records the inventory of the component
+func writeInventory(ctx context.Context, d *kubeobj.Dynamic, namespace, component string, inv *inventory) error {
+	objects, err := json.Marshal(inv.Objects)
+	if err != nil {
+		return err
+	}
+	values, err := json.Marshal(inv.Values)
+	if err != nil {
+		return err
+	}
+	_, err = d.Apply(ctx, inventorySecret(namespace, component, map[string][]byte{
+		inventoryObjects: objects,
+		inventoryValues:  values,
+	}), namespace, false)
+	return err
+}
+
+func deleteInventory(ctx context.Context, d *kubeobj.Dynamic, namespace, component string) error {
+	_, _, err := kubeobj.DeleteObject(ctx, d, inventorySecret(namespace, component, nil), namespace)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+func inventorySecret(namespace, component string, data map[string][]byte) *v1.Secret {
+	return &v1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      inventoryName(component),
+			Namespace: namespace,
+			Labels:    managedLabels(namespace, component),
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+// prune - deletes the objects of the previous inventory that are no longer rendered.
+// Objects that lost the managed-by label were taken over by someone else and are kept
+func prune(ctx context.Context, d *kubeobj.Dynamic, prev *inventory, current *inventory, logger logger.Logger) error {
+	if prev == nil {
+		return nil
+	}
+	for _, ref := range prev.Objects {
+		if current.contains(ref) {
+			continue
+		}
+		live, err := d.GetRef(ctx, ref)
+		if kubeobj.IsNotServed(err) {
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		if live == nil || live.GetLabels()[LabelManagedBy] != managedBy {
+			continue
+		}
+		err = d.DeleteRef(ctx, ref)
+		if err != nil && !errors.IsNotFound(err) {
+			logger.Error(fmt.Sprintf("%s \"%s\" failed: %v ", ref.Kind, ref.Name, err))
+			return err
+		}
+		logger.Debug(fmt.Sprintf("%s \"%s\" pruned", ref.Kind, ref.Name))
+	}
+	return nil
+}
+
+// staleObjects - returns the objects of the inventory that still exist but are not rendered anymore
+func staleObjects(ctx context.Context, d *kubeobj.Dynamic, kubeObjects map[string]runtime.Object, namespace, component string) ([]kubeobj.ObjectRef, error) {
+	prev, err := readInventory(ctx, d, namespace, component)
+	if err != nil || prev == nil {
+		return nil, err
+	}
+	current := &inventory{}
+	for _, obj := range kubeObjects {
+		ref, err := d.Ref(obj, namespace)
+		if err != nil {
+			continue
+		}
+		current.Objects = append(current.Objects, ref)
+	}
+	res := []kubeobj.ObjectRef{}
+	for _, ref := range prev.Objects {
+		if current.contains(ref) {
+			continue
+		}
+		live, err := d.GetRef(ctx, ref)
+		if kubeobj.IsNotServed(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if live != nil {
+			res = append(res, ref)
+		}
+	}
+	return res, nil
+}
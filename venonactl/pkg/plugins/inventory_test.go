@@ -0,0 +1,94 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"testing"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj/kubeobjtest"
+	v1 "k8s.io/api/core/v1"
+)
+
+func TestPrune(t *testing.T) {
+	unmanaged := configMap("b", "v")
+	unmanaged.Labels = map[string]string{LabelManagedBy: "helm"}
+	unlabeled := configMap("b", "v")
+	unlabeled.Labels = nil
+	tests := []struct {
+		name     string
+		existing []*v1.ConfigMap
+		prev     *inventory
+		current  *inventory
+		// wantExists - whether each config map exists after the prune
+		wantExists map[string]bool
+	}{
+		{
+			name:       "object missing from the new inventory is deleted",
+			existing:   []*v1.ConfigMap{configMap("a", "v"), configMap("b", "v")},
+			prev:       &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a"), configMapRef("b")}},
+			current:    &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a")}},
+			wantExists: map[string]bool{"a": true, "b": false},
+		},
+		{
+			name:       "object managed by someone else is skipped",
+			existing:   []*v1.ConfigMap{configMap("a", "v"), unmanaged},
+			prev:       &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a"), configMapRef("b")}},
+			current:    &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a")}},
+			wantExists: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name:       "object without the managed-by label is skipped",
+			existing:   []*v1.ConfigMap{configMap("a", "v"), unlabeled},
+			prev:       &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a"), configMapRef("b")}},
+			current:    &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a")}},
+			wantExists: map[string]bool{"a": true, "b": true},
+		},
+		{
+			name:       "object already deleted is ignored",
+			existing:   []*v1.ConfigMap{configMap("a", "v")},
+			prev:       &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a"), configMapRef("b")}},
+			current:    &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a")}},
+			wantExists: map[string]bool{"a": true, "b": false},
+		},
+		{
+			name:       "nothing is deleted without a previous inventory",
+			existing:   []*v1.ConfigMap{configMap("a", "v"), configMap("b", "v")},
+			current:    &inventory{Objects: []kubeobj.ObjectRef{configMapRef("a")}},
+			wantExists: map[string]bool{"a": true, "b": true},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			objects := []interface{}{}
+			for _, obj := range tt.existing {
+				objects = append(objects, obj)
+			}
+			srv, d := testDynamic(t, objects...)
+			if err := prune(context.Background(), d, tt.prev, tt.current, logger.New(&logger.Options{})); err != nil {
+				t.Fatalf("prune() error = %v", err)
+			}
+			for name, want := range tt.wantExists {
+				if got := srv.Get(kubeobjtest.Path("v1", "configmaps", testNamespace, name)) != nil; got != want {
+					t.Errorf("config map %s exists = %v, want %v", name, got, want)
+				}
+			}
+		})
+	}
+}
@@ -46,14 +46,15 @@ func (u *monitorAgentPlugin) Install(ctx context.Context, opt *InstallOptions, v
 		u.logger.Error(fmt.Sprintf("Cannot ensure namespace exists: %v", err))
 		return nil, err
 	}
-	return v, install(ctx, &installOptions{
+	return v, apply(ctx, &applyOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.KubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   monitorFilesPattern,
 		dryRun:         opt.DryRun,
+		dryRunObjects:  opt.DryRunObjects,
 		operatorType:   MonitorAgentPluginType,
 	})
 }
@@ -63,15 +64,10 @@ func (u *monitorAgentPlugin) Status(ctx context.Context, statusOpt *StatusOption
 }
 
 func (u *monitorAgentPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v Values) error {
-	cs, err := deleteOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return err
-	}
 	opt := &deleteOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    deleteOpt.KubeBuilder,
 		namespace:      deleteOpt.ClusterNamespace,
 		matchPattern:   monitorFilesPattern,
 		operatorType:   MonitorAgentPluginType,
@@ -81,7 +77,15 @@ func (u *monitorAgentPlugin) Delete(ctx context.Context, deleteOpt *DeleteOption
 }
 
 func (u *monitorAgentPlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Values) (Values, error) {
-	return nil, nil
+	return v, apply(ctx, &applyOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   monitorFilesPattern,
+		operatorType:   MonitorAgentPluginType,
+	})
 }
 func (u *monitorAgentPlugin) Migrate(context.Context, *MigrateOptions, Values) error {
 	return fmt.Errorf("not supported")
@@ -122,6 +126,28 @@ func (u *monitorAgentPlugin) Test(ctx context.Context, opt *TestOptions, v Value
 	})
 }
 
+func (u *monitorAgentPlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	return diff(ctx, &diffOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   monitorFilesPattern,
+		operatorType:   MonitorAgentPluginType,
+	})
+}
+
+func (u *monitorAgentPlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return rollback(ctx, &rollbackOptions{
+		logger:       u.logger,
+		kubeBuilder:  opt.KubeBuilder,
+		namespace:    opt.ClusterNamespace,
+		revision:     opt.Revision,
+		operatorType: MonitorAgentPluginType,
+	})
+}
+
 func (u *monitorAgentPlugin) Name() string {
 	return MonitorAgentPluginType
 }
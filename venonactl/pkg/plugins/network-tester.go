@@ -138,11 +138,11 @@ func (u *networkTesterPlugin) Test(ctx context.Context, opt *TestOptions, v Valu
 	objx.New(v["NetworkTester"]).Set("AdditionalEnvVars.URLS", urls)
 	objx.New(v["NetworkTester"]).Set("AdditionalEnvVars.KUBERNETES_HOST", getKubeHost(v, conf.Host))
 
-	err = install(ctx, &installOptions{
+	err = apply(ctx, &applyOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.KubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   networkTesterFilesPattern,
 		operatorType:   NetworkTesterPluginType,
@@ -156,7 +156,7 @@ func (u *networkTesterPlugin) Test(ctx context.Context, opt *TestOptions, v Valu
 		err := uninstall(ctx, &deleteOptions{
 			templates:      templates.TemplatesMap(),
 			templateValues: v,
-			kubeClientSet:  cs,
+			kubeBuilder:    opt.KubeBuilder,
 			namespace:      opt.ClusterNamespace,
 			matchPattern:   networkTesterFilesPattern,
 			operatorType:   NetworkTesterPluginType,
@@ -233,6 +233,15 @@ Loop:
 	return nil
 }
 
+// Diff is not supported, the network tester pod only exists while testing
+func (u *networkTesterPlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	return []ObjectDiff{}, nil
+}
+
+func (u *networkTesterPlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return v, fmt.Errorf("not supported")
+}
+
 func (u *networkTesterPlugin) Name() string {
 	return NetworkTesterPluginType
 }
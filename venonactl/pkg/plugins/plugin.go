@@ -5,11 +5,14 @@ import (
 	"fmt"
 	"io/ioutil"
 	"os"
+	"sort"
 
 	"github.com/codefresh-io/venona/venonactl/pkg/logger"
 	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/rest"
 )
@@ -24,6 +27,10 @@ const (
 	RuntimeAttachType             = "runtime-attach"
 	AppProxyPluginType            = "app-proxy"
 	NetworkTesterPluginType       = "network-tester"
+
+	// RuntimeEnvironmentRegisteredValue - set by the runtime-environment plugin when it registered
+	// the runtime-environment in Codefresh, only then a failed installation may unregister it
+	RuntimeEnvironmentRegisteredValue = "RuntimeEnvironmentRegistered"
 )
 
 type (
@@ -34,6 +41,8 @@ type (
 		Upgrade(context.Context, *UpgradeOptions, Values) (Values, error)
 		Migrate(context.Context, *MigrateOptions, Values) error
 		Test(context.Context, *TestOptions, Values) error
+		Diff(context.Context, *DiffOptions, Values) ([]ObjectDiff, error)
+		Rollback(context.Context, *RollbackOptions, Values) (Values, error)
 		Name() string
 	}
 
@@ -58,6 +67,7 @@ type (
 		CodefreshToken        string
 		ClusterName           string
 		ClusterNamespace      string
+		AgentNamespace        string
 		ClusterHost           string
 		RegisterWithAgent     bool
 		MarkAsDefault         bool
@@ -71,9 +81,13 @@ type (
 		}
 		AgentKubeBuilder interface {
 			BuildClient() (*kubernetes.Clientset, error)
+			BuildConfig() (*rest.Config, error)
 			EnsureNamespaceExists(ctx context.Context, cs *kubernetes.Clientset) error
 		}
-		DryRun                bool
+		DryRun bool
+		// DryRunObjects - when set together with DryRun the rendered objects are collected in it
+		// instead of being written to ./codefresh_manifests
+		DryRunObjects         *[]map[string]interface{}
 		BuildNodeSelector     map[string]string
 		Annotations           map[string]string
 		RuntimeEnvironment    string
@@ -86,9 +100,11 @@ type (
 	DeleteOptions struct {
 		KubeBuilder interface {
 			BuildClient() (*kubernetes.Clientset, error)
+			BuildConfig() (*rest.Config, error)
 		}
 		AgentKubeBuilder interface {
 			BuildClient() (*kubernetes.Clientset, error)
+			BuildConfig() (*rest.Config, error)
 		}
 		ClusterNamespace   string // runtime
 		AgentNamespace     string // agent
@@ -97,12 +113,29 @@ type (
 	}
 
 	UpgradeOptions struct {
-		ClusterName      string
-		ClusterNamespace string
-		Name             string
-		KubeBuilder      interface {
+		ClusterName        string
+		ClusterNamespace   string
+		Name               string
+		RuntimeEnvironment string
+		// Declared - values set explicitly by the caller, they are kept over the ones of the previous deployment
+		Declared    map[string]interface{}
+		KubeBuilder interface {
 			BuildClient() (*kubernetes.Clientset, error)
+			BuildConfig() (*rest.Config, error)
+		}
+	}
+
+	RollbackOptions struct {
+		KubeBuilder interface {
+			BuildConfig() (*rest.Config, error)
+		}
+		AgentKubeBuilder interface {
+			BuildConfig() (*rest.Config, error)
 		}
+		ClusterNamespace string // runtime
+		AgentNamespace   string // agent
+		// Revision to restore, 0 is the one before the last
+		Revision int
 	}
 
 	MigrateOptions struct {
@@ -110,6 +143,7 @@ type (
 		ClusterNamespace string
 		KubeBuilder      interface {
 			BuildClient() (*kubernetes.Clientset, error)
+			BuildConfig() (*rest.Config, error)
 		}
 	}
 
@@ -125,20 +159,35 @@ type (
 	StatusOptions struct {
 		KubeBuilder interface {
 			BuildClient() (*kubernetes.Clientset, error)
+			BuildConfig() (*rest.Config, error)
 		}
 		ClusterNamespace string
+		// RuntimeEnvironment - expected to be attached to the agent
+		RuntimeEnvironment string
+		// RegisteredRuntimes - runtimes of the agent registered in Codefresh, nil when unknown
+		RegisteredRuntimes []string
 	}
 
-	installOptions struct {
+	DiffOptions struct {
+		KubeBuilder interface {
+			BuildConfig() (*rest.Config, error)
+		}
+		ClusterNamespace   string
+		RuntimeEnvironment string
+	}
+
+	applyOptions struct {
 		templates      map[string]string
 		templateValues map[string]interface{}
-		kubeClientSet  *kubernetes.Clientset
 		namespace      string
 		matchPattern   string
 		operatorType   string
 		dryRun         bool
-		kubeBuilder    interface {
-			BuildClient() (*kubernetes.Clientset, error)
+		dryRunObjects  *[]map[string]interface{}
+		// skip - names of the templates that are not applied
+		skip        map[string]bool
+		kubeBuilder interface {
+			BuildConfig() (*rest.Config, error)
 		}
 		logger logger.Logger
 	}
@@ -146,21 +195,50 @@ type (
 	statusOptions struct {
 		templates      map[string]string
 		templateValues map[string]interface{}
-		kubeClientSet  *kubernetes.Clientset
 		namespace      string
 		matchPattern   string
 		operatorType   string
-		logger         logger.Logger
+		kubeBuilder    interface {
+			BuildConfig() (*rest.Config, error)
+		}
+		logger logger.Logger
 	}
 
 	deleteOptions struct {
 		templates      map[string]string
 		templateValues map[string]interface{}
-		kubeClientSet  *kubernetes.Clientset
 		namespace      string
 		matchPattern   string
 		operatorType   string
-		logger         logger.Logger
+		// keepInventory - set when only some of the templates of the plugin are deleted,
+		// otherwise the rest of the objects in the inventory are deleted as well
+		keepInventory bool
+		kubeBuilder   interface {
+			BuildConfig() (*rest.Config, error)
+		}
+		logger logger.Logger
+	}
+
+	rollbackOptions struct {
+		namespace    string
+		operatorType string
+		revision     int
+		kubeBuilder  interface {
+			BuildConfig() (*rest.Config, error)
+		}
+		logger logger.Logger
+	}
+
+	diffOptions struct {
+		templates      map[string]string
+		templateValues map[string]interface{}
+		namespace      string
+		matchPattern   string
+		operatorType   string
+		kubeBuilder    interface {
+			BuildConfig() (*rest.Config, error)
+		}
+		logger logger.Logger
 	}
 
 	testOptions struct {
@@ -241,8 +319,15 @@ func build(t string, logger logger.Logger) Plugin {
 	return nil
 }
 
-func install(ctx context.Context, opt *installOptions) error {
+// apply server-side applies the objects of the templates with the venonactl field manager,
+// objects that already exist are updated to match the templates. The applied objects are recorded
+// in the inventory of the plugin, objects of the previous inventory that are not rendered anymore are pruned.
+// When any of it fails the objects are restored to their previous state
+func apply(ctx context.Context, opt *applyOptions) error {
 
+	if opt.dryRun == true && opt.dryRunObjects != nil {
+		return collectObjects(opt)
+	}
 	if opt.dryRun == true {
 		err := os.Mkdir("codefresh_manifests", 0755)
 		if err != nil {
@@ -262,28 +347,70 @@ func install(ctx context.Context, opt *installOptions) error {
 	if err != nil {
 		return err
 	}
+	d, err := newDynamic(opt.kubeBuilder)
+	if err != nil {
+		return err
+	}
 
-	for _, obj := range kubeObjects {
+	names := make([]string, 0, len(kubeObjects))
+	for n := range kubeObjects {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	rel := &release{
+		namespace: opt.namespace,
+		component: opt.operatorType,
+		values:    opt.templateValues,
+	}
+	for _, n := range names {
+		obj := kubeObjects[n]
+		if err := stampLabels(obj, opt.namespace, opt.operatorType); err != nil {
+			return err
+		}
+		ref, err := d.Ref(obj, opt.namespace)
+		if err != nil {
+			opt.logger.Debug(fmt.Sprintf("%s failed: %v ", n, err))
+			return err
+		}
+		// skipped objects are still part of the installation, they must not be pruned
+		rel.refs = append(rel.refs, ref)
+		if opt.skip[n] {
+			opt.logger.Debug(fmt.Sprintf("Skipping apply of %s", n))
+			continue
+		}
+		rel.objects = append(rel.objects, obj)
+	}
+	return commit(ctx, d, rel, opt.logger)
+}
 
-		var createErr error
-		var kind, name string
-		name, kind, createErr = kubeobj.CreateObject(ctx, opt.kubeClientSet, obj, opt.namespace)
+func newDynamic(kubeBuilder interface {
+	BuildConfig() (*rest.Config, error)
+}) (*kubeobj.Dynamic, error) {
+	config, err := kubeBuilder.BuildConfig()
+	if err != nil {
+		return nil, err
+	}
+	return kubeobj.NewDynamic(config)
+}
 
-		if createErr == nil {
-			opt.logger.Debug(fmt.Sprintf("%s \"%s\" created", kind, name))
-		} else if statusError, errIsStatusError := createErr.(*errors.StatusError); errIsStatusError {
-			if statusError.ErrStatus.Reason == metav1.StatusReasonAlreadyExists {
-				opt.logger.Debug(fmt.Sprintf("%s \"%s\" already exists", kind, name))
-			} else {
-				opt.logger.Debug(fmt.Sprintf("%s \"%s\" failed: %v ", kind, name, statusError))
-				return statusError
-			}
-		} else {
-			opt.logger.Debug(fmt.Sprintf("%s \"%s\" failed: %v ", kind, name, createErr))
-			return createErr
+// collectObjects - adds the rendered objects to the dry run objects, ordered by template name
+func collectObjects(opt *applyOptions) error {
+	kubeObjects, err := KubeObjectsFromTemplates(opt.templates, opt.templateValues, opt.matchPattern, opt.logger)
+	if err != nil {
+		return err
+	}
+	names := make([]string, 0, len(kubeObjects))
+	for n := range kubeObjects {
+		names = append(names, n)
+	}
+	sort.Strings(names)
+	for _, n := range names {
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(kubeObjects[n])
+		if err != nil {
+			return err
 		}
+		*opt.dryRunObjects = append(*opt.dryRunObjects, u)
 	}
-
 	return nil
 }
 
@@ -292,20 +419,43 @@ func status(ctx context.Context, opt *statusOptions) ([][]string, error) {
 	if err != nil {
 		return nil, err
 	}
+	d, err := newDynamic(opt.kubeBuilder)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := newClientset(opt.kubeBuilder)
+	if err != nil {
+		return nil, err
+	}
 	var getErr error
 	var kind, name string
+	var live *unstructured.Unstructured
 	var rows [][]string
 	for _, obj := range kubeObjects {
-		name, kind, getErr = kubeobj.CheckObject(ctx, opt.kubeClientSet, obj, opt.namespace)
+		live, name, kind, getErr = kubeobj.GetObject(ctx, d, obj, opt.namespace)
 		if getErr == nil {
-			rows = append(rows, []string{kind, name, StatusInstalled})
+			h, err := objectHealth(ctx, cs, live)
+			if err != nil {
+				return nil, err
+			}
+			rows = append(rows, h.row(kind, name))
 		} else if statusError, errIsStatusError := getErr.(*errors.StatusError); errIsStatusError {
 			rows = append(rows, []string{kind, name, StatusNotInstalled, statusError.ErrStatus.Message})
+		} else if kubeobj.IsNotServed(getErr) {
+			rows = append(rows, []string{kind, name, StatusNotInstalled, getErr.Error()})
 		} else {
 			opt.logger.Debug(fmt.Sprintf("%s \"%s\" failed: %v ", kind, name, getErr))
 			return nil, getErr
 		}
 	}
+
+	stale, err := staleObjects(ctx, d, kubeObjects, opt.namespace, opt.operatorType)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range stale {
+		rows = append(rows, []string{ref.Kind, ref.Name, StatusStale, "not rendered by the templates, pruned on the next upgrade"})
+	}
 	return rows, nil
 }
 
@@ -315,10 +465,14 @@ func uninstall(ctx context.Context, opt *deleteOptions) error {
 	if err != nil {
 		return err
 	}
+	d, err := newDynamic(opt.kubeBuilder)
+	if err != nil {
+		return err
+	}
 	var kind, name string
 	var deleteError error
 	for _, obj := range kubeObjects {
-		kind, name, deleteError = kubeobj.DeleteObject(ctx, opt.kubeClientSet, obj, opt.namespace)
+		name, kind, deleteError = kubeobj.DeleteObject(ctx, d, obj, opt.namespace)
 		if deleteError == nil {
 			opt.logger.Debug(fmt.Sprintf("%s \"%s\" deleted", kind, name))
 		} else if statusError, errIsStatusError := deleteError.(*errors.StatusError); errIsStatusError {
@@ -330,12 +484,29 @@ func uninstall(ctx context.Context, opt *deleteOptions) error {
 				opt.logger.Error(fmt.Sprintf("%s \"%s\" failed: %v ", kind, name, statusError))
 				return statusError
 			}
+		} else if kubeobj.IsNotServed(deleteError) {
+			opt.logger.Debug(fmt.Sprintf("%s \"%s\" not found: %v", kind, name, deleteError))
 		} else {
 			opt.logger.Error(fmt.Sprintf("%s \"%s\" failed: %v ", kind, name, deleteError))
 			return deleteError
 		}
 	}
-	return nil
+	if opt.keepInventory {
+		return nil
+	}
+
+	// objects of older templates are known only from the inventory
+	prev, err := readInventory(ctx, d, opt.namespace, opt.operatorType)
+	if err != nil {
+		return err
+	}
+	if err := prune(ctx, d, prev, &inventory{}, opt.logger); err != nil {
+		return err
+	}
+	if err := deleteInventory(ctx, d, opt.namespace, opt.operatorType); err != nil {
+		return err
+	}
+	return deleteHistory(ctx, d, opt.namespace, opt.operatorType)
 }
 
 func test(ctx context.Context, opt testOptions) error {
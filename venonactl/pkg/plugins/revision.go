@@ -0,0 +1,342 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
+	v1 "k8s.io/api/core/v1"
+	kerrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+)
+
+const (
+	historyPrefix = "venonactl-history-"
+	historyKey    = "revisions"
+	// maxRevisions - number of revisions kept per plugin
+	maxRevisions = 5
+)
+
+var (
+	// ErrNoPreviousRevision - returned by Rollback when the plugin has nothing to roll back to
+	ErrNoPreviousRevision = errors.New("no previous revision")
+)
+
+type (
+	// revision - the objects and values of a successful apply of a plugin
+	revision struct {
+		Revision int                    `json:"revision"`
+		Objects  []json.RawMessage      `json:"objects"`
+		Refs     []kubeobj.ObjectRef    `json:"refs"`
+		Values   map[string]interface{} `json:"values"`
+	}
+
+	// history - the last revisions of a plugin, oldest first.
+	// Kept in a secret in the namespace of the installation as the values and objects may hold tokens
+	history struct {
+		Revisions []revision
+	}
+
+	// release - objects to apply as a new revision of a plugin
+	release struct {
+		namespace string
+		component string
+		objects   []runtime.Object
+		// refs - all the objects of the installation, including the ones that are not applied
+		refs   []kubeobj.ObjectRef
+		values map[string]interface{}
+	}
+
+	// snapshot - state of the objects before a release, used to restore them when the release fails
+	snapshot struct {
+		live    []*unstructured.Unstructured
+		missing []kubeobj.ObjectRef
+	}
+)
+
+// get - returns the revision, 0 means the one before the last
+func (h *history) get(n int) (*revision, error) {
+	if n == 0 {
+		if len(h.Revisions) < 2 {
+			return nil, ErrNoPreviousRevision
+		}
+		return &h.Revisions[len(h.Revisions)-2], nil
+	}
+	for i := range h.Revisions {
+		if h.Revisions[i].Revision == n {
+			return &h.Revisions[i], nil
+		}
+	}
+	return nil, fmt.Errorf("revision %d not found", n)
+}
+
+// add - appends the release as the next revision, only the last maxRevisions are kept
+func (h *history) add(rel *release) error {
+	rev := revision{Revision: 1, Refs: rel.refs, Values: rel.values}
+	if len(h.Revisions) > 0 {
+		rev.Revision = h.Revisions[len(h.Revisions)-1].Revision + 1
+	}
+	for _, obj := range rel.objects {
+		data, err := json.Marshal(obj)
+		if err != nil {
+			return err
+		}
+		rev.Objects = append(rev.Objects, data)
+	}
+	h.Revisions = append(h.Revisions, rev)
+	if len(h.Revisions) > maxRevisions {
+		h.Revisions = h.Revisions[len(h.Revisions)-maxRevisions:]
+	}
+	return nil
+}
+
+// release - returns the revision as a release to apply
+func (r *revision) release(namespace, component string) (*release, error) {
+	rel := &release{
+		namespace: namespace,
+		component: component,
+		refs:      r.Refs,
+		values:    r.Values,
+	}
+	for _, data := range r.Objects {
+		u := &unstructured.Unstructured{}
+		if err := u.UnmarshalJSON(data); err != nil {
+			return nil, err
+		}
+		rel.objects = append(rel.objects, u)
+	}
+	return rel, nil
+}
+
+func historySecret(namespace, component string, data map[string][]byte) *v1.Secret {
+	return &v1.Secret{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      historyPrefix + component,
+			Namespace: namespace,
+			Labels:    managedLabels(namespace, component),
+		},
+		Type: v1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+func readHistory(ctx context.Context, d *kubeobj.Dynamic, namespace, component string) (*history, error) {
+	h := &history{}
+	u, err := d.Get(ctx, historySecret(namespace, component, nil), namespace)
+	if err != nil || u == nil {
+		return h, err
+	}
+	secret := &v1.Secret{}
+	if err := runtime.DefaultUnstructuredConverter.FromUnstructured(u.Object, secret); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(secret.Data[historyKey], &h.Revisions); err != nil {
+		return nil, fmt.Errorf("Cannot read revisions %s: %v", secret.Name, err)
+	}
+	return h, nil
+}
+
+func writeHistory(ctx context.Context, d *kubeobj.Dynamic, namespace, component string, h *history) error {
+	data, err := json.Marshal(h.Revisions)
+	if err != nil {
+		return err
+	}
+	_, err = d.Apply(ctx, historySecret(namespace, component, map[string][]byte{historyKey: data}), namespace, false)
+	return err
+}
+
+func deleteHistory(ctx context.Context, d *kubeobj.Dynamic, namespace, component string) error {
+	_, _, err := kubeobj.DeleteObject(ctx, d, historySecret(namespace, component, nil), namespace)
+	if kerrors.IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// takeSnapshot - reads the current state of the objects
+func takeSnapshot(ctx context.Context, d *kubeobj.Dynamic, refs []kubeobj.ObjectRef) (*snapshot, error) {
+	s := &snapshot{}
+	for _, ref := range refs {
+		live, err := d.GetRef(ctx, ref)
+		if kubeobj.IsNotServed(err) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if live == nil {
+			s.missing = append(s.missing, ref)
+			continue
+		}
+		// only what was declared is restored, the server fills the rest again
+		for _, f := range [][]string{
+			{"status"},
+			{"metadata", "managedFields"},
+			{"metadata", "resourceVersion"},
+			{"metadata", "uid"},
+			{"metadata", "generation"},
+			{"metadata", "selfLink"},
+			{"metadata", "creationTimestamp"},
+		} {
+			unstructured.RemoveNestedField(live.Object, f...)
+		}
+		s.live = append(s.live, live)
+	}
+	return s, nil
+}
+
+// release - returns the objects of the snapshot as a release
+func (s *snapshot) release(namespace, component string) *release {
+	rel := &release{namespace: namespace, component: component}
+	for _, live := range s.live {
+		rel.objects = append(rel.objects, live)
+		rel.refs = append(rel.refs, kubeobj.RefOf(live))
+	}
+	return rel
+}
+
+// restore - brings the objects back to the snapshot, objects created since are deleted
+func (s *snapshot) restore(ctx context.Context, d *kubeobj.Dynamic, logger logger.Logger) error {
+	var res error
+	for _, live := range s.live {
+		if _, err := d.Apply(ctx, live, live.GetNamespace(), false); err != nil {
+			logger.Error(fmt.Sprintf("%s \"%s\" restore failed: %v", live.GetKind(), live.GetName(), err))
+			res = err
+			continue
+		}
+		logger.Debug(fmt.Sprintf("%s \"%s\" restored", live.GetKind(), live.GetName()))
+	}
+	for _, ref := range s.missing {
+		if err := d.DeleteRef(ctx, ref); err != nil && !kerrors.IsNotFound(err) {
+			logger.Error(fmt.Sprintf("%s \"%s\" restore failed: %v", ref.Kind, ref.Name, err))
+			res = err
+			continue
+		}
+		logger.Debug(fmt.Sprintf("%s \"%s\" removed", ref.Kind, ref.Name))
+	}
+	return res
+}
+
+// commit - applies the release and prunes the objects of the previous inventory that are not part of it.
+// On failure the objects are restored to their state before the release, on success the release is
+// recorded as the next revision of the plugin
+func commit(ctx context.Context, d *kubeobj.Dynamic, rel *release, logger logger.Logger) error {
+	prev, err := readInventory(ctx, d, rel.namespace, rel.component)
+	if err != nil {
+		return err
+	}
+	current := &inventory{Objects: rel.refs, Values: rel.values}
+
+	// only the applied and pruned objects change
+	changed := []kubeobj.ObjectRef{}
+	for _, obj := range rel.objects {
+		ref, err := d.Ref(obj, rel.namespace)
+		if err != nil {
+			return err
+		}
+		changed = append(changed, ref)
+	}
+	if prev != nil {
+		for _, ref := range prev.Objects {
+			if !current.contains(ref) {
+				changed = append(changed, ref)
+			}
+		}
+	}
+	snap, err := takeSnapshot(ctx, d, changed)
+	if err != nil {
+		return err
+	}
+
+	if err := rel.apply(ctx, d, prev, current, logger); err != nil {
+		logger.Warn(fmt.Sprintf("Failed to apply %s, restoring the previous state: %v", rel.component, err))
+		restoreErr := snap.restore(ctx, d, logger)
+		if restoreErr == nil && prev != nil {
+			restoreErr = writeInventory(ctx, d, rel.namespace, rel.component, prev)
+		} else if restoreErr == nil {
+			restoreErr = deleteInventory(ctx, d, rel.namespace, rel.component)
+		}
+		if restoreErr != nil {
+			logger.Error(fmt.Sprintf("Failed to restore the previous state of %s: %v", rel.component, restoreErr))
+		}
+		return err
+	}
+
+	h, err := readHistory(ctx, d, rel.namespace, rel.component)
+	if err != nil {
+		return err
+	}
+	// objects that existed before the first recorded revision, installed by an older venonactl
+	// or by hand, are kept as the first revision so they can be restored as well
+	if len(h.Revisions) == 0 && len(snap.live) > 0 {
+		if err := h.add(snap.release(rel.namespace, rel.component)); err != nil {
+			return err
+		}
+	}
+	if err := h.add(rel); err != nil {
+		return err
+	}
+	return writeHistory(ctx, d, rel.namespace, rel.component, h)
+}
+
+func (rel *release) apply(ctx context.Context, d *kubeobj.Dynamic, prev *inventory, current *inventory, logger logger.Logger) error {
+	for _, obj := range rel.objects {
+		applied, err := d.Apply(ctx, obj, rel.namespace, false)
+		if err != nil {
+			return err
+		}
+		logger.Debug(fmt.Sprintf("%s \"%s\" applied", applied.GetKind(), applied.GetName()))
+	}
+	if err := prune(ctx, d, prev, current, logger); err != nil {
+		return err
+	}
+	return writeInventory(ctx, d, rel.namespace, rel.component, current)
+}
+
+// rollback - applies a previous revision of the plugin, recorded as the next revision.
+// Returns the values of the restored revision
+func rollback(ctx context.Context, opt *rollbackOptions) (Values, error) {
+	d, err := newDynamic(opt.kubeBuilder)
+	if err != nil {
+		return nil, err
+	}
+	h, err := readHistory(ctx, d, opt.namespace, opt.operatorType)
+	if err != nil {
+		return nil, err
+	}
+	rev, err := h.get(opt.revision)
+	if err != nil {
+		return nil, err
+	}
+	rel, err := rev.release(opt.namespace, opt.operatorType)
+	if err != nil {
+		return nil, err
+	}
+	opt.logger.Debug(fmt.Sprintf("Rolling back %s to revision %d", opt.operatorType, rev.Revision))
+	if err := commit(ctx, d, rel, opt.logger); err != nil {
+		return nil, err
+	}
+	return rev.Values, nil
+}
@@ -0,0 +1,352 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/logger"
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj/kubeobjtest"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	testNamespace = "ns"
+	testComponent = "test"
+)
+
+// configBuilder - builds the config of the test server
+type configBuilder struct {
+	config *rest.Config
+}
+
+func (b *configBuilder) BuildConfig() (*rest.Config, error) {
+	return b.config, nil
+}
+
+// testConfig - the config of the server, not rate limited as every test talks to its own server
+func testConfig(srv *kubeobjtest.Server) *configBuilder {
+	return &configBuilder{config: &rest.Config{Host: srv.URL, QPS: -1}}
+}
+
+func testDynamic(t *testing.T, objects ...interface{}) (*kubeobjtest.Server, *kubeobj.Dynamic) {
+	srv := kubeobjtest.NewServer()
+	t.Cleanup(srv.Close)
+	for _, obj := range objects {
+		srv.Put(obj)
+	}
+	d, err := newDynamic(testConfig(srv))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return srv, d
+}
+
+func configMap(name, value string) *v1.ConfigMap {
+	return &v1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      name,
+			Namespace: testNamespace,
+			Labels:    managedLabels(testNamespace, testComponent),
+		},
+		Data: map[string]string{"value": value},
+	}
+}
+
+func configMapRef(name string) kubeobj.ObjectRef {
+	return kubeobj.ObjectRef{APIVersion: "v1", Kind: "ConfigMap", Namespace: testNamespace, Name: name}
+}
+
+// testRelease - a release of the config maps, named by their value
+func testRelease(values map[string]interface{}, objects ...*v1.ConfigMap) *release {
+	rel := &release{namespace: testNamespace, component: testComponent, values: values}
+	for _, obj := range objects {
+		rel.objects = append(rel.objects, obj)
+		rel.refs = append(rel.refs, configMapRef(obj.Name))
+	}
+	return rel
+}
+
+// liveValue - the value of the config map on the server, empty when it does not exist
+func liveValue(srv *kubeobjtest.Server, name string) string {
+	value, _, _ := unstructured.NestedString(srv.Get(kubeobjtest.Path("v1", "configmaps", testNamespace, name)), "data", "value")
+	return value
+}
+
+func revisionNumbers(h *history) []int {
+	numbers := []int{}
+	for _, rev := range h.Revisions {
+		numbers = append(numbers, rev.Revision)
+	}
+	return numbers
+}
+
+func TestHistory_get(t *testing.T) {
+	h := &history{Revisions: []revision{{Revision: 3}, {Revision: 4}, {Revision: 5}}}
+	tests := []struct {
+		name    string
+		history *history
+		n       int
+		want    int
+		wantErr string
+	}{
+		{name: "previous revision", history: h, n: 0, want: 4},
+		{name: "revision by number", history: h, n: 3, want: 3},
+		{name: "last revision by number", history: h, n: 5, want: 5},
+		{name: "trimmed revision", history: h, n: 2, wantErr: "revision 2 not found"},
+		{name: "single revision", history: &history{Revisions: []revision{{Revision: 1}}}, n: 0, wantErr: ErrNoPreviousRevision.Error()},
+		{name: "empty history", history: &history{}, n: 0, wantErr: ErrNoPreviousRevision.Error()},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := tt.history.get(tt.n)
+			if tt.wantErr != "" {
+				if err == nil || err.Error() != tt.wantErr {
+					t.Fatalf("get() error = %v, want %s", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("get() error = %v", err)
+			}
+			if got.Revision != tt.want {
+				t.Errorf("get() = revision %d, want %d", got.Revision, tt.want)
+			}
+		})
+	}
+}
+
+func TestHistory_add(t *testing.T) {
+	tests := []struct {
+		name     string
+		releases int
+		want     []int
+	}{
+		{name: "first revision", releases: 1, want: []int{1}},
+		{name: "up to the limit", releases: maxRevisions, want: []int{1, 2, 3, 4, 5}},
+		{name: "oldest revisions are trimmed", releases: maxRevisions + 2, want: []int{3, 4, 5, 6, 7}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &history{}
+			for i := 0; i < tt.releases; i++ {
+				if err := h.add(testRelease(nil, configMap("a", "v"))); err != nil {
+					t.Fatalf("add() error = %v", err)
+				}
+			}
+			if got := revisionNumbers(h); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("add() revisions = %v, want %v", got, tt.want)
+			}
+			rel, err := h.Revisions[len(h.Revisions)-1].release(testNamespace, testComponent)
+			if err != nil {
+				t.Fatalf("release() error = %v", err)
+			}
+			if len(rel.objects) != 1 || !reflect.DeepEqual(rel.refs, []kubeobj.ObjectRef{configMapRef("a")}) {
+				t.Errorf("release() = %d objects %v, want the config map", len(rel.objects), rel.refs)
+			}
+		})
+	}
+}
+
+func TestCommit(t *testing.T) {
+	lgr := logger.New(&logger.Options{})
+	tests := []struct {
+		name string
+		// existing - the config maps before the commit, recorded by a previous commit when set
+		existing []*v1.ConfigMap
+		release  *release
+		fail     string
+		wantErr  bool
+		want     map[string]string
+		// wantInventory - the objects recorded in the inventory after the commit
+		wantInventory []kubeobj.ObjectRef
+		wantRevisions []int
+	}{
+		{
+			name:          "first commit",
+			release:       testRelease(nil, configMap("a", "new")),
+			want:          map[string]string{"a": "new"},
+			wantInventory: []kubeobj.ObjectRef{configMapRef("a")},
+			wantRevisions: []int{1},
+		},
+		{
+			name:          "objects not released anymore are pruned",
+			existing:      []*v1.ConfigMap{configMap("a", "old"), configMap("b", "old")},
+			release:       testRelease(nil, configMap("a", "new")),
+			want:          map[string]string{"a": "new", "b": ""},
+			wantInventory: []kubeobj.ObjectRef{configMapRef("a")},
+			wantRevisions: []int{1, 2},
+		},
+		{
+			name:          "failed commit restores the changed objects",
+			existing:      []*v1.ConfigMap{configMap("a", "old")},
+			release:       testRelease(nil, configMap("a", "new"), configMap("b", "new")),
+			fail:          "/configmaps/b",
+			wantErr:       true,
+			want:          map[string]string{"a": "old", "b": ""},
+			wantInventory: []kubeobj.ObjectRef{configMapRef("a")},
+			wantRevisions: []int{1},
+		},
+		{
+			name:          "failed first commit removes the created objects",
+			release:       testRelease(nil, configMap("a", "new"), configMap("b", "new")),
+			fail:          "/configmaps/b",
+			wantErr:       true,
+			want:          map[string]string{"a": "", "b": ""},
+			wantRevisions: []int{},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, d := testDynamic(t)
+			ctx := context.Background()
+			if tt.existing != nil {
+				if err := commit(ctx, d, testRelease(nil, tt.existing...), lgr); err != nil {
+					t.Fatalf("commit() of the existing objects error = %v", err)
+				}
+			}
+			srv.Fail = func(r *http.Request) bool {
+				return tt.fail != "" && r.Method == http.MethodPatch && strings.HasSuffix(r.URL.Path, tt.fail)
+			}
+
+			err := commit(ctx, d, tt.release, lgr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("commit() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			srv.Fail = nil
+
+			for name, want := range tt.want {
+				if got := liveValue(srv, name); got != want {
+					t.Errorf("config map %s = %q, want %q", name, got, want)
+				}
+			}
+			inv, err := readInventory(ctx, d, testNamespace, testComponent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var gotInventory []kubeobj.ObjectRef
+			if inv != nil {
+				gotInventory = inv.Objects
+			}
+			if !reflect.DeepEqual(gotInventory, tt.wantInventory) {
+				t.Errorf("inventory = %v, want %v", gotInventory, tt.wantInventory)
+			}
+			h, err := readHistory(ctx, d, testNamespace, testComponent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := revisionNumbers(h); !reflect.DeepEqual(got, tt.wantRevisions) {
+				t.Errorf("revisions = %v, want %v", got, tt.wantRevisions)
+			}
+		})
+	}
+}
+
+func TestRollback(t *testing.T) {
+	lgr := logger.New(&logger.Options{})
+	tests := []struct {
+		name     string
+		revision int
+		want     map[string]string
+		// wantValues - the values of the restored revision
+		wantValues    map[string]interface{}
+		wantRevisions []int
+		wantErr       error
+	}{
+		{
+			name:          "previous revision",
+			want:          map[string]string{"a": "v2", "b": "v2", "c": ""},
+			wantValues:    map[string]interface{}{"Version": "2"},
+			wantRevisions: []int{1, 2, 3, 4},
+		},
+		{
+			name:          "revision by number",
+			revision:      1,
+			want:          map[string]string{"a": "v1", "b": "", "c": ""},
+			wantValues:    map[string]interface{}{"Version": "1"},
+			wantRevisions: []int{1, 2, 3, 4},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv, d := testDynamic(t)
+			ctx := context.Background()
+			for _, rel := range []*release{
+				testRelease(map[string]interface{}{"Version": "1"}, configMap("a", "v1")),
+				testRelease(map[string]interface{}{"Version": "2"}, configMap("a", "v2"), configMap("b", "v2")),
+				testRelease(map[string]interface{}{"Version": "3"}, configMap("a", "v3"), configMap("c", "v3")),
+			} {
+				if err := commit(ctx, d, rel, lgr); err != nil {
+					t.Fatalf("commit() error = %v", err)
+				}
+			}
+
+			got, err := rollback(ctx, &rollbackOptions{
+				namespace:    testNamespace,
+				operatorType: testComponent,
+				revision:     tt.revision,
+				kubeBuilder:  testConfig(srv),
+				logger:       lgr,
+			})
+			if err != nil {
+				t.Fatalf("rollback() error = %v", err)
+			}
+			if !reflect.DeepEqual(map[string]interface{}(got), tt.wantValues) {
+				t.Errorf("rollback() = %v, want %v", got, tt.wantValues)
+			}
+			for name, want := range tt.want {
+				if got := liveValue(srv, name); got != want {
+					t.Errorf("config map %s = %q, want %q", name, got, want)
+				}
+			}
+			h, err := readHistory(ctx, d, testNamespace, testComponent)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := revisionNumbers(h); !reflect.DeepEqual(got, tt.wantRevisions) {
+				t.Errorf("revisions = %v, want %v", got, tt.wantRevisions)
+			}
+		})
+	}
+}
+
+func TestRollback_noPreviousRevision(t *testing.T) {
+	lgr := logger.New(&logger.Options{})
+	srv, d := testDynamic(t)
+	if err := commit(context.Background(), d, testRelease(nil, configMap("a", "v1")), lgr); err != nil {
+		t.Fatalf("commit() error = %v", err)
+	}
+	_, err := rollback(context.Background(), &rollbackOptions{
+		namespace:    testNamespace,
+		operatorType: testComponent,
+		kubeBuilder:  testConfig(srv),
+		logger:       lgr,
+	})
+	if !errors.Is(err, ErrNoPreviousRevision) {
+		t.Errorf("rollback() error = %v, want %v", err, ErrNoPreviousRevision)
+	}
+}
@@ -93,6 +93,15 @@ func buildRuntimeConfig(ctx context.Context, opt *InstallOptions, v Values) (Run
 	return rc, nil
 }
 
+// runtimeConfKey - key of the runtime in the runnerconf secret, the name is normalized
+// to make sure we are not violating kube naming conventions
+func runtimeConfKey(runtimeEnvironment string) string {
+	name := strings.ReplaceAll(runtimeEnvironment, "/", ".")
+	name = strings.ReplaceAll(name, "@", ".")
+	name = strings.ReplaceAll(name, ":", ".")
+	return fmt.Sprintf("%s.runtime.yaml", name)
+}
+
 func readCurrentVenonaConf(ctx context.Context, agentKubeBuilder KubeClientBuilder, clusterNamespace string) (venonaConf, error) {
 
 	cs, err := agentKubeBuilder.BuildClient()
@@ -145,11 +154,7 @@ func (u *runtimeAttachPlugin) Install(ctx context.Context, opt *InstallOptions,
 	if currentVenonaConf.Runtimes == nil {
 		currentVenonaConf.Runtimes = make(map[string]RuntimeConfiguration)
 	}
-	// normalize the key in the secret to make sure we are not violating kube naming conventions
-	name := strings.ReplaceAll(opt.RuntimeEnvironment, "/", ".")
-	name = strings.ReplaceAll(name, "@", ".")
-	name = strings.ReplaceAll(name, ":", ".")
-	currentVenonaConf.Runtimes[fmt.Sprintf("%s.runtime.yaml", name)] = rc
+	currentVenonaConf.Runtimes[runtimeConfKey(opt.RuntimeEnvironment)] = rc
 	runtimes := map[string]string{}
 	for name, runtime := range currentVenonaConf.Runtimes {
 		// marshel prior persist
@@ -164,17 +169,16 @@ func (u *runtimeAttachPlugin) Install(ctx context.Context, opt *InstallOptions,
 	v["runnerConf"] = runtimes
 	v["Namespace"] = opt.ClusterNamespace
 
-	cs.CoreV1().Secrets(opt.ClusterNamespace).Delete(ctx, runtimeSecretName, metav1.DeleteOptions{})
-
-	err = install(ctx, &installOptions{
+	err = apply(ctx, &applyOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.AgentKubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   runtimeAttachFilesPattern,
 		operatorType:   RuntimeAttachType,
 		dryRun:         opt.DryRun,
+		dryRunObjects:  opt.DryRunObjects,
 	})
 
 	if err != nil {
@@ -220,15 +224,10 @@ func (u *runtimeAttachPlugin) Install(ctx context.Context, opt *InstallOptions,
 
 func (u *runtimeAttachPlugin) Status(ctx context.Context, statusOpt *StatusOptions, v Values) ([][]string, error) {
 
-	cs, err := statusOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil, err
-	}
 	opt := &statusOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    statusOpt.KubeBuilder,
 		namespace:      statusOpt.ClusterNamespace,
 		matchPattern:   runtimeAttachFilesPattern,
 		operatorType:   RuntimeAttachType,
@@ -252,8 +251,7 @@ func (u *runtimeAttachPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptio
 		u.logger.Error(fmt.Sprintf("Cannot read runnerconf: %v ", err))
 		return err
 	}
-	name := strings.ReplaceAll(deleteOpt.RuntimeEnvironment, "/", ".")
-	name = fmt.Sprintf("%s.runtime.yaml", name)
+	name := runtimeConfKey(deleteOpt.RuntimeEnvironment)
 	if _, ok := currentVenonaConf.Runtimes[name]; ok {
 		delete(currentVenonaConf.Runtimes, name)
 	}
@@ -279,11 +277,11 @@ func (u *runtimeAttachPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptio
 
 		cs.CoreV1().Secrets(deleteOpt.AgentNamespace).Delete(ctx, runtimeSecretName, metav1.DeleteOptions{})
 
-		err = install(ctx, &installOptions{
+		err = apply(ctx, &applyOptions{
 			logger:         u.logger,
 			templates:      templates.TemplatesMap(),
 			templateValues: v,
-			kubeClientSet:  cs,
+			kubeBuilder:    deleteOpt.AgentKubeBuilder,
 			namespace:      deleteOpt.AgentNamespace,
 			matchPattern:   runtimeAttachFilesPattern,
 			operatorType:   RuntimeAttachType,
@@ -296,7 +294,7 @@ func (u *runtimeAttachPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptio
 		opt := &deleteOptions{
 			templates:      templates.TemplatesMap(),
 			templateValues: v,
-			kubeClientSet:  cs,
+			kubeBuilder:    deleteOpt.AgentKubeBuilder,
 			namespace:      deleteOpt.AgentNamespace,
 			matchPattern:   runtimeAttachFilesPattern,
 			operatorType:   RuntimeAttachType,
@@ -320,6 +318,22 @@ func (u *runtimeAttachPlugin) Test(context.Context, *TestOptions, Values) error
 	return nil
 }
 
+// Diff is not supported, the runtime configuration is built from the runtime cluster
+func (u *runtimeAttachPlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	return []ObjectDiff{}, nil
+}
+
+// Rollback restores the runtimes configuration of the agent
+func (u *runtimeAttachPlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return rollback(ctx, &rollbackOptions{
+		logger:       u.logger,
+		kubeBuilder:  opt.AgentKubeBuilder,
+		namespace:    opt.AgentNamespace,
+		revision:     opt.Revision,
+		operatorType: RuntimeAttachType,
+	})
+}
+
 func (u *runtimeAttachPlugin) Name() string {
 	return RuntimeAttachType
 }
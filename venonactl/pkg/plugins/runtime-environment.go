@@ -24,6 +24,7 @@ import (
 	"github.com/codefresh-io/venona/venonactl/pkg/codefresh"
 	"github.com/codefresh-io/venona/venonactl/pkg/logger"
 	templates "github.com/codefresh-io/venona/venonactl/pkg/templates/kubernetes"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
 // runtimeEnvironmentPlugin installs assets on Kubernetes Dind runtimectl Env
@@ -33,6 +34,8 @@ type runtimeEnvironmentPlugin struct {
 
 const (
 	runtimeEnvironmentFilesPattern = ".*.re.yaml"
+	// serverCertsSecretName - the secret of codefresh-certs-server-secret.re.yaml
+	serverCertsSecretName = "codefresh-certs-server"
 )
 
 // Install runtimectl environment
@@ -85,15 +88,18 @@ func (u *runtimeEnvironmentPlugin) Install(ctx context.Context, opt *InstallOpti
 	}
 
 	v["RuntimeEnvironment"] = opt.RuntimeEnvironment
-	err = install(ctx, &installOptions{
+	// the runtime-environment is created in Codefresh before the installation, it isn't owned by it
+	v[RuntimeEnvironmentRegisteredValue] = false
+	err = apply(ctx, &applyOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.KubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   runtimeEnvironmentFilesPattern,
 		operatorType:   RuntimeEnvironmentPluginType,
 		dryRun:         opt.DryRun,
+		dryRunObjects:  opt.DryRunObjects,
 	})
 	if err != nil {
 		return nil, err
@@ -103,15 +109,10 @@ func (u *runtimeEnvironmentPlugin) Install(ctx context.Context, opt *InstallOpti
 }
 
 func (u *runtimeEnvironmentPlugin) Status(ctx context.Context, statusOpt *StatusOptions, v Values) ([][]string, error) {
-	cs, err := statusOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil, err
-	}
 	opt := &statusOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    statusOpt.KubeBuilder,
 		namespace:      statusOpt.ClusterNamespace,
 		matchPattern:   runtimeEnvironmentFilesPattern,
 		operatorType:   RuntimeEnvironmentPluginType,
@@ -121,15 +122,10 @@ func (u *runtimeEnvironmentPlugin) Status(ctx context.Context, statusOpt *Status
 }
 
 func (u *runtimeEnvironmentPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v Values) error {
-	cs, err := deleteOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return err
-	}
 	opt := &deleteOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    deleteOpt.KubeBuilder,
 		namespace:      deleteOpt.ClusterNamespace,
 		matchPattern:   runtimeEnvironmentFilesPattern,
 		operatorType:   RuntimeEnvironmentPluginType,
@@ -138,7 +134,35 @@ func (u *runtimeEnvironmentPlugin) Delete(ctx context.Context, deleteOpt *Delete
 	return uninstall(ctx, opt)
 }
 
-func (u *runtimeEnvironmentPlugin) Upgrade(_ context.Context, _ *UpgradeOptions, v Values) (Values, error) {
+// Upgrade - applies the templates again with the server certificates of dind that are installed,
+// they are signed only on install
+func (u *runtimeEnvironmentPlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Values) (Values, error) {
+	cs, err := opt.KubeBuilder.BuildClient()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create kubernetes clientset: %v ", err)
+	}
+	secret, err := cs.CoreV1().Secrets(opt.ClusterNamespace).Get(ctx, serverCertsSecretName, metav1.GetOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read the server certificates of %s, install the runtime again: %v", opt.RuntimeEnvironment, err)
+	}
+	v["ServerCert"] = map[string]string{
+		"Cert": base64.StdEncoding.EncodeToString(secret.Data["server-cert.pem"]),
+		"Key":  base64.StdEncoding.EncodeToString(secret.Data["server-key.pem"]),
+		"Ca":   base64.StdEncoding.EncodeToString(secret.Data["ca.pem"]),
+	}
+	v["RuntimeEnvironment"] = opt.RuntimeEnvironment
+	err = apply(ctx, &applyOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   runtimeEnvironmentFilesPattern,
+		operatorType:   RuntimeEnvironmentPluginType,
+	})
+	if err != nil {
+		return nil, err
+	}
 	return v, nil
 }
 
@@ -199,6 +223,29 @@ func (u *runtimeEnvironmentPlugin) Test(ctx context.Context, opt *TestOptions, v
 	})
 }
 
+func (u *runtimeEnvironmentPlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	v["RuntimeEnvironment"] = opt.RuntimeEnvironment
+	return diff(ctx, &diffOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   runtimeEnvironmentFilesPattern,
+		operatorType:   RuntimeEnvironmentPluginType,
+	})
+}
+
+func (u *runtimeEnvironmentPlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return rollback(ctx, &rollbackOptions{
+		logger:       u.logger,
+		kubeBuilder:  opt.KubeBuilder,
+		namespace:    opt.ClusterNamespace,
+		revision:     opt.Revision,
+		operatorType: RuntimeEnvironmentPluginType,
+	})
+}
+
 func (u *runtimeEnvironmentPlugin) Name() string {
 	return RuntimeEnvironmentPluginType
 }
@@ -0,0 +1,361 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
+	"github.com/codefresh-io/venona/venonactl/pkg/store"
+	"gopkg.in/yaml.v2"
+	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+)
+
+const (
+	redacted = "REDACTED"
+	// supportLogLines - lines of the log of every container that are collected
+	supportLogLines = int64(2000)
+)
+
+// supportComponents - plugins whose inventory is collected
+var supportComponents = []string{
+	VenonaPluginType,
+	RuntimeEnvironmentPluginType,
+	VolumeProvisionerPluginType,
+	EnginePluginType,
+	MonitorAgentPluginType,
+	AppProxyPluginType,
+	RuntimeAttachType,
+}
+
+// supportApps - the app label of the pods whose description and logs are collected
+var supportApps = []string{
+	store.ApplicationName,
+	store.MonitorApplicationName,
+	store.AppProxyApplicationName,
+	"dind-volume-provisioner",
+	"dind-lv-monitor",
+}
+
+// sensitiveKey - values under matching keys are redacted
+var sensitiveKey = regexp.MustCompile(`(?i)token|key|cert|crt|password|secret|credential|serviceaccount|runnerconf|^ca$`)
+
+// SupportBundle - diagnostics of an installation, paths of files mapped to their content
+type SupportBundle map[string][]byte
+
+type supportCollector struct {
+	d      *kubeobj.Dynamic
+	cs     kubernetes.Interface
+	bundle SupportBundle
+	errs   []string
+}
+
+// CollectSupportBundle - collects the values and objects of the components installed in the namespace,
+// the description and logs of the pods of the runner, the events of the namespace and a summary of the nodes.
+// Values, the data of secrets and sensitive environment variables are redacted. Parts that cannot be collected are listed in errors.txt
+func CollectSupportBundle(ctx context.Context, kubeBuilder interface {
+	BuildConfig() (*rest.Config, error)
+}, namespace string) (SupportBundle, error) {
+	d, err := newDynamic(kubeBuilder)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := newClientset(kubeBuilder)
+	if err != nil {
+		return nil, err
+	}
+	c := &supportCollector{d: d, cs: cs, bundle: SupportBundle{}}
+	for _, component := range supportComponents {
+		c.collectComponent(ctx, namespace, component)
+	}
+	c.collectPods(ctx, namespace)
+	c.collectEvents(ctx, namespace)
+	c.collectNodes(ctx)
+	if len(c.errs) > 0 {
+		c.bundle["errors.txt"] = []byte(strings.Join(c.errs, "\n") + "\n")
+	}
+	return c.bundle, nil
+}
+
+func (c *supportCollector) fail(format string, args ...interface{}) {
+	c.errs = append(c.errs, fmt.Sprintf(format, args...))
+}
+
+func (c *supportCollector) addYAML(path string, obj interface{}) {
+	data, err := yaml.Marshal(obj)
+	if err != nil {
+		c.fail("%s: %v", path, err)
+		return
+	}
+	c.bundle[path] = data
+}
+
+func (c *supportCollector) collectComponent(ctx context.Context, namespace, component string) {
+	inv, err := readInventory(ctx, c.d, namespace, component)
+	if err != nil {
+		c.fail("inventory of %s: %v", component, err)
+		return
+	}
+	if inv == nil {
+		return
+	}
+	c.addYAML(fmt.Sprintf("values/%s.yaml", component), RedactValues(inv.Values))
+	for _, ref := range inv.Objects {
+		path := fmt.Sprintf("objects/%s/%s-%s.yaml", component, strings.ToLower(ref.Kind), ref.Name)
+		live, err := c.d.GetRef(ctx, ref)
+		if err != nil {
+			c.fail("%s %s: %v", ref.Kind, ref.Name, err)
+			continue
+		}
+		if live == nil {
+			c.fail("%s %s: not found", ref.Kind, ref.Name)
+			continue
+		}
+		c.addYAML(path, redactObject(live).Object)
+	}
+}
+
+func (c *supportCollector) collectPods(ctx context.Context, namespace string) {
+	pods, err := c.cs.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{
+		LabelSelector: fmt.Sprintf("app in (%s)", strings.Join(supportApps, ",")),
+	})
+	if err != nil {
+		c.fail("pods: %v", err)
+		return
+	}
+	for i := range pods.Items {
+		pod := &pods.Items[i]
+		pod.ManagedFields = nil
+		u, err := runtime.DefaultUnstructuredConverter.ToUnstructured(pod)
+		if err != nil {
+			c.fail("pod %s: %v", pod.Name, err)
+			continue
+		}
+		redactPodSpec(u, "spec")
+		c.addYAML(fmt.Sprintf("pods/%s.yaml", pod.Name), u)
+		for _, status := range pod.Status.ContainerStatuses {
+			c.collectLog(ctx, pod, status.Name, false)
+			if status.RestartCount > 0 {
+				c.collectLog(ctx, pod, status.Name, true)
+			}
+		}
+	}
+}
+
+func (c *supportCollector) collectLog(ctx context.Context, pod *v1.Pod, container string, previous bool) {
+	tail := supportLogLines
+	data, err := c.cs.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &v1.PodLogOptions{
+		Container: container,
+		TailLines: &tail,
+		Previous:  previous,
+	}).DoRaw(ctx)
+	path := fmt.Sprintf("pods/%s/%s.log", pod.Name, container)
+	if previous {
+		path = fmt.Sprintf("pods/%s/%s.previous.log", pod.Name, container)
+	}
+	if err != nil {
+		c.fail("%s: %v", path, err)
+		return
+	}
+	c.bundle[path] = data
+}
+
+func (c *supportCollector) collectEvents(ctx context.Context, namespace string) {
+	events, err := c.cs.CoreV1().Events(namespace).List(ctx, metav1.ListOptions{})
+	if err != nil {
+		c.fail("events: %v", err)
+		return
+	}
+	sort.SliceStable(events.Items, func(i, j int) bool {
+		return eventTime(events.Items[i]).Before(eventTime(events.Items[j]))
+	})
+	var b strings.Builder
+	for _, e := range events.Items {
+		fmt.Fprintf(&b, "%s\t%s\t%s\t%s/%s\tx%d\t%s\n", eventTime(e).Format(time.RFC3339), e.Type, e.Reason,
+			e.InvolvedObject.Kind, e.InvolvedObject.Name, e.Count, strings.TrimSpace(e.Message))
+	}
+	c.bundle["events.txt"] = []byte(b.String())
+}
+
+func eventTime(e v1.Event) time.Time {
+	if !e.LastTimestamp.IsZero() {
+		return e.LastTimestamp.Time
+	}
+	if !e.EventTime.IsZero() {
+		return e.EventTime.Time
+	}
+	return e.CreationTimestamp.Time
+}
+
+// nodeSummary - what is needed of a node to find scheduling and storage problems
+type nodeSummary struct {
+	Name           string            `yaml:"name"`
+	KubeletVersion string            `yaml:"kubeletVersion"`
+	Unschedulable  bool              `yaml:"unschedulable,omitempty"`
+	Labels         map[string]string `yaml:"labels,omitempty"`
+	Taints         []string          `yaml:"taints,omitempty"`
+	Capacity       map[string]string `yaml:"capacity"`
+	Allocatable    map[string]string `yaml:"allocatable"`
+	Conditions     map[string]string `yaml:"conditions"`
+}
+
+func (c *supportCollector) collectNodes(ctx context.Context) {
+	nodes, err := c.cs.CoreV1().Nodes().List(ctx, metav1.ListOptions{})
+	if err != nil {
+		c.fail("nodes: %v", err)
+		return
+	}
+	summaries := make([]nodeSummary, 0, len(nodes.Items))
+	for _, n := range nodes.Items {
+		s := nodeSummary{
+			Name:           n.Name,
+			KubeletVersion: n.Status.NodeInfo.KubeletVersion,
+			Unschedulable:  n.Spec.Unschedulable,
+			Labels:         n.Labels,
+			Capacity:       map[string]string{},
+			Allocatable:    map[string]string{},
+			Conditions:     map[string]string{},
+		}
+		for _, t := range n.Spec.Taints {
+			s.Taints = append(s.Taints, t.ToString())
+		}
+		for k, v := range n.Status.Capacity {
+			s.Capacity[string(k)] = v.String()
+		}
+		for k, v := range n.Status.Allocatable {
+			s.Allocatable[string(k)] = v.String()
+		}
+		for _, cond := range n.Status.Conditions {
+			s.Conditions[string(cond.Type)] = string(cond.Status)
+		}
+		summaries = append(summaries, s)
+	}
+	c.addYAML("nodes.yaml", summaries)
+}
+
+// RedactValues - returns a copy of the values in which sensitive values are replaced
+func RedactValues(values map[string]interface{}) map[string]interface{} {
+	res := make(map[string]interface{}, len(values))
+	for k, v := range values {
+		if sensitiveKey.MatchString(k) {
+			res[k] = redacted
+			continue
+		}
+		res[k] = redactValue(v)
+	}
+	return res
+}
+
+func redactValue(v interface{}) interface{} {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		return RedactValues(val)
+	case []interface{}:
+		res := make([]interface{}, len(val))
+		for i, item := range val {
+			res[i] = redactValue(item)
+		}
+		return res
+	}
+	return v
+}
+
+// redactObject - replaces the data of secrets, keeping the keys, and the values of sensitive
+// environment variables of workloads, and drops the managed fields
+func redactObject(u *unstructured.Unstructured) *unstructured.Unstructured {
+	u = u.DeepCopy()
+	u.SetManagedFields(nil)
+	switch u.GetKind() {
+	case "Secret":
+		for _, field := range []string{"data", "stringData"} {
+			data, found, _ := unstructured.NestedMap(u.Object, field)
+			if !found {
+				continue
+			}
+			for k := range data {
+				data[k] = redacted
+			}
+			unstructured.SetNestedMap(u.Object, data, field)
+		}
+	case "Deployment", "DaemonSet":
+		redactPodSpec(u.Object, "spec", "template", "spec")
+	case "Pod":
+		redactPodSpec(u.Object, "spec")
+	default:
+		return u
+	}
+	annotations := u.GetAnnotations()
+	if _, ok := annotations["kubectl.kubernetes.io/last-applied-configuration"]; ok {
+		annotations["kubectl.kubernetes.io/last-applied-configuration"] = redacted
+		u.SetAnnotations(annotations)
+	}
+	return u
+}
+
+// redactPodSpec - replaces the values of the environment variables with sensitive names
+// in the containers of the pod spec at the given fields
+func redactPodSpec(obj map[string]interface{}, fields ...string) {
+	for _, list := range []string{"containers", "initContainers"} {
+		path := append(append([]string{}, fields...), list)
+		containers, found, _ := unstructured.NestedSlice(obj, path...)
+		if !found {
+			continue
+		}
+		for _, c := range containers {
+			container, ok := c.(map[string]interface{})
+			if !ok {
+				continue
+			}
+			env, _ := container["env"].([]interface{})
+			for _, e := range env {
+				variable, ok := e.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				name, _ := variable["name"].(string)
+				if _, ok := variable["value"]; ok && sensitiveKey.MatchString(name) {
+					variable["value"] = redacted
+				}
+			}
+		}
+		unstructured.SetNestedSlice(obj, containers, path...)
+	}
+}
+
+// AttachedRuntimes - names of the runtimes in the runnerconf secret of the agent
+func AttachedRuntimes(ctx context.Context, agentKubeBuilder KubeClientBuilder, agentNamespace string) ([]string, error) {
+	conf, err := readCurrentVenonaConf(ctx, agentKubeBuilder, agentNamespace)
+	if err != nil {
+		return nil, err
+	}
+	names := make([]string, 0, len(conf.Runtimes))
+	for _, rc := range conf.Runtimes {
+		names = append(names, rc.Name)
+	}
+	sort.Strings(names)
+	return names, nil
+}
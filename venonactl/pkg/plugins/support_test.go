@@ -0,0 +1,142 @@
+package plugins
+
+/*
+Copyright 2019 The Codefresh Authors.
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+import (
+	"reflect"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+)
+
+func container(env ...map[string]interface{}) map[string]interface{} {
+	vars := make([]interface{}, 0, len(env))
+	for _, e := range env {
+		vars = append(vars, e)
+	}
+	return map[string]interface{}{"name": "venona", "env": vars}
+}
+
+func TestRedactObject(t *testing.T) {
+	env := func() map[string]interface{} {
+		return container(
+			map[string]interface{}{"name": "CODEFRESH_TOKEN", "value": "secret"},
+			map[string]interface{}{"name": "CODEFRESH_HOST", "value": "https://g.codefresh.io"},
+			map[string]interface{}{"name": "AGENT_SECRET", "valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "venona"}}},
+		)
+	}
+	redactedEnv := container(
+		map[string]interface{}{"name": "CODEFRESH_TOKEN", "value": redacted},
+		map[string]interface{}{"name": "CODEFRESH_HOST", "value": "https://g.codefresh.io"},
+		map[string]interface{}{"name": "AGENT_SECRET", "valueFrom": map[string]interface{}{"secretKeyRef": map[string]interface{}{"name": "venona"}}},
+	)
+	tests := []struct {
+		name   string
+		object map[string]interface{}
+		want   map[string]interface{}
+	}{
+		{
+			name: "should redact the data of a secret",
+			object: map[string]interface{}{
+				"kind": "Secret",
+				"data": map[string]interface{}{"token": "c2VjcmV0"},
+			},
+			want: map[string]interface{}{
+				"kind": "Secret",
+				"data": map[string]interface{}{"token": redacted},
+			},
+		},
+		{
+			name: "should redact sensitive environment variables of a deployment",
+			object: map[string]interface{}{
+				"kind": "Deployment",
+				"metadata": map[string]interface{}{"annotations": map[string]interface{}{
+					"kubectl.kubernetes.io/last-applied-configuration": "{}",
+				}},
+				"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+					"containers":     []interface{}{env()},
+					"initContainers": []interface{}{env()},
+				}}},
+			},
+			want: map[string]interface{}{
+				"kind": "Deployment",
+				"metadata": map[string]interface{}{"annotations": map[string]interface{}{
+					"kubectl.kubernetes.io/last-applied-configuration": redacted,
+				}},
+				"spec": map[string]interface{}{"template": map[string]interface{}{"spec": map[string]interface{}{
+					"containers":     []interface{}{redactedEnv},
+					"initContainers": []interface{}{redactedEnv},
+				}}},
+			},
+		},
+		{
+			name: "should redact sensitive environment variables of a pod",
+			object: map[string]interface{}{
+				"kind": "Pod",
+				"spec": map[string]interface{}{"containers": []interface{}{env()}},
+			},
+			want: map[string]interface{}{
+				"kind": "Pod",
+				"spec": map[string]interface{}{"containers": []interface{}{redactedEnv}},
+			},
+		},
+		{
+			name: "should keep other kinds",
+			object: map[string]interface{}{
+				"kind": "ConfigMap",
+				"data": map[string]interface{}{"token": "value"},
+			},
+			want: map[string]interface{}{
+				"kind": "ConfigMap",
+				"data": map[string]interface{}{"token": "value"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			u := &unstructured.Unstructured{Object: tt.object}
+			if got := redactObject(u).Object; !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("redactObject() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedactValues(t *testing.T) {
+	values := map[string]interface{}{
+		"Token":     "secret",
+		"Namespace": "codefresh",
+		"Runtimes": []interface{}{
+			map[string]interface{}{"Name": "runtime", "Crt": "cert"},
+		},
+		"Venona": map[string]interface{}{"ServiceAccount": map[string]interface{}{"Name": "venona"}},
+	}
+	want := map[string]interface{}{
+		"Token":     redacted,
+		"Namespace": "codefresh",
+		"Runtimes": []interface{}{
+			map[string]interface{}{"Name": "runtime", "Crt": redacted},
+		},
+		"Venona": map[string]interface{}{"ServiceAccount": redacted},
+	}
+	if got := RedactValues(values); !reflect.DeepEqual(got, want) {
+		t.Errorf("RedactValues() = %v, want %v", got, want)
+	}
+	if values["Token"] != "secret" {
+		t.Errorf("RedactValues() changed the values")
+	}
+}
@@ -27,4 +27,12 @@ const (
 	StatusInstalled = "Installed"
 	// StatusNotInstalled - status installed
 	StatusNotInstalled = "Not Installed"
+	// StatusStale - installed by an older version of the templates
+	StatusStale = "Stale"
+	// StatusHealthy - installed and working
+	StatusHealthy = "Healthy"
+	// StatusProgressing - a rollout of the object is in progress
+	StatusProgressing = "Progressing"
+	// StatusUnhealthy - installed but not working
+	StatusUnhealthy = "Unhealthy"
 )
@@ -25,7 +25,6 @@ import (
 
 	"github.com/codefresh-io/go-sdk/pkg/codefresh"
 	"github.com/codefresh-io/venona/venonactl/pkg/logger"
-	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
 	templates "github.com/codefresh-io/venona/venonactl/pkg/templates/kubernetes"
 	v1 "k8s.io/api/core/v1"
 	kerrors "k8s.io/apimachinery/pkg/api/errors"
@@ -46,6 +45,8 @@ type migrationData struct {
 
 const (
 	venonaFilesPattern = ".*.venona.yaml"
+	// venonaConfTemplate - the runtimes configuration, owned by attach and detach of runtimes once it exists
+	venonaConfTemplate = "venonaconf.secret.venona.yaml"
 )
 
 // Install venona agent
@@ -84,48 +85,68 @@ func (u *venonaPlugin) Install(ctx context.Context, opt *InstallOptions, v Value
 		return nil, err
 	}
 
-	return v, install(ctx, &installOptions{
+	// installing again must not detach the runtimes that are already attached
+	skip := map[string]bool{}
+	if !opt.DryRun {
+		_, err = cs.CoreV1().Secrets(opt.ClusterNamespace).Get(ctx, fmt.Sprintf("%sconf", v["AppName"]), metav1.GetOptions{})
+		if err == nil {
+			skip[venonaConfTemplate] = true
+		} else if !kerrors.IsNotFound(err) {
+			return nil, err
+		}
+	}
+
+	return v, apply(ctx, &applyOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.KubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   venonaFilesPattern,
 		dryRun:         opt.DryRun,
+		dryRunObjects:  opt.DryRunObjects,
 		operatorType:   VenonaPluginType,
+		skip:           skip,
 	})
 }
 
 // Status of runtimectl environment
 func (u *venonaPlugin) Status(ctx context.Context, statusOpt *StatusOptions, v Values) ([][]string, error) {
-	cs, err := statusOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil, err
-	}
 	opt := &statusOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    statusOpt.KubeBuilder,
 		namespace:      statusOpt.ClusterNamespace,
 		matchPattern:   venonaFilesPattern,
 		operatorType:   VenonaPluginType,
 	}
-	return status(ctx, opt)
+	rows, err := status(ctx, opt)
+	if err != nil {
+		return nil, err
+	}
+	cs, err := statusOpt.KubeBuilder.BuildClient()
+	if err != nil {
+		return nil, fmt.Errorf("Cannot create kubernetes clientset: %v ", err)
+	}
+	health, err := venonaHealth(ctx, cs, statusOpt.ClusterNamespace, v["AppName"].(string))
+	if err != nil {
+		return nil, err
+	}
+	rows = append(rows, health...)
+	conf, err := readCurrentVenonaConf(ctx, statusOpt.KubeBuilder, statusOpt.ClusterNamespace)
+	if err != nil {
+		return nil, err
+	}
+	return append(rows, runtimesHealth(conf, statusOpt.RuntimeEnvironment, statusOpt.RegisteredRuntimes)...), nil
 }
 
 func (u *venonaPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v Values) error {
-	cs, err := deleteOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil
-	}
 	opt := &deleteOptions{
 		logger:         u.logger,
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    deleteOpt.KubeBuilder,
 		namespace:      deleteOpt.ClusterNamespace,
 		matchPattern:   venonaFilesPattern,
 		operatorType:   VenonaPluginType,
@@ -135,20 +156,11 @@ func (u *venonaPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v V
 
 func (u *venonaPlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Values) (Values, error) {
 
-	// replace of sa creates new secert with sa creds
-	// avoid it till patch fully implemented
-	var skipUpgradeFor = map[string]interface{}{
-		"service-account.venona.yaml":   nil,
-		"deployment.venona.yaml":        nil,
-		"venonaconf.secret.venona.yaml": nil,
+	// the runtimes configuration is owned by attach/uninstall of runtimes
+	var skipUpgradeFor = map[string]bool{
+		venonaConfTemplate: true,
 	}
 
-	var deletePriorUpgrade = map[string]interface{}{
-		"deployment.venona.yaml": nil,
-	}
-
-	var err error
-
 	kubeClientset, err := opt.KubeBuilder.BuildClient()
 	if err != nil {
 		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
@@ -156,9 +168,6 @@ func (u *venonaPlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Value
 	}
 
 	// special case when we need to get the token from the remote to no regenrate it
-	// whole flow should be more like kubectl apply that build a patch
-	// based on remote object and candidate object
-
 	secret, err := kubeClientset.CoreV1().Secrets(opt.ClusterNamespace).Get(ctx, opt.Name, metav1.GetOptions{})
 	if err != nil {
 		return nil, err
@@ -166,54 +175,33 @@ func (u *venonaPlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Value
 	token := string(secret.Data["codefresh.token"])
 	v["AgentToken"] = token
 
-	kubeObjects, err := getKubeObjectsFromTempalte(v, venonaFilesPattern, u.logger)
+	declared := map[string]interface{}{}
+	for k := range opt.Declared {
+		declared[k] = v[k]
+	}
+	prev, err := updateValuesBasedOnPreviousDeployment(ctx, opt.ClusterNamespace, kubeClientset, v)
 	if err != nil {
-		return nil, err
+		u.logger.Debug(fmt.Sprintf("Cannot read previous deployment, using the default values: %v", err))
+	} else {
+		v = prev
 	}
-	v, err = updateValuesBasedOnPreviousDeployment(ctx, opt.ClusterNamespace, kubeClientset, v)
-
-	for fileName, local := range kubeObjects {
-		if _, ok := deletePriorUpgrade[fileName]; ok {
-			u.logger.Debug(fmt.Sprintf("Deleting previous deplopyment of %s", fileName))
-			delOpt := &deleteOptions{
-				logger:         u.logger,
-				templates:      templates.TemplatesMap(),
-				templateValues: v,
-				kubeClientSet:  kubeClientset,
-				namespace:      opt.ClusterNamespace,
-				matchPattern:   fileName,
-				operatorType:   VenonaPluginType,
-			}
-			err := uninstall(ctx, delOpt)
-			if err != nil {
-				return nil, err
-			}
-			installOpt := &installOptions{
-				logger:         u.logger,
-				templates:      templates.TemplatesMap(),
-				templateValues: v,
-				kubeClientSet:  kubeClientset,
-				namespace:      opt.ClusterNamespace,
-				matchPattern:   fileName,
-				operatorType:   VenonaPluginType,
-			}
-			err = install(ctx, installOpt)
-			if err != nil {
-				return nil, err
-			}
-		}
-
-		if _, ok := skipUpgradeFor[fileName]; ok {
-			u.logger.Debug(fmt.Sprintf("Skipping upgrade of %s: should be ignored", fileName))
-			continue
-		}
-
-		_, _, err := kubeobj.ReplaceObject(ctx, kubeClientset, local, opt.ClusterNamespace)
-		if err != nil {
-			return nil, err
-		}
+	for k, val := range declared {
+		v[k] = val
 	}
 
+	err = apply(ctx, &applyOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   venonaFilesPattern,
+		operatorType:   VenonaPluginType,
+		skip:           skipUpgradeFor,
+	})
+	if err != nil {
+		return nil, err
+	}
 	return v, nil
 }
 
@@ -313,10 +301,11 @@ func (u *venonaPlugin) Migrate(ctx context.Context, opt *MigrateOptions, v Value
 				logger:         u.logger,
 				templates:      templates.TemplatesMap(),
 				templateValues: v,
-				kubeClientSet:  kubeClientset,
+				kubeBuilder:    opt.KubeBuilder,
 				namespace:      opt.ClusterNamespace,
 				matchPattern:   fileName,
 				operatorType:   VenonaPluginType,
+				keepInventory:  true,
 			}
 			err := uninstall(ctx, delOpt)
 			if err != nil {
@@ -374,6 +363,28 @@ func (u *venonaPlugin) Test(ctx context.Context, opt *TestOptions, v Values) err
 	})
 }
 
+func (u *venonaPlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	return diff(ctx, &diffOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   venonaFilesPattern,
+		operatorType:   VenonaPluginType,
+	})
+}
+
+func (u *venonaPlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return rollback(ctx, &rollbackOptions{
+		logger:       u.logger,
+		kubeBuilder:  opt.KubeBuilder,
+		namespace:    opt.ClusterNamespace,
+		revision:     opt.Revision,
+		operatorType: VenonaPluginType,
+	})
+}
+
 func (u *venonaPlugin) Name() string {
 	return VenonaPluginType
 }
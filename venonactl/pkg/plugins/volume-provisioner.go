@@ -18,10 +18,8 @@ package plugins
 
 import (
 	"context"
-	"fmt"
 
 	"github.com/codefresh-io/venona/venonactl/pkg/logger"
-	"github.com/codefresh-io/venona/venonactl/pkg/obj/kubeobj"
 	templates "github.com/codefresh-io/venona/venonactl/pkg/templates/kubernetes"
 )
 
@@ -36,32 +34,24 @@ const (
 
 // Install runtimectl environment
 func (u *volumeProvisionerPlugin) Install(ctx context.Context, opt *InstallOptions, v Values) (Values, error) {
-	cs, err := opt.KubeBuilder.BuildClient()
-	if err != nil {
-		return nil, fmt.Errorf("Cannot create kubernetes clientset: %v", err)
-	}
-	return v, install(ctx, &installOptions{
+	return v, apply(ctx, &applyOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    opt.KubeBuilder,
 		namespace:      opt.ClusterNamespace,
 		matchPattern:   volumeProvisionerFilesPattern,
 		dryRun:         opt.DryRun,
+		dryRunObjects:  opt.DryRunObjects,
 		operatorType:   VolumeProvisionerPluginType,
 		logger:         u.logger,
 	})
 }
 
 func (u *volumeProvisionerPlugin) Status(ctx context.Context, statusOpt *StatusOptions, v Values) ([][]string, error) {
-	cs, err := statusOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil, err
-	}
 	opt := &statusOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
-		kubeClientSet:  cs,
+		kubeBuilder:    statusOpt.KubeBuilder,
 		logger:         u.logger,
 		namespace:      statusOpt.ClusterNamespace,
 		matchPattern:   volumeProvisionerFilesPattern,
@@ -71,16 +61,11 @@ func (u *volumeProvisionerPlugin) Status(ctx context.Context, statusOpt *StatusO
 }
 
 func (u *volumeProvisionerPlugin) Delete(ctx context.Context, deleteOpt *DeleteOptions, v Values) error {
-	cs, err := deleteOpt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return err
-	}
 	opt := &deleteOptions{
 		templates:      templates.TemplatesMap(),
 		templateValues: v,
 		logger:         u.logger,
-		kubeClientSet:  cs,
+		kubeBuilder:    deleteOpt.KubeBuilder,
 		namespace:      deleteOpt.ClusterNamespace,
 		matchPattern:   volumeProvisionerFilesPattern,
 		operatorType:   VolumeProvisionerPluginType,
@@ -89,26 +74,21 @@ func (u *volumeProvisionerPlugin) Delete(ctx context.Context, deleteOpt *DeleteO
 }
 
 func (u *volumeProvisionerPlugin) Upgrade(ctx context.Context, opt *UpgradeOptions, v Values) (Values, error) {
-	var err error
-	kubeClientset, err := opt.KubeBuilder.BuildClient()
-	if err != nil {
-		u.logger.Error(fmt.Sprintf("Cannot create kubernetes clientset: %v ", err))
-		return nil, err
-	}
-	kubeObjects, err := getKubeObjectsFromTempalte(v, volumeProvisionerFilesPattern, u.logger)
+	err := apply(ctx, &applyOptions{
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   volumeProvisionerFilesPattern,
+		operatorType:   VolumeProvisionerPluginType,
+		logger:         u.logger,
+	})
 	if err != nil {
 		return nil, err
 	}
-	for _, local := range kubeObjects {
-
-		_, _, err := kubeobj.ReplaceObject(ctx, kubeClientset, local, opt.ClusterNamespace)
-		if err != nil {
-			return nil, err
-		}
-	}
 	return v, nil
-
 }
+
 func (u *volumeProvisionerPlugin) Migrate(ctx context.Context, opt *MigrateOptions, v Values) error {
 	return u.Delete(ctx, &DeleteOptions{
 		ClusterNamespace: opt.ClusterNamespace,
@@ -190,6 +170,28 @@ func (u *volumeProvisionerPlugin) Test(ctx context.Context, opt *TestOptions, v
 	})
 }
 
+func (u *volumeProvisionerPlugin) Diff(ctx context.Context, opt *DiffOptions, v Values) ([]ObjectDiff, error) {
+	return diff(ctx, &diffOptions{
+		logger:         u.logger,
+		templates:      templates.TemplatesMap(),
+		templateValues: v,
+		kubeBuilder:    opt.KubeBuilder,
+		namespace:      opt.ClusterNamespace,
+		matchPattern:   volumeProvisionerFilesPattern,
+		operatorType:   VolumeProvisionerPluginType,
+	})
+}
+
+func (u *volumeProvisionerPlugin) Rollback(ctx context.Context, opt *RollbackOptions, v Values) (Values, error) {
+	return rollback(ctx, &rollbackOptions{
+		logger:       u.logger,
+		kubeBuilder:  opt.KubeBuilder,
+		namespace:    opt.ClusterNamespace,
+		revision:     opt.Revision,
+		operatorType: VolumeProvisionerPluginType,
+	})
+}
+
 func (u *volumeProvisionerPlugin) Name() string {
 	return VolumeProvisionerPluginType
 }
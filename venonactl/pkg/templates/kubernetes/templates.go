@@ -829,8 +829,14 @@ metadata:
   namespace: {{ .Namespace }}
 rules:
 - apiGroups: [""]
-  resources: ["pods", "persistentvolumeclaims"]
-  verbs: ["get", "create", "delete"]
+  resources: ["pods"]
+  verbs: ["get", "list", "create", "delete"]
+- apiGroups: [""]
+  resources: ["persistentvolumeclaims"]
+  verbs: ["get", "create", "delete", "patch"]
+- apiGroups: [""]
+  resources: ["events"]
+  verbs: ["create", "patch"]
 {{- end }}`
 
 	templatesMap["rolebinding.monitor.yaml"] = `{{- if .CreateRbac }}